@@ -7,21 +7,41 @@ import (
 
 	"ia-online-golang/internal/config"
 	"ia-online-golang/internal/lib/logger"
+	"ia-online-golang/internal/lib/secret"
 	"ia-online-golang/internal/storage"
 	"ia-online-golang/internal/utils"
 
 	AuthService "ia-online-golang/internal/services/auth"
 	BitrixService "ia-online-golang/internal/services/bitrix"
+	DadataService "ia-online-golang/internal/services/dadata"
 	EmailService "ia-online-golang/internal/services/email"
 	LeadService "ia-online-golang/internal/services/lead"
+	LedgerService "ia-online-golang/internal/services/ledger"
+	OutboxService "ia-online-golang/internal/services/outbox"
 	PasswordCodeService "ia-online-golang/internal/services/passwordcode"
+	PaymentDetailsService "ia-online-golang/internal/services/paymentdetails"
+	PayoutService "ia-online-golang/internal/services/payout"
+	ReconciliationService "ia-online-golang/internal/services/reconciliation"
 	ReferralService "ia-online-golang/internal/services/referral"
+	SchedulerService "ia-online-golang/internal/services/scheduler"
+	StatisticService "ia-online-golang/internal/services/statistic"
+	StatusService "ia-online-golang/internal/services/status"
+	TariffService "ia-online-golang/internal/services/tariff"
 	TokenService "ia-online-golang/internal/services/token"
 	UserService "ia-online-golang/internal/services/user"
 
+	AddressController "ia-online-golang/internal/http/controllers/address"
 	AuthController "ia-online-golang/internal/http/controllers/auth"
 	BitrixController "ia-online-golang/internal/http/controllers/bitrix"
 	LeadController "ia-online-golang/internal/http/controllers/lead"
+	LedgerController "ia-online-golang/internal/http/controllers/ledger"
+	PaymentDetailsController "ia-online-golang/internal/http/controllers/paymentdetails"
+	PayoutController "ia-online-golang/internal/http/controllers/payout"
+	ProfileController "ia-online-golang/internal/http/controllers/profile"
+	ReferralController "ia-online-golang/internal/http/controllers/referral"
+	StatisticController "ia-online-golang/internal/http/controllers/statistic"
+	StatusController "ia-online-golang/internal/http/controllers/status"
+	TariffController "ia-online-golang/internal/http/controllers/tariff"
 	UserController "ia-online-golang/internal/http/controllers/user"
 	"ia-online-golang/internal/http/middleware"
 	"ia-online-golang/internal/http/validator"
@@ -50,15 +70,49 @@ func main() {
 		cfg.EmailConfig.SMTP.Username,
 		cfg.EmailConfig.SMTP.Password)
 
-	bitrixService := BitrixService.New(log, cfg.BitrixConfig.IncomingWebhook)
+	bitrixService := BitrixService.New(log, cfg.BitrixConfig)
+
+	var dadataService DadataService.DadataServiceI = DadataService.NewFake()
+	if cfg.DadataConfig.ApiKey != "" {
+		dadataService = DadataService.New(log, cfg.DadataConfig)
+	} else {
+		log.Warn("Dadata api_key is not set, address suggestions and cleaning are disabled")
+	}
 
 	passwordCodeService := PasswordCodeService.New(log, storage)
 
 	userService := UserService.New(log, storage)
 
-	leadService := LeadService.New(log, storage, userService, storage, bitrixService, storage)
+	statusService := StatusService.New(log, storage, cfg.StatusConfig.CacheTTL)
+
+	ledgerService := LedgerService.New(log, storage)
+
+	tariffService := TariffService.New(log, storage)
+
+	statisticService := StatisticService.New(log, storage)
+
+	leadService := LeadService.New(log, cfg.LeadsConfig, storage, userService, storage, bitrixService, storage, statusService, storage, dadataService, ledgerService, tariffService)
+
+	if cfg.ReferralsConfig.Clicks.IPSalt == "" {
+		log.Warn("Referrals clicks.ip_salt is not set, visitor IPs are not stored and unique visitors are not counted")
+	}
+
+	referralService := ReferralService.New(log, cfg.ReferralsConfig, storage, storage, storage, statusService, emailService)
+
+	keyring, err := secret.NewKeyring(cfg.EncryptionConfig.Keys, cfg.EncryptionConfig.CurrentKey)
+	if err != nil {
+		log.Fatal("Invalid encryption config:", err)
+	}
+
+	paymentDetailsService := PaymentDetailsService.New(log, keyring, storage)
+
+	payoutService := PayoutService.New(log, cfg.PayoutsConfig, storage, storage, userService, emailService, paymentDetailsService, leadService)
 
-	referralService := ReferralService.New(log, storage)
+	outboxService := OutboxService.New(log, cfg.BitrixConfig.Outbox, storage, storage, storage, userService, bitrixService, statusService)
+
+	reconciliationService := ReconciliationService.New(log, bitrixService, leadService)
+
+	schedulerService := SchedulerService.New(log, cfg.SchedulerConfig, referralService, reconciliationService, payoutService)
 
 	tokenService := TokenService.New(
 		log,
@@ -68,11 +122,9 @@ func main() {
 		int64(cfg.JWTConfig.Refresh.Expiration.Seconds()),
 		storage,
 		userService,
-		leadService,
-		referralService,
 	)
 
-	authService := AuthService.New(log, cfg.HTTPServerConfig.Address, storage, storage, storage, storage, tokenService, emailService, userService, passwordCodeService)
+	authService := AuthService.New(log, cfg.HTTPServerConfig.Address, storage, storage, storage, storage, tokenService, emailService, userService, passwordCodeService, tariffService, storage)
 
 	// Инициализация валидатора
 	validator := validator.New()
@@ -81,8 +133,25 @@ func main() {
 	log.Info("Initializing controllers...")
 	authController := AuthController.New(log, validator, authService)
 	userController := UserController.New(log, validator, userService)
+	profileController := ProfileController.New(log, userService, leadService)
+	statisticController := StatisticController.New(log, validator, statisticService)
 	leadController := LeadController.New(log, validator, leadService)
 	bitrixController := BitrixController.New(log, cfg.BitrixConfig.OutgoingWebhookAuth, leadService)
+	statusController := StatusController.New(log, validator, statusService)
+	addressController := AddressController.New(log, dadataService)
+	ledgerController := LedgerController.New(log, validator, ledgerService)
+	payoutController := PayoutController.New(log, validator, payoutService)
+	paymentDetailsController := PaymentDetailsController.New(log, validator, paymentDetailsService)
+	tariffController := TariffController.New(log, validator, tariffService)
+	referralController := ReferralController.New(log, cfg.ReferralsConfig.Clicks, referralService)
+
+	// Фоновая отправка лидов в Bitrix
+	outboxService.Run()
+	defer outboxService.Stop()
+
+	// Периодические задачи
+	schedulerService.Run()
+	defer schedulerService.Stop()
 
 	// Создаём маршрутизатор
 	mux := http.NewServeMux()
@@ -96,16 +165,49 @@ func main() {
 	mux.HandleFunc("/api/v1/auth/refresh", authController.Refresh)
 	mux.HandleFunc("/api/v1/auth/recover", authController.SendNewPassword)
 
+	// Переход по реферальной ссылке
+	mux.HandleFunc("/r/", referralController.Click)
+
 	mux.HandleFunc("/api/v1/lead/edit", bitrixController.СhangingDeal)
+	mux.HandleFunc("/api/v1/bitrix/event", bitrixController.Event)
 
 	// Защищённые маршруты (нужен JWT-токен)
 	protectedMux := http.NewServeMux()
 	protectedMux.Handle("/api/v1/users", middleware.RoleMiddleware("manager")(http.HandlerFunc(userController.Users)))
 	protectedMux.Handle("/api/v1/user/", middleware.RoleMiddleware("manager")(http.HandlerFunc(userController.User)))
 	protectedMux.Handle("/api/v1/user/edit", middleware.RoleMiddleware("user")(http.HandlerFunc(userController.EditUser)))
+	protectedMux.Handle("/api/v1/user/referral_code", middleware.RoleMiddleware("user")(http.HandlerFunc(userController.ReferralCode)))
+	protectedMux.Handle("/api/v1/me", middleware.RoleMiddleware("user", "manager", "finance")(http.HandlerFunc(profileController.Me)))
+	protectedMux.Handle("/api/v1/me/statistics", middleware.RoleMiddleware("user", "manager")(http.HandlerFunc(profileController.Statistics)))
+	protectedMux.Handle("/api/v1/statistics", middleware.RoleMiddleware("user", "manager")(http.HandlerFunc(statisticController.Statistics)))
+	protectedMux.Handle("/api/v1/user/payment_details", middleware.RoleMiddleware("user", "manager", "finance")(http.HandlerFunc(paymentDetailsController.PaymentDetails)))
 
 	protectedMux.Handle("/api/v1/leads", middleware.RoleMiddleware("manager", "user")(http.HandlerFunc(leadController.Leads)))
 	protectedMux.Handle("/api/v1/lead/save", middleware.RoleMiddleware("user")(http.HandlerFunc(leadController.SaveLead)))
+	protectedMux.Handle("/api/v1/lead/", middleware.RoleMiddleware("manager", "user")(http.HandlerFunc(leadController.Lead)))
+
+	protectedMux.Handle("/api/v1/statuses", middleware.RoleMiddleware("manager", "user")(http.HandlerFunc(statusController.Statuses)))
+	protectedMux.Handle("/api/v1/status/save", middleware.RoleMiddleware("manager")(http.HandlerFunc(statusController.SaveStatus)))
+	protectedMux.Handle("/api/v1/status/edit", middleware.RoleMiddleware("manager")(http.HandlerFunc(statusController.EditStatus)))
+	protectedMux.Handle("/api/v1/tariffs", middleware.RoleMiddleware("manager")(http.HandlerFunc(tariffController.Tariffs)))
+	protectedMux.Handle("/api/v1/tariff/save", middleware.RoleMiddleware("manager")(http.HandlerFunc(tariffController.SaveTariff)))
+	protectedMux.Handle("/api/v1/tariff/edit", middleware.RoleMiddleware("manager")(http.HandlerFunc(tariffController.EditTariff)))
+
+	protectedMux.Handle("/api/v1/address/suggest", middleware.RoleMiddleware("manager", "user")(http.HandlerFunc(addressController.Suggest)))
+
+	protectedMux.Handle("/api/v1/balance", middleware.RoleMiddleware("manager", "user")(http.HandlerFunc(ledgerController.Balance)))
+	protectedMux.Handle("/api/v1/balance/entries", middleware.RoleMiddleware("manager", "user")(http.HandlerFunc(ledgerController.Entries)))
+	protectedMux.Handle("/api/v1/balance/adjustment", middleware.RoleMiddleware("manager")(http.HandlerFunc(ledgerController.Adjust)))
+
+	protectedMux.Handle("/api/v1/payouts", middleware.RoleMiddleware("manager", "user")(http.HandlerFunc(payoutController.Payouts)))
+	protectedMux.Handle("/api/v1/payouts/request", middleware.RoleMiddleware("user")(http.HandlerFunc(payoutController.RequestPayout)))
+	protectedMux.Handle("/api/v1/payouts/funds", middleware.RoleMiddleware("user")(http.HandlerFunc(payoutController.Funds)))
+	protectedMux.Handle("/api/v1/payout/", middleware.RoleMiddleware("manager", "user")(http.HandlerFunc(payoutController.Payout)))
+	protectedMux.Handle("/api/v1/payout_batches", middleware.RoleMiddleware("manager")(http.HandlerFunc(payoutController.Batches)))
+	protectedMux.Handle("/api/v1/payout_batches/create", middleware.RoleMiddleware("manager")(http.HandlerFunc(payoutController.CreateBatch)))
+	protectedMux.Handle("/api/v1/payout_batch/", middleware.RoleMiddleware("manager")(http.HandlerFunc(payoutController.Batch)))
+	protectedMux.Handle("/api/v1/referrals", middleware.RoleMiddleware("manager", "user")(http.HandlerFunc(referralController.Referrals)))
+	protectedMux.Handle("/api/v1/referrals/funnel", middleware.RoleMiddleware("manager", "user")(http.HandlerFunc(referralController.Funnel)))
 
 	protectedMux.Handle("/api/v1/auth/new_password", middleware.RoleMiddleware("user")(http.HandlerFunc(authController.NewPassword)))
 
@@ -118,9 +220,39 @@ func main() {
 	finalMux.Handle("/api/v1/users", protectedRoutes)
 	finalMux.Handle("/api/v1/user", protectedRoutes)
 	finalMux.Handle("/api/v1/user/edit", protectedRoutes)
+	finalMux.Handle("/api/v1/user/referral_code", protectedRoutes)
+	finalMux.Handle("/api/v1/user/payment_details", protectedRoutes)
+	finalMux.Handle("/api/v1/me", protectedRoutes)
+	finalMux.Handle("/api/v1/me/statistics", protectedRoutes)
+	finalMux.Handle("/api/v1/statistics", protectedRoutes)
 
 	finalMux.Handle("/api/v1/leads", protectedRoutes)
 	finalMux.Handle("/api/v1/lead/save", protectedRoutes)
+	finalMux.Handle("/api/v1/lead/", protectedRoutes)
+	finalMux.Handle("/api/v1/lead/edit", mux) // вебхук Bitrix остаётся открытым
+
+	finalMux.Handle("/api/v1/statuses", protectedRoutes)
+	finalMux.Handle("/api/v1/status/save", protectedRoutes)
+	finalMux.Handle("/api/v1/status/edit", protectedRoutes)
+	finalMux.Handle("/api/v1/tariffs", protectedRoutes)
+	finalMux.Handle("/api/v1/tariff/save", protectedRoutes)
+	finalMux.Handle("/api/v1/tariff/edit", protectedRoutes)
+
+	finalMux.Handle("/api/v1/address/suggest", protectedRoutes)
+
+	finalMux.Handle("/api/v1/balance", protectedRoutes)
+	finalMux.Handle("/api/v1/balance/entries", protectedRoutes)
+	finalMux.Handle("/api/v1/balance/adjustment", protectedRoutes)
+
+	finalMux.Handle("/api/v1/payouts", protectedRoutes)
+	finalMux.Handle("/api/v1/payouts/request", protectedRoutes)
+	finalMux.Handle("/api/v1/payouts/funds", protectedRoutes)
+	finalMux.Handle("/api/v1/payout/", protectedRoutes)
+	finalMux.Handle("/api/v1/payout_batches", protectedRoutes)
+	finalMux.Handle("/api/v1/payout_batches/create", protectedRoutes)
+	finalMux.Handle("/api/v1/payout_batch/", protectedRoutes)
+	finalMux.Handle("/api/v1/referrals", protectedRoutes)
+	finalMux.Handle("/api/v1/referrals/funnel", protectedRoutes)
 
 	finalMux.Handle("/api/v1/auth/new_password", protectedRoutes)
 
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"ia-online-golang/internal/config"
+	"ia-online-golang/internal/lib/logger"
+	"ia-online-golang/internal/storage"
+
+	BitrixService "ia-online-golang/internal/services/bitrix"
+	OutboxService "ia-online-golang/internal/services/outbox"
+	StatusService "ia-online-golang/internal/services/status"
+	UserService "ia-online-golang/internal/services/user"
+)
+
+// События outbox, отправку которых outbox прекратил:
+//
+//	go run ./cmd/outbox -config config.yaml -action list [-lead 15]
+//	go run ./cmd/outbox -config config.yaml -action requeue -event 42
+//	go run ./cmd/outbox -config config.yaml -action requeue -lead 15
+//
+// requeue с -lead возвращает в очередь все события лида в dead-letter.
+func main() {
+	// Флаги объявляются до config.MustLoad, который вызывает flag.Parse
+	action := flag.String("action", "list", "list or requeue")
+	eventID := flag.Int64("event", 0, "outbox event ID")
+	leadID := flag.Int64("lead", 0, "lead ID")
+
+	cfg := config.MustLoad()
+
+	log := logger.SetupLogger(cfg.Env)
+
+	storage, err := storage.NewStorage(cfg.StorageConfig.Path)
+	if err != nil {
+		log.Fatal("Error connecting to storage:", err)
+	}
+	defer storage.Close()
+
+	userService := UserService.New(log, storage)
+	bitrixService := BitrixService.New(log, cfg.BitrixConfig)
+	statusService := StatusService.New(log, storage, cfg.StatusConfig.CacheTTL)
+	outboxService := OutboxService.New(log, cfg.BitrixConfig.Outbox, storage, storage, storage, userService, bitrixService, statusService)
+
+	ctx := context.Background()
+
+	var lead *int64
+	if *leadID > 0 {
+		lead = leadID
+	}
+
+	var result any
+
+	switch *action {
+	case "list":
+		result, err = outboxService.DeadEvents(ctx, lead)
+	case "requeue":
+		if *eventID > 0 {
+			result, err = outboxService.Requeue(ctx, *eventID)
+			break
+		}
+		if lead == nil {
+			log.Fatal("-event or -lead is required")
+		}
+
+		events, deadErr := outboxService.DeadEvents(ctx, lead)
+		if deadErr != nil {
+			log.Fatal("Listing dead events failed:", deadErr)
+		}
+
+		// События лида возвращаются по порядку создания, чтобы сделка создалась раньше изменений
+		requeued := events[:0]
+		for _, event := range events {
+			event, err = outboxService.Requeue(ctx, event.ID)
+			if err != nil {
+				break
+			}
+			requeued = append(requeued, event)
+		}
+		result = requeued
+	default:
+		log.Fatalf("Unknown action %q", *action)
+	}
+
+	if err != nil {
+		log.Fatalf("%s failed: %v", *action, err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"strconv"
+
+	"ia-online-golang/internal/config"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/lib/logger"
+	"ia-online-golang/internal/lib/secret"
+	"ia-online-golang/internal/storage"
+
+	BitrixService "ia-online-golang/internal/services/bitrix"
+	DadataService "ia-online-golang/internal/services/dadata"
+	EmailService "ia-online-golang/internal/services/email"
+	LeadService "ia-online-golang/internal/services/lead"
+	LedgerService "ia-online-golang/internal/services/ledger"
+	PaymentDetailsService "ia-online-golang/internal/services/paymentdetails"
+	PayoutService "ia-online-golang/internal/services/payout"
+	StatusService "ia-online-golang/internal/services/status"
+	TariffService "ia-online-golang/internal/services/tariff"
+	UserService "ia-online-golang/internal/services/user"
+)
+
+// Реестры выплат для банка:
+//
+//	go run ./cmd/payouts -config config.yaml -manager 1 -action create
+//	go run ./cmd/payouts -config config.yaml -manager 1 -action export -batch 3 -format 1c -file payouts_3.txt
+//	go run ./cmd/payouts -config config.yaml -manager 1 -action import -batch 3 -format 1c -file statement.txt
+//
+// -manager — ID менеджера, от имени которого проводятся действия; без -file
+// реестр пишется в stdout, а ответ банка читается из stdin.
+func main() {
+	// Флаги объявляются до config.MustLoad, который вызывает flag.Parse
+	action := flag.String("action", "list", "create, list, show, export or import")
+	managerID := flag.Int64("manager", 0, "ID of the manager performing the action")
+	batchID := flag.Int64("batch", 0, "payout batch ID")
+	format := flag.String("format", "csv", "registry format")
+	path := flag.String("file", "", "registry output or confirmation input file")
+
+	cfg := config.MustLoad()
+
+	log := logger.SetupLogger(cfg.Env)
+
+	if *managerID <= 0 {
+		log.Fatal("-manager is required")
+	}
+
+	storage, err := storage.NewStorage(cfg.StorageConfig.Path)
+	if err != nil {
+		log.Fatal("Error connecting to storage:", err)
+	}
+	defer storage.Close()
+
+	emailService := EmailService.New(
+		cfg.EmailConfig.SMTP.Host,
+		strconv.Itoa(cfg.EmailConfig.SMTP.PortSSL),
+		cfg.EmailConfig.SMTP.Username,
+		cfg.EmailConfig.SMTP.Password)
+	userService := UserService.New(log, storage)
+
+	// Оплата заявки закрывает лиды агента, поэтому нужен сервис лидов
+	bitrixService := BitrixService.New(log, cfg.BitrixConfig)
+	statusService := StatusService.New(log, storage, cfg.StatusConfig.CacheTTL)
+	ledgerService := LedgerService.New(log, storage)
+	tariffService := TariffService.New(log, storage)
+	leadService := LeadService.New(log, cfg.LeadsConfig, storage, userService, storage, bitrixService, storage, statusService, storage, DadataService.NewFake(), ledgerService, tariffService)
+
+	keyring, err := secret.NewKeyring(cfg.EncryptionConfig.Keys, cfg.EncryptionConfig.CurrentKey)
+	if err != nil {
+		log.Fatal("Invalid encryption config:", err)
+	}
+
+	paymentDetailsService := PaymentDetailsService.New(log, keyring, storage)
+	payoutService := PayoutService.New(log, cfg.PayoutsConfig, storage, storage, userService, emailService, paymentDetailsService, leadService)
+
+	// Командная строка доступна только тем, у кого есть доступ к серверу и конфигу,
+	// поэтому действия выполняются с правами финансового менеджера
+	ctx := context.WithValue(context.Background(), context_keys.UserIDKey, *managerID)
+	ctx = context.WithValue(ctx, context_keys.UserRoleKey, []string{"manager", "finance"})
+
+	var result any
+
+	switch *action {
+	case "create":
+		result, err = payoutService.CreateBatch(ctx)
+	case "list":
+		result, err = payoutService.Batches(ctx, 100, 0)
+	case "show":
+		result, err = payoutService.Batch(ctx, *batchID)
+	case "export":
+		file, exportErr := payoutService.ExportBatch(ctx, *batchID, *format)
+		if exportErr != nil {
+			log.Fatal("Export failed:", exportErr)
+		}
+
+		if *path == "" {
+			os.Stdout.Write(file.Data)
+			return
+		}
+		// В реестре счета и ИНН агентов: файл доступен только владельцу
+		if err := os.WriteFile(*path, file.Data, 0o600); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.Chmod(*path, 0o600); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Registry written to %s", *path)
+		return
+	case "import":
+		var input io.Reader = os.Stdin
+		if *path != "" {
+			f, openErr := os.Open(*path)
+			if openErr != nil {
+				log.Fatal(openErr)
+			}
+			defer f.Close()
+			input = f
+		}
+		result, err = payoutService.ImportConfirmation(ctx, *batchID, *format, input)
+	default:
+		log.Fatalf("Unknown action %q", *action)
+	}
+
+	if err != nil {
+		log.Fatalf("%s failed: %v", *action, err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"ia-online-golang/internal/config"
+	"ia-online-golang/internal/lib/logger"
+	"ia-online-golang/internal/storage"
+
+	BitrixService "ia-online-golang/internal/services/bitrix"
+	DadataService "ia-online-golang/internal/services/dadata"
+	LeadService "ia-online-golang/internal/services/lead"
+	LedgerService "ia-online-golang/internal/services/ledger"
+	ReconciliationService "ia-online-golang/internal/services/reconciliation"
+	StatusService "ia-online-golang/internal/services/status"
+	TariffService "ia-online-golang/internal/services/tariff"
+	UserService "ia-online-golang/internal/services/user"
+)
+
+// Разовая сверка лидов со сделками Bitrix: go run ./cmd/reconcile -config config.yaml
+func main() {
+	cfg := config.MustLoad()
+
+	log := logger.SetupLogger(cfg.Env)
+
+	storage, err := storage.NewStorage(cfg.StorageConfig.Path)
+	if err != nil {
+		log.Fatal("Error connecting to storage:", err)
+	}
+	defer storage.Close()
+
+	bitrixService := BitrixService.New(log, cfg.BitrixConfig)
+	userService := UserService.New(log, storage)
+	statusService := StatusService.New(log, storage, cfg.StatusConfig.CacheTTL)
+	ledgerService := LedgerService.New(log, storage)
+	tariffService := TariffService.New(log, storage)
+	leadService := LeadService.New(log, cfg.LeadsConfig, storage, userService, storage, bitrixService, storage, statusService, storage, DadataService.NewFake(), ledgerService, tariffService)
+	reconciliationService := ReconciliationService.New(log, bitrixService, leadService)
+
+	report, err := reconciliationService.Reconcile(context.Background())
+	if err != nil {
+		log.Fatal("Reconciliation failed:", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+
+	"ia-online-golang/internal/config"
+	"ia-online-golang/internal/lib/logger"
+	"ia-online-golang/internal/lib/secret"
+	"ia-online-golang/internal/storage"
+
+	PaymentDetailsService "ia-online-golang/internal/services/paymentdetails"
+)
+
+// Перешифровка платёжных реквизитов текущим ключом: go run ./cmd/rotatekeys -config config.yaml
+//
+// Порядок смены ключа: добавить новый ключ в encryption.keys, сделать его current_key,
+// перезапустить сервер, выполнить эту команду и после неё удалить старый ключ из конфига.
+func main() {
+	cfg := config.MustLoad()
+
+	log := logger.SetupLogger(cfg.Env)
+
+	keyring, err := secret.NewKeyring(cfg.EncryptionConfig.Keys, cfg.EncryptionConfig.CurrentKey)
+	if err != nil {
+		log.Fatal("Invalid encryption config:", err)
+	}
+
+	storage, err := storage.NewStorage(cfg.StorageConfig.Path)
+	if err != nil {
+		log.Fatal("Error connecting to storage:", err)
+	}
+	defer storage.Close()
+
+	paymentDetailsService := PaymentDetailsService.New(log, keyring, storage)
+
+	rotated, err := paymentDetailsService.RotateKeys(context.Background())
+	if err != nil {
+		log.Fatalf("Rotation stopped after %d records: %v", rotated, err)
+	}
+
+	log.Infof("Re-encrypted %d records with key %s", rotated, keyring.CurrentKeyID())
+}
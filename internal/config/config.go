@@ -2,8 +2,12 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"ia-online-golang/internal/lib/requisites"
 	"log"
+	"net/url"
 	"os"
+	"regexp"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -17,15 +21,25 @@ type Config struct {
 	HTTPServerConfig HTTPServerConfig `yaml:"http_server"`
 	EmailConfig      EmailConfig      `yaml:"email"`
 	BitrixConfig     BitrixConfig     `yaml:"bitrix"`
+	StatusConfig     StatusConfig     `yaml:"statuses"`
+	SchedulerConfig  SchedulerConfig  `yaml:"scheduler"`
+	LeadsConfig      LeadsConfig      `yaml:"leads"`
+	ReferralsConfig  ReferralsConfig  `yaml:"referrals"`
+	PayoutsConfig    PayoutsConfig    `yaml:"payouts"`
+	EncryptionConfig EncryptionConfig `yaml:"encryption"`
 }
 
 type StorageConfig struct {
 	Path string `yaml:"path"`
 }
 
+// DadataConfig — доступ к Dadata; без api_key подсказки и стандартизация адресов отключены
 type DadataConfig struct {
-	ApiKey    string `yaml:"api_key"`
-	SecretKey string `yaml:"secret_key"`
+	ApiKey     string        `yaml:"api_key"`
+	SecretKey  string        `yaml:"secret_key"`
+	SuggestURL string        `yaml:"suggest_url" env-default:"https://suggestions.dadata.ru/suggestions/api/4_1/rs/suggest/address"`
+	CleanURL   string        `yaml:"clean_url" env-default:"https://cleaner.dadata.ru/api/v1/clean/address"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"3s"`
 }
 
 type JWTConfig struct {
@@ -60,8 +74,130 @@ type EmailInfo struct {
 }
 
 type BitrixConfig struct {
-	OutgoingWebhookAuth string `yaml:"outgoing_webhook_auth"`
-	IncomingWebhook     string `yaml:"incoming_webhook"`
+	OutgoingWebhookAuth string               `yaml:"outgoing_webhook_auth"`
+	IncomingWebhook     string               `yaml:"incoming_webhook"`
+	Timeout             time.Duration        `yaml:"timeout" env-default:"10s"`
+	Deal                BitrixDealConfig     `yaml:"deal"`
+	Fields              BitrixFieldsConfig   `yaml:"fields"`
+	Services            BitrixServicesConfig `yaml:"services"`
+	Outbox              OutboxConfig         `yaml:"outbox"`
+}
+
+// BitrixDealConfig описывает воронку, в которой создаются сделки.
+// Значения по умолчанию совпадают с прежними захардкоженными в BitrixService.
+type BitrixDealConfig struct {
+	CategoryID int64  `yaml:"category_id" env-default:"42"`
+	StageNew   string `yaml:"stage_new"` // по умолчанию C<category_id>:NEW
+	Title      string `yaml:"title" env-default:"Заявка с сайта ia-on.ru"`
+	TypeID     string `yaml:"type_id" env-default:"SALE"`
+}
+
+// BitrixFieldsConfig сопоставляет атрибуты лида пользовательским полям сделки (UF_CRM_*)
+type BitrixFieldsConfig struct {
+	Address        string `yaml:"address" env-default:"UF_CRM_1697646751446"`
+	Services       string `yaml:"services" env-default:"UF_CRM_1743744405443"`
+	Comment        string `yaml:"comment" env-default:"UF_CRM_1697294923031"`
+	AgentCity      string `yaml:"agent_city" env-default:"UF_CRM_1703703644316"`
+	AgentName      string `yaml:"agent_name" env-default:"UF_CRM_1697357613372"`
+	AgentPhone     string `yaml:"agent_phone" env-default:"UF_CRM_1700909419606"`
+	AgentID        string `yaml:"agent_id" env-default:"UF_CRM_1701035680304"`
+	RewardInternet string `yaml:"reward_internet" env-default:"UF_CRM_1737451536004"`
+	RewardCleaning string `yaml:"reward_cleaning" env-default:"UF_CRM_1744353480781"`
+	RewardShipping string `yaml:"reward_shipping" env-default:"UF_CRM_1744354030686"`
+}
+
+// BitrixServicesConfig хранит ID элементов списка услуг в поле Fields.Services
+type BitrixServicesConfig struct {
+	Internet int `yaml:"internet" env-default:"510"`
+	Cleaning int `yaml:"cleaning" env-default:"512"`
+	Shipping int `yaml:"shipping" env-default:"514"`
+}
+
+// OutboxConfig задаёт работу фоновой отправки лидов в Bitrix
+type OutboxConfig struct {
+	Interval          time.Duration `yaml:"interval" env-default:"10s"`
+	BatchSize         int           `yaml:"batch_size" env-default:"20"`
+	MaxAttempts       int           `yaml:"max_attempts" env-default:"10"`
+	BaseBackoff       time.Duration `yaml:"base_backoff" env-default:"30s"`
+	MaxBackoff        time.Duration `yaml:"max_backoff" env-default:"1h"`
+	ProcessingTimeout time.Duration `yaml:"processing_timeout" env-default:"5m"`
+}
+
+type StatusConfig struct {
+	CacheTTL time.Duration `yaml:"cache_ttl" env-default:"5m"`
+}
+
+// LeadsConfig задаёт правила приёма лидов. Лид на тот же телефон или адрес,
+// поданный в течение DuplicateWindow, считается дублем; 0 отключает проверку.
+// Вознаграждение считается по тарифу на момент создания лида; при BitrixRewardOverride
+// ненулевая сумма из сделки Bitrix заменяет тариф.
+//
+// ReferralCommissions — проценты от вознаграждений агента, которые получают пригласившие его
+// по цепочке: [10, 3] — 10% прямому пригласившему и 3% тому, кто пригласил его. Длина списка
+// задаёт глубину; пустой список отключает комиссии.
+type LeadsConfig struct {
+	DuplicateWindow      time.Duration `yaml:"duplicate_window" env-default:"720h"`
+	BitrixRewardOverride bool          `yaml:"bitrix_reward_override" env-default:"false"`
+	ReferralCommissions  []float64     `yaml:"referral_commissions"`
+}
+
+// ReferralsConfig задаёт условия активации реферала: не меньше MinLeads лидов в статусах
+// Statuses (системные имена) с общим вознаграждением не меньше MinReward рублей, поданных
+// за Window с регистрации. Кто не выполнил условия за Window, истекает; 0 — срок не ограничен.
+type ReferralsConfig struct {
+	MinLeads  int64         `yaml:"min_leads" env-default:"3"`
+	Statuses  []string      `yaml:"statuses" env-default:"ready,paid"`
+	Window    time.Duration `yaml:"window" env-default:"0s"`
+	MinReward int64         `yaml:"min_reward" env-default:"0"`
+	Clicks    ClicksConfig  `yaml:"clicks"`
+}
+
+// ClicksConfig задаёт учёт переходов по реферальным ссылкам /r/{code}. Посетитель
+// перенаправляется на LandingURL с параметром referral_code, а cookie с переходом живёт
+// AttributionTTL: регистрация без кода за это время засчитывается агенту из ссылки.
+// IP хранится хешем с солью IPSalt, без соли не хранится вовсе; при TrustProxy адрес берётся из X-Real-IP / X-Forwarded-For.
+type ClicksConfig struct {
+	LandingURL     string        `yaml:"landing_url" env-default:"/"`
+	AttributionTTL time.Duration `yaml:"attribution_ttl" env-default:"720h"`
+	IPSalt         string        `yaml:"ip_salt"`
+	TrustProxy     bool          `yaml:"trust_proxy" env-default:"false"`
+}
+
+// PayoutsConfig задаёт правила вывода вознаграждений. Начисление становится доступным
+// к выводу через Hold после проводки; заявка меньше MinAmount рублей не принимается.
+// Если задан ManagerEmail, на него уходят уведомления о новых заявках.
+type PayoutsConfig struct {
+	MinAmount    int64         `yaml:"min_amount" env-default:"1000"`
+	Hold         time.Duration `yaml:"hold" env-default:"336h"`
+	ManagerEmail string        `yaml:"manager_email"`
+	Payer        PayerConfig   `yaml:"payer"`
+}
+
+// PayerConfig — реквизиты компании, с расчётного счёта которой платятся выплаты.
+// Попадают в реестр платежей для банка.
+type PayerConfig struct {
+	Name        string `yaml:"name"`
+	INN         string `yaml:"inn"`
+	KPP         string `yaml:"kpp"`
+	Account     string `yaml:"account"`
+	BankName    string `yaml:"bank_name"`
+	BIK         string `yaml:"bik"`
+	CorrAccount string `yaml:"corr_account"`
+}
+
+// EncryptionConfig — ключи AES-256 (32 байта в base64) для шифрования платёжных реквизитов.
+// Новые данные шифруются ключом CurrentKey; прежние ключи остаются в Keys,
+// пока записи не перешифрованы командой cmd/rotatekeys.
+type EncryptionConfig struct {
+	CurrentKey string            `yaml:"current_key"`
+	Keys       map[string]string `yaml:"keys"`
+}
+
+// SchedulerConfig задаёт расписания фоновых задач в формате cron (5 полей)
+type SchedulerConfig struct {
+	Referrals      string `yaml:"referrals" env-default:"*/10 * * * *"`
+	Reconciliation string `yaml:"reconciliation" env-default:"15 * * * *"`
+	PayoutLeads    string `yaml:"payout_leads" env-default:"*/5 * * * *"`
 }
 
 func MustLoad() *Config {
@@ -86,5 +222,185 @@ func MustLoad() *Config {
 		log.Fatalf("Cannot read config: %s", err)
 	}
 
+	if err := cfg.BitrixConfig.Validate(); err != nil {
+		log.Fatalf("Invalid bitrix config: %s", err)
+	}
+
+	if err := cfg.LeadsConfig.Validate(); err != nil {
+		log.Fatalf("Invalid leads config: %s", err)
+	}
+
+	if err := cfg.ReferralsConfig.Validate(); err != nil {
+		log.Fatalf("Invalid referrals config: %s", err)
+	}
+
+	if err := cfg.PayoutsConfig.Validate(); err != nil {
+		log.Fatalf("Invalid payouts config: %s", err)
+	}
+
 	return &cfg
 }
+
+var bitrixUserField = regexp.MustCompile(`^UF_CRM_[A-Z0-9_]+$`)
+
+// Validate проверяет, что все поля Bitrix заданы и не пересекаются
+func (b *BitrixConfig) Validate() error {
+	if b.Deal.CategoryID < 0 {
+		return fmt.Errorf("deal.category_id must not be negative")
+	}
+
+	if b.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+
+	if b.Deal.StageNew == "" {
+		b.Deal.StageNew = "NEW"
+		if b.Deal.CategoryID > 0 {
+			b.Deal.StageNew = fmt.Sprintf("C%d:NEW", b.Deal.CategoryID)
+		}
+	}
+
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"address", b.Fields.Address},
+		{"services", b.Fields.Services},
+		{"comment", b.Fields.Comment},
+		{"agent_city", b.Fields.AgentCity},
+		{"agent_name", b.Fields.AgentName},
+		{"agent_phone", b.Fields.AgentPhone},
+		{"agent_id", b.Fields.AgentID},
+		{"reward_internet", b.Fields.RewardInternet},
+		{"reward_cleaning", b.Fields.RewardCleaning},
+		{"reward_shipping", b.Fields.RewardShipping},
+	}
+
+	seenFields := make(map[string]string)
+	for _, f := range fields {
+		if !bitrixUserField.MatchString(f.value) {
+			return fmt.Errorf("fields.%s: %q is not a UF_CRM_* field code", f.name, f.value)
+		}
+		if other, ok := seenFields[f.value]; ok {
+			return fmt.Errorf("fields.%s: %s is already used by fields.%s", f.name, f.value, other)
+		}
+		seenFields[f.value] = f.name
+	}
+
+	services := []struct {
+		name  string
+		value int
+	}{
+		{"internet", b.Services.Internet},
+		{"cleaning", b.Services.Cleaning},
+		{"shipping", b.Services.Shipping},
+	}
+
+	seenServices := make(map[int]string)
+	for _, svc := range services {
+		if svc.value <= 0 {
+			return fmt.Errorf("services.%s: enum id is required", svc.name)
+		}
+		if other, ok := seenServices[svc.value]; ok {
+			return fmt.Errorf("services.%s: %d is already used by services.%s", svc.name, svc.value, other)
+		}
+		seenServices[svc.value] = svc.name
+	}
+
+	return nil
+}
+
+// Validate проверяет, что комиссии пригласившим не отрицательны и вместе не больше 100%
+func (l *LeadsConfig) Validate() error {
+	var total float64
+	for i, percent := range l.ReferralCommissions {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("referral_commissions[%d]: %v is not a percentage", i, percent)
+		}
+		total += percent
+	}
+
+	if total > 100 {
+		return fmt.Errorf("referral_commissions: total %v%% exceeds 100%%", total)
+	}
+
+	return nil
+}
+
+// Validate проверяет, что условия активации рефералов выполнимы
+func (r *ReferralsConfig) Validate() error {
+	if len(r.Statuses) == 0 {
+		return fmt.Errorf("statuses: at least one status is required")
+	}
+	if r.MinLeads < 0 {
+		return fmt.Errorf("min_leads must not be negative")
+	}
+	if r.MinReward < 0 {
+		return fmt.Errorf("min_reward must not be negative")
+	}
+	if r.Window < 0 {
+		return fmt.Errorf("window must not be negative")
+	}
+	if r.MinLeads == 0 && r.MinReward == 0 {
+		return fmt.Errorf("min_leads or min_reward must be set")
+	}
+	if r.Clicks.AttributionTTL <= 0 {
+		return fmt.Errorf("clicks.attribution_ttl must be positive")
+	}
+	if _, err := url.Parse(r.Clicks.LandingURL); err != nil {
+		return fmt.Errorf("clicks.landing_url: %w", err)
+	}
+	// Короткая соль перебирается вместе со всем пространством адресов.
+	// Пустая допустима: тогда IP посетителей не хранятся
+	if r.Clicks.IPSalt != "" && len(r.Clicks.IPSalt) < 16 {
+		return fmt.Errorf("clicks.ip_salt must be at least 16 characters")
+	}
+
+	return nil
+}
+
+// Validate проверяет правила вывода и, если реквизиты плательщика заданы,
+// их контрольные суммы: с ошибкой в счёте банк отклонит весь реестр
+func (p *PayoutsConfig) Validate() error {
+	if p.MinAmount <= 0 {
+		return fmt.Errorf("min_amount must be positive")
+	}
+	if p.Hold < 0 {
+		return fmt.Errorf("hold must not be negative")
+	}
+
+	// Без плательщика выгрузка реестров недоступна, но заявки обрабатываются вручную
+	if p.Payer == (PayerConfig{}) {
+		return nil
+	}
+
+	if err := p.Payer.Validate(); err != nil {
+		return fmt.Errorf("payer.%w", err)
+	}
+
+	return nil
+}
+
+// Validate проверяет реквизиты плательщика так же, как реквизиты агентов
+func (p *PayerConfig) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !requisites.ValidINN(p.INN) {
+		return fmt.Errorf("inn: %q is not a valid INN", p.INN)
+	}
+	if p.KPP != "" && len(p.KPP) != 9 {
+		return fmt.Errorf("kpp: %q must be 9 characters", p.KPP)
+	}
+	if !requisites.ValidBIK(p.BIK) {
+		return fmt.Errorf("bik: %q is not a valid BIK", p.BIK)
+	}
+	if !requisites.ValidAccount(p.Account, p.BIK) {
+		return fmt.Errorf("account: %q does not match bik %s", p.Account, p.BIK)
+	}
+	if p.CorrAccount != "" && !requisites.ValidCorrAccount(p.CorrAccount, p.BIK) {
+		return fmt.Errorf("corr_account: %q does not match bik %s", p.CorrAccount, p.BIK)
+	}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+package dto
+
+type AddressSuggestionDTO struct {
+	Value             string   `json:"value"`
+	UnrestrictedValue string   `json:"unrestricted_value"`
+	FiasID            string   `json:"fias_id"`
+	City              string   `json:"city"`
+	GeoLat            *float64 `json:"geo_lat"`
+	GeoLon            *float64 `json:"geo_lon"`
+}
@@ -0,0 +1,5 @@
+package dto
+
+type CommentDTO struct {
+	Text string `json:"text" validate:"required,max=4000"`
+}
@@ -1,37 +1,112 @@
 package dto
 
-import "time"
+import (
+	"ia-online-golang/internal/lib/money"
+	"ia-online-golang/internal/models"
+	"time"
+)
 
+// LeadDTO — новый лид от агента. Вознаграждения считаются по тарифам и сделке Bitrix, агент их не задаёт.
 type LeadDTO struct {
-	Name           string  `json:"name" validate:"required"`
-	PhoneNumber    string  `json:"phone_number" validate:"required"`
-	Address        string  `json:"address" validate:"required"`
-	Comment        string  `json:"comment" validate:"omitempty"`
-	RewardInternet float64 `json:"reward_internet" validate:"omitempty"`
-	RewardCleaning float64 `json:"reward_cleaning" validate:"omitempty"`
-	RewardShipping float64 `json:"reward_shipping" validate:"omitempty"`
-	IsInternet     bool    `json:"is_internet"`
-	IsShipping     bool    `json:"is_shipping"`
-	IsCleaning     bool    `json:"is_cleaning" validate:"atLeastOneService"`
+	Name        string `json:"name" validate:"required"`
+	PhoneNumber string `json:"phone_number" validate:"required,phone"`
+	Address     string `json:"address" validate:"required"`
+	Comment     string `json:"comment" validate:"omitempty"`
+	IsInternet  bool   `json:"is_internet"`
+	IsShipping  bool   `json:"is_shipping"`
+	IsCleaning  bool   `json:"is_cleaning" validate:"atLeastOneService"`
+}
+
+// LeadEditDTO — частичное изменение лида агентом; пустые поля не меняются
+type LeadEditDTO struct {
+	Name        *string `json:"name" validate:"omitempty,min=1"`
+	PhoneNumber *string `json:"phone_number" validate:"omitempty,phone"`
+	Address     *string `json:"address" validate:"omitempty,min=1"`
+	IsInternet  *bool   `json:"is_internet"`
+	IsShipping  *bool   `json:"is_shipping"`
+	IsCleaning  *bool   `json:"is_cleaning"`
+}
+
+// LeadDuplicateDTO — сведения о ранее поданном лиде на того же клиента.
+// LeadID раскрывается только владельцу лида.
+type LeadDuplicateDTO struct {
+	LeadID    *int64     `json:"lead_id"`
+	Own       bool       `json:"own"`
+	StatusID  int64      `json:"status_id"`
+	CreatedAt *time.Time `json:"created_at"`
 }
 
 type LeadFilterDTO struct {
-	StatusID   *int64     `json:"status_id"`
-	StartDate  *time.Time `json:"start_date"`
-	EndDate    *time.Time `json:"end_date"`
-	UserID     *int64     `json:"user_id"`
-	Limit      int64      `json:"limit"`
-	Offset     int64      `json:"offset"`
-	IsInternet *bool      `json:"is_internet"`
-	IsShipping *bool      `json:"is_shipping"`
-	IsCleaning *bool      `json:"is_cleaning"`
-	Search     *string    `json:"search"`
+	StatusID     *int64     `json:"status_id"`
+	StartDate    *time.Time `json:"start_date"`
+	EndDate      *time.Time `json:"end_date"`
+	UserID       *int64     `json:"user_id"`
+	Limit        int64      `json:"limit"`
+	Offset       int64      `json:"offset"`
+	IsInternet   *bool      `json:"is_internet"`
+	IsShipping   *bool      `json:"is_shipping"`
+	IsCleaning   *bool      `json:"is_cleaning"`
+	Search       *string    `json:"search"`
+	WithComments bool       `json:"with_comments"`
+	Sort         string     `json:"sort" validate:"omitempty,oneof=created_at status reward_total relevance"`
+	Order        string     `json:"order" validate:"omitempty,oneof=asc desc"`
+	Cursor       string     `json:"cursor"`
 }
 
+// LeadListDTO — страница списка лидов; NextCursor пуст, когда страниц больше нет
+// или сортировка не по created_at
+type LeadListDTO struct {
+	Items      []models.Lead `json:"items"`
+	Total      int64         `json:"total"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+// UserStatistic — начисления агента за период по книге начислений, за вычетом сторно
 type UserStatistic struct {
-	Internet  float64 `json:"internet"`
-	Cleaning  float64 `json:"cleaning"`
-	Shipping  float64 `json:"shipping"`
-	Referrals float64 `json:"referrals"`
-	Total     float64 `json:"total"`
+	Internet  money.Amount `json:"internet"`
+	Cleaning  money.Amount `json:"cleaning"`
+	Shipping  money.Amount `json:"shipping"`
+	Referrals money.Amount `json:"referrals"`
+	// Комиссии с вознаграждений рефералов всех уровней
+	Commissions money.Amount `json:"commissions"`
+	Total       money.Amount `json:"total"`
+}
+
+// Add учитывает сумму по статье книги начислений
+func (s *UserStatistic) Add(service string, amount money.Amount) {
+	switch service {
+	case models.LedgerServiceInternet:
+		s.Internet += amount
+	case models.LedgerServiceCleaning:
+		s.Cleaning += amount
+	case models.LedgerServiceShipping:
+		s.Shipping += amount
+	case models.LedgerServiceReferral:
+		s.Referrals += amount
+	case models.LedgerServiceCommission:
+		s.Commissions += amount
+	}
+	s.Total += amount
+}
+
+// StatisticPeriodDTO — начисления агента за период [From, To)
+type StatisticPeriodDTO struct {
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+	UserStatistic
+}
+
+// BalanceDTO — текущий баланс агента: всё начисленное минус выплаченное
+type BalanceDTO struct {
+	UserID  int64        `json:"user_id"`
+	Accrued money.Amount `json:"accrued"`
+	PaidOut money.Amount `json:"paid_out"`
+	Balance money.Amount `json:"balance"`
+}
+
+// LedgerAdjustmentDTO — ручная корректировка баланса агента менеджером
+type LedgerAdjustmentDTO struct {
+	UserID      int64        `json:"user_id" validate:"required"`
+	Amount      money.Amount `json:"amount" validate:"required"`
+	Description string       `json:"description" validate:"required,max=500"`
 }
@@ -0,0 +1,15 @@
+package dto
+
+// PaymentDetailsDTO — реквизиты для выплат агенту: на карту (method=card)
+// или на счёт в банке (method=account). Номера можно передавать с пробелами.
+type PaymentDetailsDTO struct {
+	Method       string `json:"method" validate:"required,oneof=card account"`
+	FullName     string `json:"full_name" validate:"required,max=255"`
+	SelfEmployed bool   `json:"self_employed"`
+	INN          string `json:"inn" validate:"required,inn"`
+	CardNumber   string `json:"card_number" validate:"required_if=Method card,omitempty,card"`
+	Account      string `json:"account" validate:"required_if=Method account"`
+	BIK          string `json:"bik" validate:"required_if=Method account,omitempty,bik"`
+	BankName     string `json:"bank_name" validate:"required_if=Method account,max=255"`
+	CorrAccount  string `json:"corr_account"`
+}
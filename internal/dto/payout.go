@@ -0,0 +1,52 @@
+package dto
+
+import "ia-online-golang/internal/lib/money"
+
+// PayoutRequestDTO — заявка агента на вывод суммы в рублях
+type PayoutRequestDTO struct {
+	Amount  money.Amount `json:"amount" validate:"required,gt=0"`
+	Comment string       `json:"comment" validate:"omitempty,max=1000"`
+}
+
+// PayoutDecisionDTO — комментарий менеджера к одобрению или оплате заявки
+type PayoutDecisionDTO struct {
+	Comment string `json:"comment" validate:"omitempty,max=1000"`
+}
+
+// PayoutRejectDTO — отказ по заявке; причину увидит агент
+type PayoutRejectDTO struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+type PayoutFilterDTO struct {
+	UserID *int64  `json:"user_id"`
+	Status *string `json:"status" validate:"omitempty,oneof=requested approved rejected paid"`
+	Limit  int64   `json:"limit"`
+	Offset int64   `json:"offset"`
+}
+
+// PayoutFundsDTO — сколько агент может вывести и почему не больше
+type PayoutFundsDTO struct {
+	Balance   money.Amount `json:"balance"`
+	Held      money.Amount `json:"held"`
+	Reserved  money.Amount `json:"reserved"`
+	Available money.Amount `json:"available"`
+	MinAmount money.Amount `json:"min_amount"`
+}
+
+// PayoutBatchImportDTO — итог загрузки ответного файла банка по пачке
+type PayoutBatchImportDTO struct {
+	BatchID     int64                  `json:"batch_id"`
+	Paid        []int64                `json:"paid"`
+	AlreadyPaid []int64                `json:"already_paid"`
+	Failed      []PayoutImportIssueDTO `json:"failed"`
+	Skipped     []PayoutImportIssueDTO `json:"skipped"`
+	Completed   bool                   `json:"completed"`
+}
+
+// PayoutImportIssueDTO — заявка, которую банк не оплатил (Failed)
+// или которую не удалось сопоставить с пачкой (Skipped)
+type PayoutImportIssueDTO struct {
+	PayoutID int64  `json:"payout_id"`
+	Reason   string `json:"reason"`
+}
@@ -0,0 +1,23 @@
+package dto
+
+import "time"
+
+// ReconciliationReport — итог сверки лидов со сделками Bitrix
+type ReconciliationReport struct {
+	StartedAt     time.Time      `json:"started_at"`
+	FinishedAt    time.Time      `json:"finished_at"`
+	Total         int            `json:"total"`
+	Fixed         int            `json:"fixed"`
+	Unchanged     int            `json:"unchanged"`
+	Missing       int            `json:"missing"`
+	Unknown       int            `json:"unknown"`
+	Failed        int            `json:"failed"`
+	MissingDeals  []int64        `json:"missing_deals"`
+	UnknownStages map[string]int `json:"unknown_stages"`
+	// Лиды с неотправленными событиями outbox: их сделки не сверялись
+	Pending      int     `json:"pending"`
+	PendingDeals []int64 `json:"pending_deals"`
+	// Лиды, сделок которых нет в воронке Bitrix
+	Lost      int     `json:"lost"`
+	LostLeads []int64 `json:"lost_leads"`
+}
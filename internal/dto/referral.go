@@ -1,9 +1,106 @@
 package dto
 
+import (
+	"ia-online-golang/internal/lib/money"
+	"time"
+)
+
 type ReferralDTO struct {
 	ID          int64  `json:"id"`
 	Name        string `json:"name"`
 	PhoneNumber string `json:"phone_number"`
 	City        string `json:"city"`
 	Active      bool   `json:"active"`
+	// Комиссия, полученная с вознаграждений этого реферала
+	Commission money.Amount `json:"commission"`
+}
+
+// ReferralFilterDTO — страница сети агента. UserID может задать только менеджер,
+// Depth — сколько уровней приглашений вернуть деревом.
+type ReferralFilterDTO struct {
+	UserID *int64
+	Depth  int
+	Limit  int64
+	Offset int64
+}
+
+// ReferralNetworkDTO — сеть агента: итоги по прямым рефералам, условия активации
+// и страница прямых рефералов с вложенными уровнями
+type ReferralNetworkDTO struct {
+	UserID    int64              `json:"user_id"`
+	Total     int64              `json:"total"`
+	Active    int64              `json:"active"`
+	Pending   int64              `json:"pending"`
+	Expired   int64              `json:"expired"`
+	Earned    money.Amount       `json:"earned"`
+	Rule      ReferralRuleDTO    `json:"rule"`
+	Referrals []*ReferralNodeDTO `json:"referrals"`
+}
+
+type ReferralRuleDTO struct {
+	MinLeads  int64        `json:"min_leads"`
+	MinReward money.Amount `json:"min_reward"`
+	Statuses  []string     `json:"statuses"`
+}
+
+// ReferralNodeDTO — реферал в сети. Earned — сколько агент, чью сеть смотрят, получил с него.
+type ReferralNodeDTO struct {
+	ID          int64                `json:"id"`
+	UserID      int64                `json:"user_id"`
+	Name        string               `json:"name"`
+	PhoneNumber string               `json:"phone_number"`
+	City        string               `json:"city"`
+	Level       int                  `json:"level"`
+	Status      string               `json:"status"`
+	CreatedAt   time.Time            `json:"created_at"`
+	ActivatedAt *time.Time           `json:"activated_at"`
+	ExpiredAt   *time.Time           `json:"expired_at"`
+	Leads       int64                `json:"leads"`
+	Earned      money.Amount         `json:"earned"`
+	Progress    *ReferralProgressDTO `json:"progress"`
+	Referrals   []*ReferralNodeDTO   `json:"referrals,omitempty"`
+}
+
+// ReferralProgressDTO — выполнение условий активации; Deadline — до какого времени
+// засчитываются лиды, nil — без срока
+type ReferralProgressDTO struct {
+	Leads     int64        `json:"leads"`
+	MinLeads  int64        `json:"min_leads"`
+	Reward    money.Amount `json:"reward"`
+	MinReward money.Amount `json:"min_reward"`
+	Deadline  *time.Time   `json:"deadline"`
+}
+
+// ReferralClickDTO — переход по ссылке /r/{code}: IP передаётся как есть и хешируется в сервисе
+type ReferralClickDTO struct {
+	Code        string
+	UserAgent   string
+	IP          string
+	UTMSource   string
+	UTMMedium   string
+	UTMCampaign string
+	UTMTerm     string
+	UTMContent  string
+}
+
+// ReferralFunnelFilterDTO — период воронки [From, To). UserID может задать только менеджер.
+type ReferralFunnelFilterDTO struct {
+	UserID *int64
+	From   *time.Time
+	To     *time.Time
+}
+
+// ReferralFunnelDTO — воронка агента: переходы → регистрации → активации → первый оплаченный лид
+type ReferralFunnelDTO struct {
+	UserID      int64  `json:"user_id"`
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+	City        string `json:"city"`
+	Clicks      int64  `json:"clicks"`
+	// Уникальные посетители по хешу IP
+	Visitors           int64 `json:"visitors"`
+	ClickRegistrations int64 `json:"click_registrations"`
+	Registrations      int64 `json:"registrations"`
+	Activated          int64 `json:"activated"`
+	FirstPaidLead      int64 `json:"first_paid_lead"`
 }
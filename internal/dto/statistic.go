@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+// StatisticFilterDTO — период [From, To) и шаг ряда. UserID может задать только менеджер.
+type StatisticFilterDTO struct {
+	UserID   *int64
+	From     *time.Time
+	To       *time.Time
+	Interval string `validate:"omitempty,oneof=day week month"`
+}
+
+// StatisticSeriesDTO — начисления и лиды за период: итоги и ряд по шагам для графиков.
+// UserID пуст, если менеджер смотрит всех агентов.
+type StatisticSeriesDTO struct {
+	UserID   *int64              `json:"user_id"`
+	From     time.Time           `json:"from"`
+	To       time.Time           `json:"to"`
+	Interval string              `json:"interval"`
+	Earnings UserStatistic       `json:"earnings"`
+	Leads    map[string]int64    `json:"leads"`
+	Points   []StatisticPointDTO `json:"points"`
+}
+
+// StatisticPointDTO — шаг ряда: начисления по статьям и лиды, поданные за шаг, по текущему статусу
+type StatisticPointDTO struct {
+	Start    time.Time        `json:"start"`
+	Earnings UserStatistic    `json:"earnings"`
+	Leads    map[string]int64 `json:"leads"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+type StatusDTO struct {
+	ID         *int64           `json:"id" validate:"omitempty"`
+	Name       string           `json:"name" validate:"required"`
+	BitrixName string           `json:"bitrix_name" validate:"required"`
+	Stages     []StatusStageDTO `json:"stages" validate:"dive"`
+}
+
+type StatusStageDTO struct {
+	CategoryID int64  `json:"category_id" validate:"gte=0"`
+	StageCode  string `json:"stage_code" validate:"required"`
+}
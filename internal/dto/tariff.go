@@ -0,0 +1,23 @@
+package dto
+
+import (
+	"ia-online-golang/internal/lib/money"
+	"time"
+)
+
+// TariffDTO — тариф от менеджера; ID обязателен при правке. Без city тариф действует
+// во всех городах, без valid_to — бессрочно.
+type TariffDTO struct {
+	ID        *int64       `json:"id" validate:"omitempty"`
+	Service   string       `json:"service" validate:"required,oneof=internet cleaning shipping referral"`
+	City      *string      `json:"city" validate:"omitempty,min=1,max=100"`
+	Amount    money.Amount `json:"amount" validate:"gte=0"`
+	ValidFrom time.Time    `json:"valid_from" validate:"required"`
+	ValidTo   *time.Time   `json:"valid_to" validate:"omitempty"`
+}
+
+type TariffFilterDTO struct {
+	Service *string    `json:"service" validate:"omitempty,oneof=internet cleaning shipping referral"`
+	City    *string    `json:"city"`
+	At      *time.Time `json:"at"`
+}
@@ -4,9 +4,10 @@ type UserDTO struct {
 	ID             *int64   `json:"id" validate:"omitempty"`
 	Roles          []string `json:"roles" validate:"omitempty"`
 	ReferralCode   string   `json:"referral_code" validate:"omitempty"`
+	VanityCode     *string  `json:"vanity_code"`
 	Email          string   `json:"email" validate:"omitempty"`
 	Name           string   `json:"name" validate:"omitempty"`
-	PhoneNumber    string   `json:"phone_number" validate:"omitempty"`
+	PhoneNumber    string   `json:"phone_number" validate:"omitempty,phone"`
 	Telegram       string   `json:"telegram" validate:"omitempty"`
 	City           string   `json:"city" validate:"omitempty"`
 	RewardInternet float64  `json:"reward_internet" validate:"omitempty"`
@@ -14,3 +15,8 @@ type UserDTO struct {
 	RewardShipping float64  `json:"reward_shipping" validate:"omitempty"`
 	RewardReferral float64  `json:"reward_referral" validate:"omitempty"`
 }
+
+// ReferralCodeDTO — короткий код, который агент выбирает вместо UUID
+type ReferralCodeDTO struct {
+	ReferralCode string `json:"referral_code" validate:"required,referralcode"`
+}
@@ -0,0 +1,79 @@
+package address
+
+import (
+	"encoding/json"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/services/dadata"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Сколько подсказок отдавать по умолчанию и максимум, который разрешает Dadata
+const (
+	defaultSuggestCount = 5
+	maxSuggestCount     = 20
+)
+
+type AddressController struct {
+	log           *logrus.Logger
+	DadataService dadata.DadataServiceI
+}
+
+type AddressControllerI interface {
+	Suggest(w http.ResponseWriter, r *http.Request)
+}
+
+func New(log *logrus.Logger, dadataService dadata.DadataServiceI) *AddressController {
+	return &AddressController{
+		log:           log,
+		DadataService: dadataService,
+	}
+}
+
+// Suggest отдаёт подсказки адреса для формы лида. Если Dadata недоступна,
+// возвращается пустой список, и агент вводит адрес вручную.
+func (c *AddressController) Suggest(w http.ResponseWriter, r *http.Request) {
+	const op = "AddressController.Suggest"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		c.log.Infof("%s: empty query", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	count := defaultSuggestCount
+	if val := r.URL.Query().Get("count"); val != "" {
+		parsed, err := strconv.Atoi(val)
+		if err == nil && parsed > 0 {
+			count = min(parsed, maxSuggestCount)
+		}
+	}
+
+	suggestions, err := c.DadataService.SuggestAddress(r.Context(), query, count)
+	if err != nil {
+		c.log.Warnf("%s: %v", op, err)
+
+		suggestions = []dto.AddressSuggestionDTO{}
+	}
+
+	c.log.Debugf("%s: suggestions send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestions)
+}
@@ -80,6 +80,11 @@ func (a *AuthController) Registration(w http.ResponseWriter, r *http.Request) {
 
 	a.log.Debugf("%s: validation completed", op)
 
+	// Переход по реферальной ссылке, после которого пользователь пришёл регистрироваться
+	if click, err := r.Cookie("referral_click"); err == nil {
+		dto.ClickToken = click.Value
+	}
+
 	// Регистрируем пользователя
 	tokens, err := a.AuthService.RegistrationUser(r.Context(), dto)
 	if err != nil {
@@ -116,6 +121,16 @@ func (a *AuthController) Registration(w http.ResponseWriter, r *http.Request) {
 
 	http.SetCookie(w, cookie)
 
+	// Переход засчитан, cookie больше не нужна
+	http.SetCookie(w, &http.Cookie{
+		Name:     "referral_click",
+		Value:    "",
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+		MaxAge:   -1,
+	})
+
 	a.log.Debugf("%s: tokens send", op)
 
 	w.Header().Set("Content-Type", "application/json")
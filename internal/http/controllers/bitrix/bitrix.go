@@ -1,11 +1,14 @@
 package bitrix
 
 import (
+	"errors"
 	"fmt"
 	"ia-online-golang/internal/dto"
 	"ia-online-golang/internal/http/responses"
 	"ia-online-golang/internal/services/lead"
+	"ia-online-golang/internal/services/status"
 	"net/http"
+	"strconv"
 
 	"github.com/sirupsen/logrus"
 )
@@ -18,6 +21,7 @@ type BitrixController struct {
 
 type BitrixControllerI interface {
 	СhangingDeal(w http.ResponseWriter, r *http.Request)
+	Event(w http.ResponseWriter, r *http.Request)
 }
 
 func New(log *logrus.Logger, bitrix_key string, leadService lead.LeadServiceI) *BitrixController {
@@ -77,6 +81,47 @@ func (c *BitrixController) СhangingDeal(w http.ResponseWriter, r *http.Request)
 	c.log.Debugf("%s: correct member id", op)
 
 	err = c.LeadService.EditDeal(r.Context(), hook.DocumentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, lead.ErrInvalidDealDocument):
+			c.log.Infof("%s: %v", op, err)
+
+			responses.InvalidRequest(w)
+		// Сделки не из нашей воронки и стадии без статуса повторная доставка не исправит:
+		// отвечаем 200, чтобы Bitrix не повторял вебхук
+		case errors.Is(err, lead.ErrLeadNotFound), errors.Is(err, status.ErrStageNotMapped):
+			c.log.Infof("%s: deal skipped: %v", op, err)
+
+			responses.Ok(w)
+		default:
+			c.log.Errorf("%s: %v", op, err)
+
+			responses.ServerError(w)
+		}
+		return
+	}
+
+	c.log.Debugf("%s: deal changed", op)
+
+	responses.Ok(w)
+}
+
+// Event принимает события REST Bitrix; сейчас обрабатывается только
+// ONCRMTIMELINECOMMENTADD — новый комментарий в таймлайне сделки
+func (c *BitrixController) Event(w http.ResponseWriter, r *http.Request) {
+	const op = "BitrixController.Event"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodPost {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPost)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	err := r.ParseForm()
 	if err != nil {
 		c.log.Errorf("%s: %v", op, err)
 
@@ -84,7 +129,37 @@ func (c *BitrixController) СhangingDeal(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	c.log.Debugf("%s: deal changed", op)
+	if r.FormValue("auth[member_id]") != c.bitrixKey {
+		c.log.Infof("%s: invalid member id", op)
+
+		responses.Forbidden(w)
+		return
+	}
+
+	event := r.FormValue("event")
+
+	c.log.Debugf("%s: event %s", op, event)
+
+	switch event {
+	case "ONCRMTIMELINECOMMENTADD":
+		commentID, err := strconv.ParseInt(r.FormValue("data[FIELDS][ID]"), 10, 64)
+		if err != nil {
+			c.log.Infof("%s: invalid comment id", op)
+
+			responses.InvalidRequest(w)
+			return
+		}
+
+		err = c.LeadService.ImportBitrixComment(r.Context(), commentID)
+		if err != nil {
+			c.log.Errorf("%s: %v", op, err)
+
+			responses.ServerError(w)
+			return
+		}
+	default:
+		c.log.Infof("%s: event %s is not handled", op, event)
+	}
 
 	responses.Ok(w)
 }
@@ -2,6 +2,7 @@ package leads
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"ia-online-golang/internal/dto"
 	"ia-online-golang/internal/http/context_keys"
@@ -10,6 +11,7 @@ import (
 	"ia-online-golang/internal/utils"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -25,6 +27,7 @@ type LeadController struct {
 type LeadControllerI interface {
 	SaveLead(w http.ResponseWriter, r *http.Request)
 	Leads(w http.ResponseWriter, r *http.Request)
+	Lead(w http.ResponseWriter, r *http.Request)
 }
 
 func New(log *logrus.Logger, validator *validator.Validate, leadService lead.LeadServiceI) *LeadController {
@@ -48,8 +51,8 @@ func (c *LeadController) SaveLead(w http.ResponseWriter, r *http.Request) {
 
 	c.log.Debugf("%s: method id correct", op)
 
-	var lead dto.LeadDTO
-	if err := json.NewDecoder(r.Body).Decode(&lead); err != nil {
+	var leadDTO dto.LeadDTO
+	if err := json.NewDecoder(r.Body).Decode(&leadDTO); err != nil {
 		c.log.Infof("%s: decode error", op)
 
 		responses.InvalidRequest(w)
@@ -59,7 +62,7 @@ func (c *LeadController) SaveLead(w http.ResponseWriter, r *http.Request) {
 	c.log.Debugf("%s: decode completed", op)
 
 	// Валидируем данные
-	if err := c.validator.Struct(lead); err != nil {
+	if err := c.validator.Struct(leadDTO); err != nil {
 		c.log.Infof("%s: validation error", op)
 
 		responses.ValidationError(w, utils.FormatValidationErrors(err))
@@ -68,8 +71,16 @@ func (c *LeadController) SaveLead(w http.ResponseWriter, r *http.Request) {
 
 	c.log.Debugf("%s: validation completed", op)
 
-	err := c.LeadService.SaveLead(r.Context(), lead)
+	err := c.LeadService.SaveLead(r.Context(), leadDTO)
 	if err != nil {
+		var duplicateErr *lead.DuplicateError
+		if errors.As(err, &duplicateErr) {
+			c.log.Infof("%s: %v", op, err)
+
+			responses.LeadDuplicate(w, duplicateErr.Duplicate)
+			return
+		}
+
 		c.log.Errorf("%s: %v", op, err)
 
 		responses.ServerError(w)
@@ -97,9 +108,16 @@ func (c *LeadController) Leads(w http.ResponseWriter, r *http.Request) {
 	// Парсим фильтры
 	filter, err := parseLeadFilters(r)
 	if err != nil {
-		c.log.Errorf("%s: %v", op, err)
+		c.log.Infof("%s: %v", op, err)
 
-		responses.ServerError(w)
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(filter); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
 		return
 	}
 
@@ -128,6 +146,13 @@ func (c *LeadController) Leads(w http.ResponseWriter, r *http.Request) {
 	// Вызов сервиса
 	leads, err := c.LeadService.Leads(r.Context(), filter)
 	if err != nil {
+		if errors.Is(err, lead.ErrInvalidCursor) {
+			c.log.Infof("%s: %v", op, err)
+
+			responses.InvalidRequest(w)
+			return
+		}
+
 		c.log.Errorf("%s: %v", op, err)
 
 		responses.ServerError(w)
@@ -140,6 +165,238 @@ func (c *LeadController) Leads(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(leads)
 }
 
+// Lead обслуживает маршруты вида /api/v1/lead/{id}/{action}
+func (c *LeadController) Lead(w http.ResponseWriter, r *http.Request) {
+	const op = "LeadController.Lead"
+
+	c.log.Debugf("%s: start", op)
+
+	leadID, action, err := parseLeadPath(r.URL.Path)
+	if err != nil {
+		c.log.Infof("%s: %v", op, err)
+
+		utils.HandleNotFound(w, r)
+		return
+	}
+
+	switch action {
+	case "":
+		c.lead(w, r, leadID)
+	case "cancel":
+		c.cancelLead(w, r, leadID)
+	case "comments":
+		c.comments(w, r, leadID)
+	case "history":
+		c.leadHistory(w, r, leadID)
+	default:
+		utils.HandleNotFound(w, r)
+	}
+}
+
+// lead отдаёт лид (GET) или меняет его данные (PATCH)
+func (c *LeadController) lead(w http.ResponseWriter, r *http.Request, leadID int64) {
+	const op = "LeadController.lead"
+
+	switch r.Method {
+	case http.MethodGet:
+		lead, err := c.LeadService.Lead(r.Context(), leadID)
+		if err != nil {
+			c.handleLeadError(w, op, err)
+			return
+		}
+
+		c.log.Debugf("%s: lead send", op)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lead)
+	case http.MethodPatch:
+		c.editLead(w, r, leadID)
+	default:
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPatch)
+		responses.MethodNotAllowed(w)
+	}
+}
+
+func (c *LeadController) editLead(w http.ResponseWriter, r *http.Request, leadID int64) {
+	const op = "LeadController.editLead"
+
+	var editDTO dto.LeadEditDTO
+	if err := json.NewDecoder(r.Body).Decode(&editDTO); err != nil {
+		c.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(editDTO); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	c.log.Debugf("%s: validation completed", op)
+
+	err := c.LeadService.EditLead(r.Context(), leadID, editDTO)
+	if err != nil {
+		c.handleLeadError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: lead updated", op)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *LeadController) cancelLead(w http.ResponseWriter, r *http.Request, leadID int64) {
+	const op = "LeadController.cancelLead"
+
+	if r.Method != http.MethodPost {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPost)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	err := c.LeadService.CancelLead(r.Context(), leadID)
+	if err != nil {
+		c.handleLeadError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: lead canceled", op)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// comments отдаёт переписку по лиду (GET) или добавляет комментарий (POST)
+func (c *LeadController) comments(w http.ResponseWriter, r *http.Request, leadID int64) {
+	const op = "LeadController.comments"
+
+	switch r.Method {
+	case http.MethodGet:
+		comments, err := c.LeadService.Comments(r.Context(), leadID)
+		if err != nil {
+			c.handleLeadError(w, op, err)
+			return
+		}
+
+		c.log.Debugf("%s: comments send", op)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(comments)
+	case http.MethodPost:
+		var commentDTO dto.CommentDTO
+		if err := json.NewDecoder(r.Body).Decode(&commentDTO); err != nil {
+			c.log.Infof("%s: decode error", op)
+
+			responses.InvalidRequest(w)
+			return
+		}
+
+		if err := c.validator.Struct(commentDTO); err != nil {
+			c.log.Infof("%s: validation error", op)
+
+			responses.ValidationError(w, utils.FormatValidationErrors(err))
+			return
+		}
+
+		c.log.Debugf("%s: validation completed", op)
+
+		comment, err := c.LeadService.AddComment(r.Context(), leadID, commentDTO)
+		if err != nil {
+			c.handleLeadError(w, op, err)
+			return
+		}
+
+		c.log.Debugf("%s: comment created", op)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(comment)
+	default:
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		responses.MethodNotAllowed(w)
+	}
+}
+
+func (c *LeadController) leadHistory(w http.ResponseWriter, r *http.Request, leadID int64) {
+	const op = "LeadController.leadHistory"
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	history, err := c.LeadService.LeadHistory(r.Context(), leadID)
+	if err != nil {
+		c.handleLeadError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: history send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// handleLeadError переводит ошибки сервиса лидов в HTTP-ответы
+func (c *LeadController) handleLeadError(w http.ResponseWriter, op string, err error) {
+	var duplicateErr *lead.DuplicateError
+
+	switch {
+	case errors.As(err, &duplicateErr):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.LeadDuplicate(w, duplicateErr.Duplicate)
+	case errors.Is(err, lead.ErrLeadNotFound):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.LeadNotFound(w)
+	case errors.Is(err, lead.ErrLeadForbidden):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.Forbidden(w)
+	case errors.Is(err, lead.ErrLeadNotEditable):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.LeadNotEditable(w)
+	case errors.Is(err, lead.ErrInvalidTransition):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.LeadInvalidTransition(w)
+	case errors.Is(err, lead.ErrLeadNoServices):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.ValidationError(w, err.Error())
+	default:
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+	}
+}
+
+// parseLeadPath разбирает /api/v1/lead/{id}[/{action}]
+func parseLeadPath(path string) (int64, string, error) {
+	rest := strings.TrimPrefix(path, "/api/v1/lead/")
+	idStr, action, _ := strings.Cut(rest, "/")
+
+	leadID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid lead id %q", idStr)
+	}
+
+	return leadID, strings.Trim(action, "/"), nil
+}
+
 func parseLeadFilters(r *http.Request) (dto.LeadFilterDTO, error) {
 	query := r.URL.Query()
 
@@ -236,6 +493,16 @@ func parseLeadFilters(r *http.Request) (dto.LeadFilterDTO, error) {
 
 	search := parseString("search")
 
+	// По умолчанию комментарии включены, как и раньше
+	withComments := true
+	if val := query.Get("with_comments"); val != "" {
+		parsed, err := strconv.ParseBool(val)
+		if err != nil {
+			return dto.LeadFilterDTO{}, fmt.Errorf("invalid with_comments")
+		}
+		withComments = parsed
+	}
+
 	return dto.LeadFilterDTO{
 		StatusID:   statusID,
 		UserID:     userID,
@@ -247,5 +514,10 @@ func parseLeadFilters(r *http.Request) (dto.LeadFilterDTO, error) {
 		IsShipping: isShipping,
 		IsCleaning: isCleaning,
 		Search:     search,
+
+		WithComments: withComments,
+		Sort:         query.Get("sort"),
+		Order:        query.Get("order"),
+		Cursor:       query.Get("cursor"),
 	}, nil
 }
@@ -0,0 +1,232 @@
+package ledger
+
+import (
+	"encoding/json"
+	"errors"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/services/ledger"
+	"ia-online-golang/internal/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// Размер страницы движений по умолчанию и максимальный
+const (
+	defaultEntriesLimit = 20
+	maxEntriesLimit     = 100
+)
+
+var (
+	errInvalidUserID = errors.New("invalid user_id")
+	errForbidden     = errors.New("user_id is available only to managers")
+)
+
+type LedgerController struct {
+	log           *logrus.Logger
+	validator     *validator.Validate
+	LedgerService ledger.LedgerServiceI
+}
+
+type LedgerControllerI interface {
+	Balance(w http.ResponseWriter, r *http.Request)
+	Entries(w http.ResponseWriter, r *http.Request)
+	Adjust(w http.ResponseWriter, r *http.Request)
+}
+
+func New(log *logrus.Logger, validator *validator.Validate, ledgerService ledger.LedgerServiceI) *LedgerController {
+	return &LedgerController{
+		log:           log,
+		validator:     validator,
+		LedgerService: ledgerService,
+	}
+}
+
+// Balance отдаёт баланс текущего агента; менеджер может запросить чужой через ?user_id=
+func (c *LedgerController) Balance(w http.ResponseWriter, r *http.Request) {
+	const op = "LedgerController.Balance"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	userID, err := targetUserID(r)
+	if err != nil {
+		c.handleTargetError(w, op, err)
+		return
+	}
+
+	balance, err := c.LedgerService.Balance(r.Context(), userID)
+	if err != nil {
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: balance send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(balance)
+}
+
+// Entries отдаёт движения по балансу агента постранично: ?limit=&offset=
+func (c *LedgerController) Entries(w http.ResponseWriter, r *http.Request) {
+	const op = "LedgerController.Entries"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	userID, err := targetUserID(r)
+	if err != nil {
+		c.handleTargetError(w, op, err)
+		return
+	}
+
+	limit := int64(defaultEntriesLimit)
+	if val := r.URL.Query().Get("limit"); val != "" {
+		parsed, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || parsed <= 0 {
+			c.log.Infof("%s: invalid limit", op)
+
+			responses.InvalidRequest(w)
+			return
+		}
+		limit = min(parsed, maxEntriesLimit)
+	}
+
+	offset := int64(0)
+	if val := r.URL.Query().Get("offset"); val != "" {
+		parsed, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || parsed < 0 {
+			c.log.Infof("%s: invalid offset", op)
+
+			responses.InvalidRequest(w)
+			return
+		}
+		offset = parsed
+	}
+
+	entries, err := c.LedgerService.Entries(r.Context(), userID, limit, offset)
+	if err != nil {
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: entries send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// Adjust проводит ручную корректировку баланса агента
+func (c *LedgerController) Adjust(w http.ResponseWriter, r *http.Request) {
+	const op = "LedgerController.Adjust"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodPost {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPost)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	var adjustmentDTO dto.LedgerAdjustmentDTO
+	if err := json.NewDecoder(r.Body).Decode(&adjustmentDTO); err != nil {
+		c.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(adjustmentDTO); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	c.log.Debugf("%s: validation completed", op)
+
+	transaction, err := c.LedgerService.Adjust(r.Context(), adjustmentDTO)
+	if err != nil {
+		if errors.Is(err, ledger.ErrZeroAmount) {
+			c.log.Infof("%s: %v", op, err)
+
+			responses.InvalidRequest(w)
+			return
+		}
+
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: adjustment saved", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transaction)
+}
+
+// targetUserID возвращает агента из ?user_id= (только для менеджера) или текущего пользователя
+func targetUserID(r *http.Request) (int64, error) {
+	if val := r.URL.Query().Get("user_id"); val != "" {
+		roles, _ := r.Context().Value(context_keys.UserRoleKey).([]string)
+		if !utils.Contains(roles, "manager") {
+			return 0, errForbidden
+		}
+
+		userID, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return 0, errInvalidUserID
+		}
+		return userID, nil
+	}
+
+	userID, ok := r.Context().Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return 0, errors.New("error receiving userID")
+	}
+
+	return userID, nil
+}
+
+func (c *LedgerController) handleTargetError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, errForbidden):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.Forbidden(w)
+	case errors.Is(err, errInvalidUserID):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.InvalidRequest(w)
+	default:
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+	}
+}
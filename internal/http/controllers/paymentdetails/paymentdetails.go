@@ -0,0 +1,138 @@
+package paymentdetails
+
+import (
+	"encoding/json"
+	"errors"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/services/paymentdetails"
+	"ia-online-golang/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+type PaymentDetailsController struct {
+	log                   *logrus.Logger
+	validator             *validator.Validate
+	PaymentDetailsService paymentdetails.PaymentDetailsServiceI
+}
+
+type PaymentDetailsControllerI interface {
+	PaymentDetails(w http.ResponseWriter, r *http.Request)
+}
+
+func New(log *logrus.Logger, validator *validator.Validate, paymentDetailsService paymentdetails.PaymentDetailsServiceI) *PaymentDetailsController {
+	return &PaymentDetailsController{
+		log:                   log,
+		validator:             validator,
+		PaymentDetailsService: paymentDetailsService,
+	}
+}
+
+// PaymentDetails обрабатывает /api/v1/user/payment_details: GET отдаёт реквизиты
+// (менеджер и финансы могут указать ?user_id=), PUT сохраняет реквизиты текущего пользователя
+func (c *PaymentDetailsController) PaymentDetails(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		c.paymentDetails(w, r)
+	case http.MethodPut:
+		c.savePaymentDetails(w, r)
+	default:
+		c.log.Infof("PaymentDetailsController.PaymentDetails: method not allowed. method: %s", r.Method)
+
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut}, ", "))
+		responses.MethodNotAllowed(w)
+	}
+}
+
+func (c *PaymentDetailsController) paymentDetails(w http.ResponseWriter, r *http.Request) {
+	const op = "PaymentDetailsController.paymentDetails"
+
+	c.log.Debugf("%s: start", op)
+
+	userID, ok := r.Context().Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		c.log.Errorf("%s: error receiving userID", op)
+
+		responses.ServerError(w)
+		return
+	}
+
+	if val := r.URL.Query().Get("user_id"); val != "" {
+		parsed, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			c.log.Infof("%s: invalid user_id", op)
+
+			responses.InvalidRequest(w)
+			return
+		}
+		userID = parsed
+	}
+
+	details, err := c.PaymentDetailsService.PaymentDetails(r.Context(), userID)
+	if err != nil {
+		c.handlePaymentDetailsError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: payment details send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(details)
+}
+
+func (c *PaymentDetailsController) savePaymentDetails(w http.ResponseWriter, r *http.Request) {
+	const op = "PaymentDetailsController.savePaymentDetails"
+
+	c.log.Debugf("%s: start", op)
+
+	var detailsDTO dto.PaymentDetailsDTO
+	if err := json.NewDecoder(r.Body).Decode(&detailsDTO); err != nil {
+		c.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(detailsDTO); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	c.log.Debugf("%s: validation completed", op)
+
+	details, err := c.PaymentDetailsService.SavePaymentDetails(r.Context(), detailsDTO)
+	if err != nil {
+		c.handlePaymentDetailsError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: payment details saved", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(details)
+}
+
+func (c *PaymentDetailsController) handlePaymentDetailsError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, paymentdetails.ErrPaymentDetailsNotFound):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.PaymentDetailsNotFound(w)
+	case errors.Is(err, paymentdetails.ErrPaymentDetailsForbidden):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.Forbidden(w)
+	default:
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+	}
+}
@@ -0,0 +1,250 @@
+package payout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/lib/registry"
+	"ia-online-golang/internal/services/payout"
+	"ia-online-golang/internal/utils"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Формат реестра, если ?format= не задан
+	defaultRegistryFormat = "csv"
+	// Максимальный размер ответного файла банка
+	maxConfirmationSize = 10 << 20
+)
+
+// Batches отдаёт пачки выплат постранично: ?limit=&offset=
+func (c *PayoutController) Batches(w http.ResponseWriter, r *http.Request) {
+	const op = "PayoutController.Batches"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	filter, err := parsePayoutFilter(r)
+	if err != nil {
+		c.log.Infof("%s: %v", op, err)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	batches, err := c.PayoutService.Batches(r.Context(), filter.Limit, filter.Offset)
+	if err != nil {
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: batches send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batches)
+}
+
+// CreateBatch собирает одобренные заявки в новую пачку
+func (c *PayoutController) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	const op = "PayoutController.CreateBatch"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodPost {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPost)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	batch, err := c.PayoutService.CreateBatch(r.Context())
+	if err != nil {
+		c.handleBatchError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: batch %d created", op, batch.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(batch)
+}
+
+// Batch обрабатывает /api/v1/payout_batch/{id}[/{action}]: просмотр пачки,
+// выгрузку реестра (export) и загрузку ответа банка (confirmation)
+func (c *PayoutController) Batch(w http.ResponseWriter, r *http.Request) {
+	const op = "PayoutController.Batch"
+
+	c.log.Debugf("%s: start", op)
+
+	batchID, action, err := parseBatchPath(r.URL.Path)
+	if err != nil {
+		c.log.Infof("%s: %v", op, err)
+
+		utils.HandleNotFound(w, r)
+		return
+	}
+
+	switch action {
+	case "":
+		c.batch(w, r, batchID)
+	case "export":
+		c.exportBatch(w, r, batchID)
+	case "confirmation":
+		c.importConfirmation(w, r, batchID)
+	default:
+		utils.HandleNotFound(w, r)
+	}
+}
+
+func (c *PayoutController) batch(w http.ResponseWriter, r *http.Request, batchID int64) {
+	const op = "PayoutController.batch"
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	batch, err := c.PayoutService.Batch(r.Context(), batchID)
+	if err != nil {
+		c.handleBatchError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: batch send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// exportBatch отдаёт реестр пачки файлом: ?format=csv|1c
+func (c *PayoutController) exportBatch(w http.ResponseWriter, r *http.Request, batchID int64) {
+	const op = "PayoutController.exportBatch"
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	file, err := c.PayoutService.ExportBatch(r.Context(), batchID, registryFormat(r))
+	if err != nil {
+		c.handleBatchError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: registry %s send", op, file.Name)
+
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(file.Data)))
+	w.Write(file.Data)
+}
+
+// importConfirmation принимает ответный файл банка: ?format=csv|1c, файл — телом запроса
+// или полем file формы multipart/form-data
+func (c *PayoutController) importConfirmation(w http.ResponseWriter, r *http.Request, batchID int64) {
+	const op = "PayoutController.importConfirmation"
+
+	if r.Method != http.MethodPost {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPost)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxConfirmationSize)
+
+	var file io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		formFile, _, err := r.FormFile("file")
+		if err != nil {
+			c.log.Infof("%s: %v", op, err)
+
+			responses.InvalidRequest(w)
+			return
+		}
+		defer formFile.Close()
+
+		file = formFile
+	}
+
+	result, err := c.PayoutService.ImportConfirmation(r.Context(), batchID, registryFormat(r), file)
+	if err != nil {
+		c.handleBatchError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: confirmation imported", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (c *PayoutController) handleBatchError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, payout.ErrPayoutBatchNotFound):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.PayoutBatchNotFound(w)
+	case errors.Is(err, payout.ErrPayoutBatchEmpty):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.PayoutBatchEmpty(w)
+	case errors.Is(err, payout.ErrUnknownFormat):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.ValidationError(w, fmt.Sprintf("format must be one of: %s", strings.Join(registry.Names(), ", ")))
+	case errors.Is(err, payout.ErrPaymentDetailsMissing), errors.Is(err, payout.ErrUnsupportedPayment):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.PayoutBatchNotExportable(w, err.Error())
+	case errors.Is(err, payout.ErrInvalidConfirmation):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.ValidationError(w, err.Error())
+	default:
+		c.handlePayoutError(w, op, err)
+	}
+}
+
+func registryFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	return defaultRegistryFormat
+}
+
+// parseBatchPath разбирает /api/v1/payout_batch/{id}[/{action}]
+func parseBatchPath(path string) (int64, string, error) {
+	rest := strings.TrimPrefix(path, "/api/v1/payout_batch/")
+	idStr, action, _ := strings.Cut(rest, "/")
+
+	batchID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid payout batch id %q", idStr)
+	}
+
+	return batchID, strings.Trim(action, "/"), nil
+}
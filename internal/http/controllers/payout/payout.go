@@ -0,0 +1,381 @@
+package payout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/services/payout"
+	"ia-online-golang/internal/utils"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// Размер страницы заявок по умолчанию и максимальный
+const (
+	defaultPayoutsLimit = 20
+	maxPayoutsLimit     = 100
+)
+
+type PayoutController struct {
+	log           *logrus.Logger
+	validator     *validator.Validate
+	PayoutService payout.PayoutServiceI
+}
+
+type PayoutControllerI interface {
+	Payouts(w http.ResponseWriter, r *http.Request)
+	RequestPayout(w http.ResponseWriter, r *http.Request)
+	Funds(w http.ResponseWriter, r *http.Request)
+	Payout(w http.ResponseWriter, r *http.Request)
+	Batches(w http.ResponseWriter, r *http.Request)
+	CreateBatch(w http.ResponseWriter, r *http.Request)
+	Batch(w http.ResponseWriter, r *http.Request)
+}
+
+func New(log *logrus.Logger, validator *validator.Validate, payoutService payout.PayoutServiceI) *PayoutController {
+	return &PayoutController{
+		log:           log,
+		validator:     validator,
+		PayoutService: payoutService,
+	}
+}
+
+// Payouts отдаёт заявки на выплату: ?user_id= (для менеджера), ?status=, ?limit=, ?offset=
+func (c *PayoutController) Payouts(w http.ResponseWriter, r *http.Request) {
+	const op = "PayoutController.Payouts"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	filter, err := parsePayoutFilter(r)
+	if err != nil {
+		c.log.Infof("%s: %v", op, err)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(filter); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	payouts, err := c.PayoutService.Payouts(r.Context(), filter)
+	if err != nil {
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: payouts send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payouts)
+}
+
+// RequestPayout принимает заявку агента на вывод
+func (c *PayoutController) RequestPayout(w http.ResponseWriter, r *http.Request) {
+	const op = "PayoutController.RequestPayout"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodPost {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPost)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	var requestDTO dto.PayoutRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&requestDTO); err != nil {
+		c.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(requestDTO); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	c.log.Debugf("%s: validation completed", op)
+
+	created, err := c.PayoutService.RequestPayout(r.Context(), requestDTO)
+	if err != nil {
+		c.handlePayoutError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: payout requested", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// Funds отдаёт баланс агента, удержание и сумму, доступную к выводу
+func (c *PayoutController) Funds(w http.ResponseWriter, r *http.Request) {
+	const op = "PayoutController.Funds"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	funds, err := c.PayoutService.Funds(r.Context())
+	if err != nil {
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: funds send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(funds)
+}
+
+// Payout обрабатывает /api/v1/payout/{id}[/{action}]: просмотр заявки
+// и решения менеджера approve, reject, paid
+func (c *PayoutController) Payout(w http.ResponseWriter, r *http.Request) {
+	const op = "PayoutController.Payout"
+
+	c.log.Debugf("%s: start", op)
+
+	payoutID, action, err := parsePayoutPath(r.URL.Path)
+	if err != nil {
+		c.log.Infof("%s: %v", op, err)
+
+		utils.HandleNotFound(w, r)
+		return
+	}
+
+	if action == "" {
+		c.payout(w, r, payoutID)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPost)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	switch action {
+	case "approve":
+		c.decide(w, r, payoutID, c.PayoutService.ApprovePayout)
+	case "paid":
+		c.decide(w, r, payoutID, c.PayoutService.MarkPayoutPaid)
+	case "reject":
+		c.reject(w, r, payoutID)
+	default:
+		utils.HandleNotFound(w, r)
+	}
+}
+
+func (c *PayoutController) payout(w http.ResponseWriter, r *http.Request, payoutID int64) {
+	const op = "PayoutController.payout"
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	found, err := c.PayoutService.Payout(r.Context(), payoutID)
+	if err != nil {
+		c.handlePayoutError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: payout send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(found)
+}
+
+type decisionFunc func(ctx context.Context, id int64, decisionDTO dto.PayoutDecisionDTO) (models.Payout, error)
+
+// decide применяет одобрение или оплату; тело запроса с комментарием необязательно
+func (c *PayoutController) decide(w http.ResponseWriter, r *http.Request, payoutID int64, apply decisionFunc) {
+	const op = "PayoutController.decide"
+
+	var decisionDTO dto.PayoutDecisionDTO
+	if err := json.NewDecoder(r.Body).Decode(&decisionDTO); err != nil && !errors.Is(err, io.EOF) {
+		c.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(decisionDTO); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	updated, err := apply(r.Context(), payoutID, decisionDTO)
+	if err != nil {
+		c.handlePayoutError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: payout %d is %s", op, updated.ID, updated.Status)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (c *PayoutController) reject(w http.ResponseWriter, r *http.Request, payoutID int64) {
+	const op = "PayoutController.reject"
+
+	var rejectDTO dto.PayoutRejectDTO
+	if err := json.NewDecoder(r.Body).Decode(&rejectDTO); err != nil {
+		c.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(rejectDTO); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	updated, err := c.PayoutService.RejectPayout(r.Context(), payoutID, rejectDTO)
+	if err != nil {
+		c.handlePayoutError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: payout rejected", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (c *PayoutController) handlePayoutError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, payout.ErrPayoutNotFound):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.PayoutNotFound(w)
+	case errors.Is(err, payout.ErrPayoutForbidden):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.Forbidden(w)
+	case errors.Is(err, payout.ErrPayoutExists):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.PayoutAlreadyRequested(w)
+	case errors.Is(err, payout.ErrPayoutInsufficientFunds):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.PayoutInsufficientFunds(w)
+	case errors.Is(err, payout.ErrPayoutInvalidStatus):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.PayoutInvalidStatus(w)
+	case errors.Is(err, payout.ErrPayoutExported):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.PayoutExported(w)
+	case errors.Is(err, payout.ErrPaymentDetailsRequired):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.PaymentDetailsRequired(w)
+	case errors.Is(err, payout.ErrPayoutBelowMinimum):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.ValidationError(w, err.Error())
+	default:
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+	}
+}
+
+func parsePayoutFilter(r *http.Request) (dto.PayoutFilterDTO, error) {
+	query := r.URL.Query()
+
+	filter := dto.PayoutFilterDTO{Limit: defaultPayoutsLimit}
+
+	if val := query.Get("user_id"); val != "" {
+		userID, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user_id")
+		}
+		filter.UserID = &userID
+	}
+
+	if val := query.Get("status"); val != "" {
+		filter.Status = &val
+	}
+
+	if val := query.Get("limit"); val != "" {
+		limit, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || limit <= 0 {
+			return filter, fmt.Errorf("invalid limit")
+		}
+		filter.Limit = min(limit, maxPayoutsLimit)
+	}
+
+	if val := query.Get("offset"); val != "" {
+		offset, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || offset < 0 {
+			return filter, fmt.Errorf("invalid offset")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
+
+// parsePayoutPath разбирает /api/v1/payout/{id}[/{action}]
+func parsePayoutPath(path string) (int64, string, error) {
+	rest := strings.TrimPrefix(path, "/api/v1/payout/")
+	idStr, action, _ := strings.Cut(rest, "/")
+
+	payoutID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid payout id %q", idStr)
+	}
+
+	return payoutID, strings.Trim(action, "/"), nil
+}
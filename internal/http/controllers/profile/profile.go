@@ -0,0 +1,161 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/services/lead"
+	"ia-online-golang/internal/services/user"
+	"ia-online-golang/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProfileController отдаёт данные текущего пользователя, которые раньше
+// приходили внутри access-токена
+type ProfileController struct {
+	log         *logrus.Logger
+	UserService user.UserServiceI
+	LeadService lead.LeadServiceI
+}
+
+type ProfileControllerI interface {
+	Me(w http.ResponseWriter, r *http.Request)
+	Statistics(w http.ResponseWriter, r *http.Request)
+}
+
+func New(log *logrus.Logger, userService user.UserServiceI, leadService lead.LeadServiceI) *ProfileController {
+	return &ProfileController{
+		log:         log,
+		UserService: userService,
+		LeadService: leadService,
+	}
+}
+
+// Me отдаёт профиль текущего пользователя
+func (c *ProfileController) Me(w http.ResponseWriter, r *http.Request) {
+	const op = "ProfileController.Me"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	userID, ok := r.Context().Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		c.log.Errorf("%s: user id not received", op)
+
+		responses.ServerError(w)
+		return
+	}
+
+	profile, err := c.UserService.UserById(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			c.log.Infof("%s: %v", op, err)
+
+			responses.UserNotFound(w)
+			return
+		}
+
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: profile send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// Statistics отдаёт начисления текущего агента за период: ?from=&to= (дата 2006-01-02
+// или время RFC 3339; дата в to входит в период целиком). Без параметров — с начала месяца.
+func (c *ProfileController) Statistics(w http.ResponseWriter, r *http.Request) {
+	const op = "ProfileController.Statistics"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	userID, ok := r.Context().Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		c.log.Errorf("%s: user id not received", op)
+
+		responses.ServerError(w)
+		return
+	}
+
+	period, err := parsePeriod(r)
+	if err != nil {
+		c.log.Infof("%s: %v", op, err)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	statistic, err := c.LeadService.GetUserPaymentStatistic(r.Context(), userID, period.From, period.To)
+	if err != nil {
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+	period.UserStatistic = statistic
+
+	c.log.Debugf("%s: statistics send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(period)
+}
+
+func parsePeriod(r *http.Request) (dto.StatisticPeriodDTO, error) {
+	query := r.URL.Query()
+
+	var period dto.StatisticPeriodDTO
+
+	if val := query.Get("from"); val != "" {
+		from, err := utils.ParsePeriodBound(val, false)
+		if err != nil {
+			return period, fmt.Errorf("invalid from")
+		}
+		period.From = &from
+	}
+
+	if val := query.Get("to"); val != "" {
+		to, err := utils.ParsePeriodBound(val, true)
+		if err != nil {
+			return period, fmt.Errorf("invalid to")
+		}
+		period.To = &to
+	}
+
+	if period.From == nil && period.To == nil {
+		now := time.Now()
+		firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		period.From = &firstOfMonth
+	}
+
+	if period.From != nil && period.To != nil && !period.To.After(*period.From) {
+		return period, fmt.Errorf("to must be later than from")
+	}
+
+	return period, nil
+}
@@ -0,0 +1,209 @@
+package referral
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/services/referral"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UTM-метки, которые сохраняются с переходом и передаются дальше на лендинг
+var utmParams = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"}
+
+// Click обрабатывает переход по реферальной ссылке /r/{code}: записывает его, ставит cookie
+// с токеном перехода и перенаправляет на лендинг. По неизвестному коду переход не пишется,
+// но посетитель всё равно попадает на лендинг.
+func (c *ReferralController) Click(w http.ResponseWriter, r *http.Request) {
+	const op = "ReferralController.Click"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodHead)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	code := strings.Trim(strings.TrimPrefix(r.URL.Path, "/r/"), "/")
+	query := r.URL.Query()
+
+	clickDTO := dto.ReferralClickDTO{
+		Code:        code,
+		UserAgent:   r.UserAgent(),
+		IP:          c.clientIP(r),
+		UTMSource:   query.Get("utm_source"),
+		UTMMedium:   query.Get("utm_medium"),
+		UTMCampaign: query.Get("utm_campaign"),
+		UTMTerm:     query.Get("utm_term"),
+		UTMContent:  query.Get("utm_content"),
+	}
+
+	click, err := c.ReferralService.RecordClick(r.Context(), clickDTO)
+	switch {
+	case err == nil:
+		http.SetCookie(w, &http.Cookie{
+			Name:     "referral_click",
+			Value:    click.Token,
+			HttpOnly: true,
+			Secure:   true,
+			Path:     "/",
+			MaxAge:   int(c.cfg.AttributionTTL / time.Second),
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		c.log.Debugf("%s: click %d recorded", op, click.ID)
+	case errors.Is(err, referral.ErrReferralCodeNotFound):
+		c.log.Infof("%s: unknown referral code %q", op, code)
+		code = ""
+	default:
+		c.log.Errorf("%s: %v", op, err)
+	}
+
+	http.Redirect(w, r, c.landingURL(code, query), http.StatusFound)
+}
+
+// Funnel отдаёт воронку агентов: ?user_id= (для менеджера), ?from=, ?to=
+// (дата 2006-01-02 или время RFC 3339; дата в to входит в период целиком)
+func (c *ReferralController) Funnel(w http.ResponseWriter, r *http.Request) {
+	const op = "ReferralController.Funnel"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	filter, err := parseFunnelFilter(r)
+	if err != nil {
+		c.log.Infof("%s: %v", op, err)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	funnels, err := c.ReferralService.Funnel(r.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, referral.ErrReferralForbidden):
+			c.log.Infof("%s: %v", op, err)
+
+			responses.Forbidden(w)
+		default:
+			c.log.Errorf("%s: %v", op, err)
+
+			responses.ServerError(w)
+		}
+		return
+	}
+
+	c.log.Debugf("%s: funnel send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(funnels)
+}
+
+// clientIP возвращает адрес посетителя. Заголовкам прокси верим только при clicks.trust_proxy.
+func (c *ReferralController) clientIP(r *http.Request) string {
+	if c.cfg.TrustProxy {
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(ip)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// landingURL добавляет к адресу лендинга код агента и UTM-метки перехода
+func (c *ReferralController) landingURL(code string, query url.Values) string {
+	landing, err := url.Parse(c.cfg.LandingURL)
+	if err != nil {
+		return "/"
+	}
+
+	params := landing.Query()
+	if code != "" {
+		params.Set("referral_code", code)
+	}
+	for _, name := range utmParams {
+		if val := query.Get(name); val != "" {
+			params.Set(name, val)
+		}
+	}
+	landing.RawQuery = params.Encode()
+
+	return landing.String()
+}
+
+func parseFunnelFilter(r *http.Request) (dto.ReferralFunnelFilterDTO, error) {
+	query := r.URL.Query()
+
+	var filter dto.ReferralFunnelFilterDTO
+
+	if val := query.Get("user_id"); val != "" {
+		userID, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user_id")
+		}
+		filter.UserID = &userID
+	}
+
+	if val := query.Get("from"); val != "" {
+		from, err := parseTime(val, false)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from")
+		}
+		filter.From = &from
+	}
+
+	if val := query.Get("to"); val != "" {
+		to, err := parseTime(val, true)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to")
+		}
+		filter.To = &to
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return filter, fmt.Errorf("to must be later than from")
+	}
+
+	return filter, nil
+}
+
+// parseTime разбирает время RFC 3339 или дату; для конца периода дата означает начало следующего дня
+func parseTime(val string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", val)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
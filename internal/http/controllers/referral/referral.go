@@ -0,0 +1,124 @@
+package referral
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/config"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/services/referral"
+	"net/http"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Размер страницы рефералов по умолчанию и максимальный
+const (
+	defaultReferralsLimit = 20
+	maxReferralsLimit     = 100
+)
+
+type ReferralController struct {
+	log             *logrus.Logger
+	cfg             config.ClicksConfig
+	ReferralService referral.ReferralServiceI
+}
+
+type ReferralControllerI interface {
+	Referrals(w http.ResponseWriter, r *http.Request)
+	Click(w http.ResponseWriter, r *http.Request)
+	Funnel(w http.ResponseWriter, r *http.Request)
+}
+
+func New(log *logrus.Logger, cfg config.ClicksConfig, referralService referral.ReferralServiceI) *ReferralController {
+	return &ReferralController{
+		log:             log,
+		cfg:             cfg,
+		ReferralService: referralService,
+	}
+}
+
+// Referrals отдаёт сеть агента: ?user_id= (для менеджера), ?depth=, ?limit=, ?offset=
+func (c *ReferralController) Referrals(w http.ResponseWriter, r *http.Request) {
+	const op = "ReferralController.Referrals"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	filter, err := parseReferralFilter(r)
+	if err != nil {
+		c.log.Infof("%s: %v", op, err)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	network, err := c.ReferralService.Referrals(r.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, referral.ErrReferralForbidden):
+			c.log.Infof("%s: %v", op, err)
+
+			responses.Forbidden(w)
+		default:
+			c.log.Errorf("%s: %v", op, err)
+
+			responses.ServerError(w)
+		}
+		return
+	}
+
+	c.log.Debugf("%s: referrals send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(network)
+}
+
+func parseReferralFilter(r *http.Request) (dto.ReferralFilterDTO, error) {
+	query := r.URL.Query()
+
+	filter := dto.ReferralFilterDTO{Limit: defaultReferralsLimit}
+
+	if val := query.Get("user_id"); val != "" {
+		userID, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user_id")
+		}
+		filter.UserID = &userID
+	}
+
+	if val := query.Get("depth"); val != "" {
+		depth, err := strconv.Atoi(val)
+		if err != nil || depth <= 0 {
+			return filter, fmt.Errorf("invalid depth")
+		}
+		filter.Depth = min(depth, referral.MaxReferralDepth)
+	}
+
+	if val := query.Get("limit"); val != "" {
+		limit, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || limit <= 0 {
+			return filter, fmt.Errorf("invalid limit")
+		}
+		filter.Limit = min(limit, maxReferralsLimit)
+	}
+
+	if val := query.Get("offset"); val != "" {
+		offset, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || offset < 0 {
+			return filter, fmt.Errorf("invalid offset")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,121 @@
+package statistic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/services/statistic"
+	"ia-online-golang/internal/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+type StatisticController struct {
+	log              *logrus.Logger
+	validator        *validator.Validate
+	StatisticService statistic.StatisticServiceI
+}
+
+type StatisticControllerI interface {
+	Statistics(w http.ResponseWriter, r *http.Request)
+}
+
+func New(log *logrus.Logger, validator *validator.Validate, statisticService statistic.StatisticServiceI) *StatisticController {
+	return &StatisticController{
+		log:              log,
+		validator:        validator,
+		StatisticService: statisticService,
+	}
+}
+
+// Statistics отдаёт начисления и лиды временным рядом: ?user_id= (для менеджера), ?from=, ?to=
+// (дата 2006-01-02 или время RFC 3339; дата в to входит в период целиком), ?interval=day|week|month
+func (c *StatisticController) Statistics(w http.ResponseWriter, r *http.Request) {
+	const op = "StatisticController.Statistics"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	filter, err := parseStatisticFilter(r)
+	if err != nil {
+		c.log.Infof("%s: %v", op, err)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(filter); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	series, err := c.StatisticService.Series(r.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, statistic.ErrStatisticForbidden):
+			c.log.Infof("%s: %v", op, err)
+
+			responses.Forbidden(w)
+		case errors.Is(err, statistic.ErrStatisticPeriod), errors.Is(err, statistic.ErrTooManyPoints):
+			c.log.Infof("%s: %v", op, err)
+
+			responses.ValidationError(w, err.Error())
+		default:
+			c.log.Errorf("%s: %v", op, err)
+
+			responses.ServerError(w)
+		}
+		return
+	}
+
+	c.log.Debugf("%s: statistics send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
+
+func parseStatisticFilter(r *http.Request) (dto.StatisticFilterDTO, error) {
+	query := r.URL.Query()
+
+	filter := dto.StatisticFilterDTO{Interval: query.Get("interval")}
+
+	if val := query.Get("user_id"); val != "" {
+		userID, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user_id")
+		}
+		filter.UserID = &userID
+	}
+
+	if val := query.Get("from"); val != "" {
+		from, err := utils.ParsePeriodBound(val, false)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from")
+		}
+		filter.From = &from
+	}
+
+	if val := query.Get("to"); val != "" {
+		to, err := utils.ParsePeriodBound(val, true)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to")
+		}
+		filter.To = &to
+	}
+
+	return filter, nil
+}
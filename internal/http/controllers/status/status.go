@@ -0,0 +1,184 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/services/status"
+	"ia-online-golang/internal/utils"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+type StatusController struct {
+	log           *logrus.Logger
+	validator     *validator.Validate
+	StatusService status.StatusServiceI
+}
+
+type StatusControllerI interface {
+	Statuses(w http.ResponseWriter, r *http.Request)
+	SaveStatus(w http.ResponseWriter, r *http.Request)
+	EditStatus(w http.ResponseWriter, r *http.Request)
+}
+
+func New(log *logrus.Logger, validator *validator.Validate, statusService status.StatusServiceI) *StatusController {
+	return &StatusController{
+		log:           log,
+		validator:     validator,
+		StatusService: statusService,
+	}
+}
+
+func (c *StatusController) Statuses(w http.ResponseWriter, r *http.Request) {
+	const op = "StatusController.Statuses"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	statuses, err := c.StatusService.Statuses(r.Context())
+	if err != nil {
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: statuses send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+func (c *StatusController) SaveStatus(w http.ResponseWriter, r *http.Request) {
+	const op = "StatusController.SaveStatus"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodPost {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPost)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	var statusDTO dto.StatusDTO
+	if err := json.NewDecoder(r.Body).Decode(&statusDTO); err != nil {
+		c.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(statusDTO); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	c.log.Debugf("%s: validation completed", op)
+
+	created, err := c.StatusService.CreateStatus(r.Context(), statusDTO)
+	if err != nil {
+		if errors.Is(err, status.ErrStatusExists) {
+			c.log.Infof("%s: %v", op, err)
+
+			responses.StatusAlreadyExists(w)
+			return
+		}
+
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: status created", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (c *StatusController) EditStatus(w http.ResponseWriter, r *http.Request) {
+	const op = "StatusController.EditStatus"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodPut {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPut)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	var statusDTO dto.StatusDTO
+	if err := json.NewDecoder(r.Body).Decode(&statusDTO); err != nil {
+		c.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(statusDTO); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	if statusDTO.ID == nil {
+		c.log.Infof("%s: status id is required", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	c.log.Debugf("%s: validation completed", op)
+
+	err := c.StatusService.EditStatus(r.Context(), statusDTO)
+	if err != nil {
+		if errors.Is(err, status.ErrStatusNotFound) {
+			c.log.Infof("%s: %v", op, err)
+
+			responses.StatusNotFound(w)
+			return
+		}
+
+		if errors.Is(err, status.ErrStatusExists) {
+			c.log.Infof("%s: %v", op, err)
+
+			responses.StatusAlreadyExists(w)
+			return
+		}
+
+		if errors.Is(err, status.ErrStatusSystem) {
+			c.log.Infof("%s: %v", op, err)
+
+			responses.StatusSystem(w)
+			return
+		}
+
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: status updated", op)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,218 @@
+package tariff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/responses"
+	"ia-online-golang/internal/services/tariff"
+	"ia-online-golang/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+type TariffController struct {
+	log           *logrus.Logger
+	validator     *validator.Validate
+	TariffService tariff.TariffServiceI
+}
+
+type TariffControllerI interface {
+	Tariffs(w http.ResponseWriter, r *http.Request)
+	SaveTariff(w http.ResponseWriter, r *http.Request)
+	EditTariff(w http.ResponseWriter, r *http.Request)
+}
+
+func New(log *logrus.Logger, validator *validator.Validate, tariffService tariff.TariffServiceI) *TariffController {
+	return &TariffController{
+		log:           log,
+		validator:     validator,
+		TariffService: tariffService,
+	}
+}
+
+// Tariffs отдаёт тарифы: ?service=&city=&at= (at — дата 2006-01-02 или время RFC 3339)
+func (c *TariffController) Tariffs(w http.ResponseWriter, r *http.Request) {
+	const op = "TariffController.Tariffs"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodGet {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodGet)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	filter, err := parseTariffFilter(r)
+	if err != nil {
+		c.log.Infof("%s: %v", op, err)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(filter); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	tariffs, err := c.TariffService.Tariffs(r.Context(), filter)
+	if err != nil {
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+		return
+	}
+
+	c.log.Debugf("%s: tariffs send", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tariffs)
+}
+
+func (c *TariffController) SaveTariff(w http.ResponseWriter, r *http.Request) {
+	const op = "TariffController.SaveTariff"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodPost {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPost)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	var tariffDTO dto.TariffDTO
+	if err := json.NewDecoder(r.Body).Decode(&tariffDTO); err != nil {
+		c.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(tariffDTO); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	c.log.Debugf("%s: validation completed", op)
+
+	created, err := c.TariffService.CreateTariff(r.Context(), tariffDTO)
+	if err != nil {
+		c.handleTariffError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: tariff created", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (c *TariffController) EditTariff(w http.ResponseWriter, r *http.Request) {
+	const op = "TariffController.EditTariff"
+
+	c.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodPut {
+		c.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPut)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	var tariffDTO dto.TariffDTO
+	if err := json.NewDecoder(r.Body).Decode(&tariffDTO); err != nil {
+		c.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := c.validator.Struct(tariffDTO); err != nil {
+		c.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	if tariffDTO.ID == nil {
+		c.log.Infof("%s: tariff id is required", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	c.log.Debugf("%s: validation completed", op)
+
+	updated, err := c.TariffService.EditTariff(r.Context(), tariffDTO)
+	if err != nil {
+		c.handleTariffError(w, op, err)
+		return
+	}
+
+	c.log.Debugf("%s: tariff updated", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (c *TariffController) handleTariffError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, tariff.ErrTariffNotFound):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.TariffNotFound(w)
+	case errors.Is(err, tariff.ErrTariffOverlap):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.TariffOverlap(w)
+	case errors.Is(err, tariff.ErrTariffPeriod):
+		c.log.Infof("%s: %v", op, err)
+
+		responses.ValidationError(w, err.Error())
+	default:
+		c.log.Errorf("%s: %v", op, err)
+
+		responses.ServerError(w)
+	}
+}
+
+func parseTariffFilter(r *http.Request) (dto.TariffFilterDTO, error) {
+	query := r.URL.Query()
+
+	var filter dto.TariffFilterDTO
+
+	if service := query.Get("service"); service != "" {
+		filter.Service = &service
+	}
+	if city := query.Get("city"); city != "" {
+		filter.City = &city
+	}
+
+	if at := query.Get("at"); at != "" {
+		parsed, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			parsed, err = time.Parse("2006-01-02", at)
+			if err != nil {
+				return dto.TariffFilterDTO{}, fmt.Errorf("invalid at")
+			}
+		}
+		filter.At = &parsed
+	}
+
+	return filter, nil
+}
@@ -25,6 +25,7 @@ type UserControllerI interface {
 	User(w http.ResponseWriter, r *http.Request)
 	Users(w http.ResponseWriter, r *http.Request)
 	EditUser(w http.ResponseWriter, r *http.Request)
+	ReferralCode(w http.ResponseWriter, r *http.Request)
 }
 
 // New создаёт новый экземпляр AuthController
@@ -152,3 +153,63 @@ func (u UserController) EditUser(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// ReferralCode задаёт агенту короткий реферальный код вместо UUID
+func (u UserController) ReferralCode(w http.ResponseWriter, r *http.Request) {
+	const op = "UserController.ReferralCode"
+
+	u.log.Debugf("%s: start", op)
+
+	if r.Method != http.MethodPut {
+		u.log.Infof("%s: method not allowed. method: %s", op, r.Method)
+
+		w.Header().Set("Allow", http.MethodPut)
+		responses.MethodNotAllowed(w)
+		return
+	}
+
+	var codeDTO dto.ReferralCodeDTO
+	if err := json.NewDecoder(r.Body).Decode(&codeDTO); err != nil {
+		u.log.Infof("%s: decode error", op)
+
+		responses.InvalidRequest(w)
+		return
+	}
+
+	if err := u.validator.Struct(codeDTO); err != nil {
+		u.log.Infof("%s: validation error", op)
+
+		responses.ValidationError(w, utils.FormatValidationErrors(err))
+		return
+	}
+
+	u.log.Debugf("%s: validation completed", op)
+
+	userDTO, err := u.UserService.SetReferralCode(r.Context(), codeDTO.ReferralCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrReferralCodeInvalid), errors.Is(err, user.ErrReferralCodeForbidden):
+			u.log.Infof("%s: %v", op, err)
+
+			responses.ValidationError(w, err.Error())
+		case errors.Is(err, user.ErrReferralCodeTaken):
+			u.log.Infof("%s: %v", op, err)
+
+			responses.ReferralCodeTaken(w)
+		case errors.Is(err, user.ErrUserNotFound):
+			u.log.Infof("%s: %v", op, err)
+
+			responses.UserNotFound(w)
+		default:
+			u.log.Errorf("%s: %v", op, err)
+
+			responses.ServerError(w)
+		}
+		return
+	}
+
+	u.log.Debugf("%s: referral code updated", op)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userDTO)
+}
@@ -11,6 +11,12 @@ type errorResponse struct {
 	Code    int    `json:"code"`
 }
 
+// duplicateResponse — ошибка 409 со сведениями о лиде, за которым уже закреплён клиент
+type duplicateResponse struct {
+	errorResponse
+	Duplicate any `json:"duplicate"`
+}
+
 // sendError отправляет JSON-ответ с кодом ошибки
 func SendError(w http.ResponseWriter, statusCode int, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -87,3 +93,86 @@ func PasswordCodeHasExpired(w http.ResponseWriter) {
 func PasswordCodeIncorrect(w http.ResponseWriter) {
 	SendError(w, http.StatusUnauthorized, "password code incorrect")
 }
+func StatusNotFound(w http.ResponseWriter) {
+	SendError(w, http.StatusNotFound, "status not found")
+}
+func StatusAlreadyExists(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "status or bitrix stage already exists")
+}
+func StatusSystem(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "system status cannot be renamed")
+}
+func LeadNotFound(w http.ResponseWriter) {
+	SendError(w, http.StatusNotFound, "lead not found")
+}
+
+func LeadNotEditable(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "lead can be edited only in new status")
+}
+
+func LeadInvalidTransition(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "lead status does not allow this action")
+}
+
+func LeadDuplicate(w http.ResponseWriter, duplicate any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(duplicateResponse{
+		errorResponse: errorResponse{
+			Message: "client already has an active lead",
+			Code:    http.StatusConflict,
+		},
+		Duplicate: duplicate,
+	})
+}
+
+func PayoutNotFound(w http.ResponseWriter) {
+	SendError(w, http.StatusNotFound, "payout not found")
+}
+
+func PayoutAlreadyRequested(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "user already has an open payout")
+}
+
+func PayoutInsufficientFunds(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "insufficient funds for payout")
+}
+
+func PayoutInvalidStatus(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "payout status does not allow this action")
+}
+
+func PayoutExported(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "payout is in exported batch")
+}
+
+func PayoutBatchNotFound(w http.ResponseWriter) {
+	SendError(w, http.StatusNotFound, "payout batch not found")
+}
+
+func PayoutBatchEmpty(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "no approved payouts for batch")
+}
+
+func PaymentDetailsNotFound(w http.ResponseWriter) {
+	SendError(w, http.StatusNotFound, "payment details not found")
+}
+
+func PaymentDetailsRequired(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "payment details are required")
+}
+
+func PayoutBatchNotExportable(w http.ResponseWriter, reason string) {
+	SendError(w, http.StatusConflict, reason)
+}
+
+func TariffNotFound(w http.ResponseWriter) {
+	SendError(w, http.StatusNotFound, "tariff not found")
+}
+
+func TariffOverlap(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "tariff period overlaps another tariff for this service and city")
+}
+func ReferralCodeTaken(w http.ResponseWriter) {
+	SendError(w, http.StatusConflict, "referral code is already taken")
+}
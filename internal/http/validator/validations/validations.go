@@ -4,6 +4,9 @@ import (
 	"crypto/rand"
 	"fmt"
 	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/lib/phone"
+	"ia-online-golang/internal/lib/refcode"
+	"ia-online-golang/internal/lib/requisites"
 	"math/big"
 	"regexp"
 
@@ -26,6 +29,32 @@ func PasswordValidation(fl validator.FieldLevel) bool {
 	return len(password) >= 8 && hasDigit && hasUpper && hasSpecial
 }
 
+// Телефон, который можно привести к E.164 (см. lib/phone)
+func PhoneValidation(fl validator.FieldLevel) bool {
+	_, err := phone.Normalize(fl.Field().String())
+	return err == nil
+}
+
+// ИНН с верными контрольными цифрами
+func INNValidation(fl validator.FieldLevel) bool {
+	return requisites.ValidINN(requisites.Clean(fl.Field().String()))
+}
+
+func BIKValidation(fl validator.FieldLevel) bool {
+	return requisites.ValidBIK(requisites.Clean(fl.Field().String()))
+}
+
+// Номер карты, проходящий проверку по алгоритму Луна
+func CardValidation(fl validator.FieldLevel) bool {
+	return requisites.ValidCard(requisites.Clean(fl.Field().String()))
+}
+
+// Реферальный код, выбранный агентом (см. lib/refcode)
+func ReferralCodeValidation(fl validator.FieldLevel) bool {
+	_, err := refcode.Normalize(fl.Field().String())
+	return err == nil
+}
+
 func AtLeastOneServiceEnabled(fl validator.FieldLevel) bool {
 	obj := fl.Parent().Interface().(dto.LeadDTO)
 	return obj.IsInternet || obj.IsShipping || obj.IsCleaning
@@ -40,6 +69,26 @@ func NewPasswordStructValidation(sl validator.StructLevel) {
 	}
 }
 
+// PaymentDetailsStructValidation сверяет счета с БИК по контрольному ключу;
+// ИНН самозанятого должен быть ИНН физического лица
+func PaymentDetailsStructValidation(sl validator.StructLevel) {
+	dto := sl.Current().Interface().(dto.PaymentDetailsDTO)
+
+	bik := requisites.Clean(dto.BIK)
+
+	if dto.Account != "" && !requisites.ValidAccount(requisites.Clean(dto.Account), bik) {
+		sl.ReportError(dto.Account, "Account", "account", "account", "BIK")
+	}
+
+	if dto.CorrAccount != "" && !requisites.ValidCorrAccount(requisites.Clean(dto.CorrAccount), bik) {
+		sl.ReportError(dto.CorrAccount, "CorrAccount", "corr_account", "corr_account", "BIK")
+	}
+
+	if dto.SelfEmployed && !requisites.ValidPersonINN(requisites.Clean(dto.INN)) {
+		sl.ReportError(dto.INN, "INN", "inn", "person_inn", "")
+	}
+}
+
 func GenerateValidPassword(length int) (string, error) {
 	if length < 8 {
 		return "", fmt.Errorf("password length must be at least 8 characters")
@@ -13,7 +13,13 @@ func New() *validator.Validate {
 	// Кастомные валидации
 	v.RegisterValidation("complexpassword", validations.PasswordValidation)
 	v.RegisterValidation("atLeastOneService", validations.AtLeastOneServiceEnabled)
+	v.RegisterValidation("phone", validations.PhoneValidation)
+	v.RegisterValidation("inn", validations.INNValidation)
+	v.RegisterValidation("bik", validations.BIKValidation)
+	v.RegisterValidation("card", validations.CardValidation)
+	v.RegisterValidation("referralcode", validations.ReferralCodeValidation)
 	v.RegisterStructValidation(validations.NewPasswordStructValidation, dto.NewPasswordDTO{})
+	v.RegisterStructValidation(validations.PaymentDetailsStructValidation, dto.PaymentDetailsDTO{})
 
 	return v
 }
@@ -0,0 +1,158 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount — денежная сумма в копейках. В JSON и в колонках NUMERIC(…,2)
+// представлена в рублях с двумя знаками после точки: 1500.50.
+type Amount int64
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// FromKopecks создаёт сумму из копеек
+func FromKopecks(kopecks int64) Amount {
+	return Amount(kopecks)
+}
+
+// FromRubles создаёт сумму из целого числа рублей
+func FromRubles(rubles int64) Amount {
+	return Amount(rubles * 100)
+}
+
+// Parse разбирает сумму в рублях вида "1500", "1500.5", "1500,50" или "-20.10".
+// Дробная часть длиннее двух знаков округляется до копеек.
+func Parse(value string) (Amount, error) {
+	value = strings.TrimSpace(strings.ReplaceAll(value, ",", "."))
+	if value == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch value[0] {
+	case '-':
+		negative = true
+		value = value[1:]
+	case '+':
+		value = value[1:]
+	}
+
+	whole, frac, _ := strings.Cut(value, ".")
+	if whole == "" && frac == "" {
+		return 0, ErrInvalidAmount
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidAmount
+	}
+
+	rubles, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || rubles > math.MaxInt64/100-1 {
+		return 0, ErrInvalidAmount
+	}
+
+	frac += "000"
+	kopecks, _ := strconv.ParseInt(frac[:2], 10, 64)
+	if frac[2] >= '5' {
+		kopecks++
+	}
+
+	amount := rubles*100 + kopecks
+	if negative {
+		amount = -amount
+	}
+
+	return Amount(amount), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Kopecks возвращает сумму в копейках
+func (a Amount) Kopecks() int64 {
+	return int64(a)
+}
+
+// Float возвращает сумму в рублях; только для отображения, не для расчётов
+func (a Amount) Float() float64 {
+	return float64(a) / 100
+}
+
+func (a Amount) String() string {
+	sign := ""
+	value := int64(a)
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, value/100, value%100)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON принимает как число, так и строку с суммой
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if value == "null" {
+		return nil
+	}
+
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+
+	parsed, err := Parse(value)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, string(data))
+	}
+
+	*a = parsed
+	return nil
+}
+
+// Scan читает NUMERIC, который lib/pq отдаёт строкой; NULL читается как ноль
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case int64:
+		*a = FromRubles(v)
+	case float64:
+		*a = Amount(math.Round(v * 100))
+	default:
+		return fmt.Errorf("%w: unsupported type %T", ErrInvalidAmount, src)
+	}
+	return nil
+}
+
+func (a *Amount) scanString(value string) error {
+	parsed, err := Parse(value)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, value)
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
@@ -0,0 +1,65 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Amount
+	}{
+		{"1500", 150000},
+		{"1500.5", 150050},
+		{"1500,50", 150050},
+		{" 1500.50 ", 150050},
+		{"+20", 2000},
+		{"-20.10", -2010},
+		{".5", 50},
+		{"0.004", 0},
+		{"0.005", 1},
+		{"1.994", 199},
+		{"1.995", 200},
+		{"0.999", 100},
+		{"-0.005", -1},
+		{"10.", 1000},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.value)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, value := range []string{"", " ", "-", ".", "abc", "1.2.3", "1 000", "1e3", "--1", "92233720368547758.07"} {
+		if _, err := Parse(value); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Parse(%q): error = %v, want ErrInvalidAmount", value, err)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{150050, "1500.50"},
+		{-2010, "-20.10"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", int64(tt.amount), got, tt.want)
+		}
+	}
+}
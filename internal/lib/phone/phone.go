@@ -0,0 +1,44 @@
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// Normalize приводит номер к E.164. Российские номера в записи "8 (999) 123-45-67",
+// "+7 999 123 45 67", "7-999-123-45-67" и "9991234567" становятся "+79991234567";
+// прочие номера принимаются только с "+" и кодом страны.
+func Normalize(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	plus := strings.HasPrefix(raw, "+")
+	if plus {
+		raw = raw[1:]
+	}
+
+	var b strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune(" -().", r):
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	digits := b.String()
+
+	switch {
+	case len(digits) == 11 && digits[0] == '7':
+		return "+" + digits, nil
+	case !plus && len(digits) == 11 && digits[0] == '8':
+		return "+7" + digits[1:], nil
+	case !plus && len(digits) == 10 && digits[0] != '0':
+		return "+7" + digits, nil
+	case plus && digits != "" && digits[0] != '7' && digits[0] != '0' && len(digits) >= 8 && len(digits) <= 15:
+		return "+" + digits, nil
+	}
+
+	return "", ErrInvalidPhone
+}
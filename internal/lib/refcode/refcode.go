@@ -0,0 +1,63 @@
+package refcode
+
+import (
+	"errors"
+	"strings"
+)
+
+// Длина кода, который агент выбирает сам. UUID длиннее MaxLength, поэтому
+// выбранный код не может совпасть с чьим-то UUID.
+const (
+	MinLength = 4
+	MaxLength = 20
+)
+
+var (
+	ErrInvalidCode   = errors.New("referral code must be 4-20 latin letters, digits or hyphens and start with a letter")
+	ErrForbiddenCode = errors.New("referral code contains a forbidden word")
+)
+
+// Слова, которые не могут входить в код: служебные, чтобы код не выдавал себя за компанию,
+// и нецензурные в транслите. Сравнение идёт после замены похожих цифр на буквы.
+var blacklist = []string{
+	"admin", "manager", "support", "official", "iaonline", "moderator",
+	"huy", "xuy", "pizd", "pisd", "blyad", "blyat", "yebat", "yeban", "yobany",
+	"mudak", "mudil", "pidor", "pidar", "gandon", "zalup", "shlyuh", "shluh", "dolboeb",
+	"fuck", "cunt", "bitch", "nigger", "nigga",
+}
+
+var lookalikes = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "-", "")
+
+// Normalize приводит код к нижнему регистру и проверяет его: от MinLength до MaxLength
+// символов из латиницы, цифр и дефиса, первая буква, без дефиса в конце и двух дефисов подряд.
+func Normalize(raw string) (string, error) {
+	code := strings.ToLower(strings.TrimSpace(raw))
+
+	if len(code) < MinLength || len(code) > MaxLength {
+		return "", ErrInvalidCode
+	}
+	if code[0] < 'a' || code[0] > 'z' || strings.HasSuffix(code, "-") || strings.Contains(code, "--") {
+		return "", ErrInvalidCode
+	}
+
+	for _, r := range code {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return "", ErrInvalidCode
+		}
+	}
+
+	return code, nil
+}
+
+// Allowed проверяет код по чёрному списку. Код должен быть уже нормализован.
+func Allowed(code string) error {
+	plain := lookalikes.Replace(code)
+
+	for _, word := range blacklist {
+		if strings.Contains(plain, word) {
+			return ErrForbiddenCode
+		}
+	}
+
+	return nil
+}
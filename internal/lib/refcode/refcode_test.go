@@ -0,0 +1,69 @@
+package refcode
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"ivan", "ivan", false},
+		{"  Ivan-Petrov ", "ivan-petrov", false},
+		{"moscow2026", "moscow2026", false},
+		{"abcdefghijklmnopqrst", "abcdefghijklmnopqrst", false},
+		{"abc", "", true},
+		{"abcdefghijklmnopqrstu", "", true},
+		{"1ivan", "", true},
+		{"-ivan", "", true},
+		{"ivan-", "", true},
+		{"ivan--petrov", "", true},
+		{"ivan_petrov", "", true},
+		{"иван", "", true},
+		{"ivan petrov", "", true},
+		{"550e8400-e29b-41d4-a716-446655440000", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Normalize(tt.raw)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidCode) {
+				t.Errorf("Normalize(%q): error = %v, want ErrInvalidCode", tt.raw, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		code    string
+		allowed bool
+	}{
+		{"ivan-petrov", true},
+		{"moscow2026", true},
+		{"sasha-internet", true},
+		{"admin", false},
+		{"ia-online", false},
+		{"4dm1n", false},
+		{"super-manager", false},
+		{"supp0rt-team", false},
+		{"f-u-c-k", false},
+	}
+
+	for _, tt := range tests {
+		err := Allowed(tt.code)
+		if tt.allowed && err != nil {
+			t.Errorf("Allowed(%q) = %v, want nil", tt.code, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrForbiddenCode) {
+			t.Errorf("Allowed(%q) = %v, want ErrForbiddenCode", tt.code, err)
+		}
+	}
+}
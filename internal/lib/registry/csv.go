@@ -0,0 +1,165 @@
+package registry
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/lib/money"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Заголовок реестра CSV; в ответном файле банк добавляет колонки «Статус» и «Комментарий»
+var csvHeader = []string{"Номер", "Получатель", "ИНН", "Карта", "Счёт", "Банк", "БИК", "Корр. счёт", "Сумма", "Назначение платежа"}
+
+// Статусы ответного файла, означающие, что платёж проведён
+var csvPaidStatuses = map[string]bool{
+	"исполнен": true,
+	"оплачен":  true,
+	"проведен": true,
+	"проведён": true,
+	"paid":     true,
+	"executed": true,
+}
+
+// CSV — реестр в UTF-8 с BOM и разделителем «;», который открывается в Excel без настройки
+type CSV struct{}
+
+func (CSV) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (CSV) Extension() string {
+	return "csv"
+}
+
+func (CSV) Write(w io.Writer, registry Registry) error {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = ';'
+	writer.UseCRLF = true
+
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, payment := range registry.Payments {
+		record := []string{
+			strconv.FormatInt(payment.PayoutID, 10),
+			payment.Recipient.Name,
+			payment.Recipient.INN,
+			payment.Recipient.CardNumber,
+			payment.Recipient.Account,
+			payment.Recipient.BankName,
+			payment.Recipient.BIK,
+			payment.Recipient.CorrAccount,
+			strings.Replace(payment.Amount.String(), ".", ",", 1),
+			payment.Purpose,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// ReadConfirmation читает ответный файл: колонки «Номер» и «Статус» обязательны,
+// «Сумма» и «Комментарий» — нет. Порядок колонок не важен.
+func (CSV) ReadConfirmation(r io.Reader, _ Payer) ([]Confirmation, error) {
+	text, err := decodeText(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(text, "\ufeff")))
+	reader.Comma = ';'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("confirmation file is empty")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	numberCol, ok := columns["номер"]
+	if !ok {
+		return nil, fmt.Errorf("confirmation file has no «Номер» column")
+	}
+	statusCol, ok := columns["статус"]
+	if !ok {
+		return nil, fmt.Errorf("confirmation file has no «Статус» column")
+	}
+	amountCol, hasAmount := columns["сумма"]
+	commentCol, hasComment := columns["комментарий"]
+
+	field := func(record []string, i int) string {
+		if i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var confirmations []Confirmation
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		number := field(record, numberCol)
+		if number == "" {
+			continue
+		}
+
+		payoutID, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid number %q", line, number)
+		}
+
+		confirmation := Confirmation{PayoutID: payoutID}
+
+		if hasAmount {
+			if val := field(record, amountCol); val != "" {
+				amount, err := money.Parse(strings.ReplaceAll(val, " ", ""))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid amount %q", line, val)
+				}
+				confirmation.Amount = amount
+			}
+		}
+
+		status := field(record, statusCol)
+		confirmation.Paid = csvPaidStatuses[strings.ToLower(status)]
+		if !confirmation.Paid {
+			confirmation.Reason = status
+			if hasComment {
+				if comment := field(record, commentCol); comment != "" {
+					confirmation.Reason += ": " + comment
+				}
+			}
+		}
+
+		confirmations = append(confirmations, confirmation)
+	}
+
+	return confirmations, nil
+}
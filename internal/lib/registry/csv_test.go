@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"bytes"
+	"ia-online-golang/internal/lib/money"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRegistry() Registry {
+	return Registry{
+		BatchID:   7,
+		CreatedAt: time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC),
+		Payer: Payer{
+			Name:        "ООО «Ай-Онлайн»",
+			INN:         "7707083893",
+			KPP:         "773601001",
+			Account:     "40702810938000000001",
+			BankName:    "ПАО Сбербанк",
+			BIK:         "044525225",
+			CorrAccount: "30101810400000000225",
+		},
+		Payments: []Payment{
+			{
+				PayoutID: 101,
+				Amount:   money.FromKopecks(150050),
+				Recipient: Recipient{
+					Name:        "Иванов Иван Иванович",
+					INN:         "500100732259",
+					Account:     "40817810938000000002",
+					BankName:    "ПАО Сбербанк",
+					BIK:         "044525225",
+					CorrAccount: "30101810400000000225",
+				},
+				Purpose: "Выплата агентского вознаграждения по заявке №101. НДС не облагается",
+			},
+			{
+				PayoutID: 102,
+				Amount:   money.FromRubles(2000),
+				Recipient: Recipient{
+					Name:        "Петров Пётр Петрович",
+					INN:         "500100732259",
+					Account:     "40817810938000000003",
+					BankName:    "ПАО Сбербанк",
+					BIK:         "044525225",
+					CorrAccount: "30101810400000000225",
+				},
+				Purpose: "Оплата агентских услуг по заявке №102. Самозанятый, НПД. НДС не облагается",
+			},
+		},
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	registry := testRegistry()
+
+	var out bytes.Buffer
+	if err := (CSV{}).Write(&out, registry); err != nil {
+		t.Fatal(err)
+	}
+
+	// Банк возвращает реестр с колонками «Статус» и «Комментарий»
+	lines := strings.Split(strings.TrimRight(out.String(), "\r\n"), "\r\n")
+	if len(lines) != len(registry.Payments)+1 {
+		t.Fatalf("got %d lines, want %d", len(lines), len(registry.Payments)+1)
+	}
+	statuses := []string{"Статус;Комментарий", "Исполнен;", "Отклонен;Счёт закрыт"}
+	for i := range lines {
+		lines[i] += ";" + statuses[i]
+	}
+
+	confirmations, err := (CSV{}).ReadConfirmation(strings.NewReader(strings.Join(lines, "\r\n")), Payer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Confirmation{
+		{PayoutID: 101, Amount: money.FromKopecks(150050), Paid: true},
+		{PayoutID: 102, Amount: money.FromRubles(2000), Reason: "Отклонен: Счёт закрыт"},
+	}
+	if len(confirmations) != len(want) {
+		t.Fatalf("got %d confirmations, want %d", len(confirmations), len(want))
+	}
+	for i := range want {
+		if confirmations[i] != want[i] {
+			t.Errorf("confirmation %d = %+v, want %+v", i, confirmations[i], want[i])
+		}
+	}
+}
+
+func TestCSVReadConfirmation(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []Confirmation
+		wantErr bool
+	}{
+		{
+			name: "columns in any order, amount with spaces",
+			file: "Статус;Сумма;Номер\nоплачен;1 500,50;101\n;;\n",
+			want: []Confirmation{{PayoutID: 101, Amount: money.FromKopecks(150050), Paid: true}},
+		},
+		{
+			name: "without amount",
+			file: "Номер;Статус\n101;PAID\n",
+			want: []Confirmation{{PayoutID: 101, Paid: true}},
+		},
+		{name: "empty", file: "", wantErr: true},
+		{name: "no status column", file: "Номер;Сумма\n101;10\n", wantErr: true},
+		{name: "invalid number", file: "Номер;Статус\nN101;paid\n", wantErr: true},
+		{name: "invalid amount", file: "Номер;Статус;Сумма\n101;paid;много\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := (CSV{}).ReadConfirmation(strings.NewReader(tt.file), Payer{})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: confirmation %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package registry
+
+import (
+	"bufio"
+	"fmt"
+	"ia-online-golang/internal/lib/money"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	oneCHeader     = "1CClientBankExchange"
+	oneCDateLayout = "02.01.2006"
+	oneCTimeLayout = "15:04:05"
+)
+
+// Номер заявки в назначении платежа, которое пишет выгрузка
+var oneCPurposePayout = regexp.MustCompile(`по заявке №(\d+)`)
+
+// OneC — обмен с клиент-банком в формате 1CClientBankExchange 1.03: каждая выплата
+// выгружается платёжным поручением, ответом служит выписка, где у проведённых
+// платежей заполнена ДатаСписано. Платёжное поручение требует счёта получателя,
+// выплаты на карту в этом формате не выгружаются.
+type OneC struct{}
+
+func (OneC) ContentType() string {
+	return "text/plain; charset=windows-1251"
+}
+
+func (OneC) Extension() string {
+	return "txt"
+}
+
+func (OneC) Write(w io.Writer, registry Registry) error {
+	for _, payment := range registry.Payments {
+		if payment.Recipient.Account == "" {
+			return fmt.Errorf("%w: payout %d: recipient has no bank account", ErrUnsupportedPayment, payment.PayoutID)
+		}
+	}
+
+	encoder := encoding.ReplaceUnsupported(charmap.Windows1251.NewEncoder())
+	out := bufio.NewWriter(encoder.Writer(w))
+
+	line := func(key, value string) {
+		// Перевод строки внутри значения сломал бы разбор файла
+		value = strings.Join(strings.Fields(value), " ")
+		fmt.Fprintf(out, "%s=%s\r\n", key, value)
+	}
+
+	date := registry.CreatedAt.Format(oneCDateLayout)
+	payer := registry.Payer
+
+	out.WriteString(oneCHeader + "\r\n")
+	line("ВерсияФормата", "1.03")
+	line("Кодировка", "Windows")
+	line("Отправитель", "ia-online")
+	line("Получатель", "")
+	line("ДатаСоздания", date)
+	line("ВремяСоздания", registry.CreatedAt.Format(oneCTimeLayout))
+	line("ДатаНачала", date)
+	line("ДатаКонца", date)
+	line("РасчСчет", payer.Account)
+	line("Документ", "Платежное поручение")
+
+	for _, payment := range registry.Payments {
+		recipient := payment.Recipient
+
+		line("СекцияДокумент", "Платежное поручение")
+		line("Номер", strconv.FormatInt(payment.PayoutID, 10))
+		line("Дата", date)
+		line("Сумма", payment.Amount.String())
+		line("ПлательщикСчет", payer.Account)
+		line("Плательщик", fmt.Sprintf("ИНН %s %s", payer.INN, payer.Name))
+		line("ПлательщикИНН", payer.INN)
+		line("ПлательщикКПП", payer.KPP)
+		line("Плательщик1", payer.Name)
+		line("ПлательщикРасчСчет", payer.Account)
+		line("ПлательщикБанк1", payer.BankName)
+		line("ПлательщикБИК", payer.BIK)
+		line("ПлательщикКорсчет", payer.CorrAccount)
+		line("ПолучательСчет", recipient.Account)
+		line("Получатель", recipient.Name)
+		line("ПолучательИНН", recipient.INN)
+		line("Получатель1", recipient.Name)
+		line("ПолучательРасчСчет", recipient.Account)
+		line("ПолучательБанк1", recipient.BankName)
+		line("ПолучательБИК", recipient.BIK)
+		line("ПолучательКорсчет", recipient.CorrAccount)
+		line("ВидОплаты", "01")
+		line("Очередность", "5")
+		line("НазначениеПлатежа", payment.Purpose)
+		out.WriteString("КонецДокумента\r\n")
+	}
+
+	out.WriteString("КонецФайла\r\n")
+
+	return out.Flush()
+}
+
+// ReadConfirmation читает выписку банка. Заявкой считается только списание со счёта
+// payer, у которого номер документа совпадает с номером заявки в назначении платежа;
+// остальные документы (входящие платежи, прочие списания) пропускаются.
+func (OneC) ReadConfirmation(r io.Reader, payer Payer) ([]Confirmation, error) {
+	if payer.Account == "" {
+		return nil, ErrPayerAccountMissing
+	}
+
+	text, err := decodeText(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(strings.TrimPrefix(lines[0], "\ufeff")) != oneCHeader {
+		return nil, fmt.Errorf("not a %s file", oneCHeader)
+	}
+
+	var confirmations []Confirmation
+	var document map[string]string
+
+	for i, raw := range lines[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(raw), "=")
+
+		switch {
+		case key == "СекцияДокумент":
+			document = make(map[string]string)
+		case key == "КонецДокумента":
+			if document == nil {
+				return nil, fmt.Errorf("line %d: КонецДокумента without СекцияДокумент", i+2)
+			}
+
+			confirmation, ok, err := oneCConfirmation(document, payer)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+2, err)
+			}
+			if ok {
+				confirmations = append(confirmations, confirmation)
+			}
+			document = nil
+		case document != nil:
+			document[key] = strings.TrimSpace(value)
+		}
+	}
+
+	return confirmations, nil
+}
+
+func oneCConfirmation(document map[string]string, payer Payer) (Confirmation, bool, error) {
+	account := document["ПлательщикСчет"]
+	if account == "" {
+		account = document["ПлательщикРасчСчет"]
+	}
+	if account != payer.Account {
+		return Confirmation{}, false, nil
+	}
+
+	payoutID, err := strconv.ParseInt(document["Номер"], 10, 64)
+	if err != nil {
+		return Confirmation{}, false, nil
+	}
+
+	purpose := oneCPurposePayout.FindStringSubmatch(document["НазначениеПлатежа"])
+	if purpose == nil || purpose[1] != strconv.FormatInt(payoutID, 10) {
+		return Confirmation{}, false, nil
+	}
+
+	confirmation := Confirmation{PayoutID: payoutID}
+
+	if val := document["Сумма"]; val != "" {
+		amount, err := money.Parse(val)
+		if err != nil {
+			return Confirmation{}, false, fmt.Errorf("document %d: invalid amount %q", payoutID, val)
+		}
+		confirmation.Amount = amount
+	}
+
+	confirmation.Paid = document["ДатаСписано"] != ""
+	if !confirmation.Paid {
+		confirmation.Reason = "в выписке нет даты списания"
+	}
+
+	return confirmation, true, nil
+}
@@ -0,0 +1,140 @@
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"ia-online-golang/internal/lib/money"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// bankStatement превращает выгруженные поручения в выписку: у первых paid документов
+// банк заполняет ДатаСписано
+func bankStatement(t *testing.T, exported []byte, paid int) []byte {
+	t.Helper()
+
+	text, err := charmap.Windows1251.NewDecoder().String(string(exported))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documents := strings.Split(text, "КонецДокумента\r\n")
+	for i := 0; i < paid && i < len(documents)-1; i++ {
+		documents[i] += "ДатаСписано=02.03.2026\r\n"
+	}
+
+	statement, err := charmap.Windows1251.NewEncoder().String(strings.Join(documents, "КонецДокумента\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []byte(statement)
+}
+
+func TestOneCRoundTrip(t *testing.T) {
+	registry := testRegistry()
+
+	var out bytes.Buffer
+	if err := (OneC{}).Write(&out, registry); err != nil {
+		t.Fatal(err)
+	}
+	exported := out.Bytes()
+
+	tests := []struct {
+		name    string
+		payer   Payer
+		file    []byte
+		want    []Confirmation
+		wantErr error
+	}{
+		{
+			name:  "statement",
+			payer: registry.Payer,
+			file:  bankStatement(t, exported, 1),
+			want: []Confirmation{
+				{PayoutID: 101, Amount: money.FromKopecks(150050), Paid: true},
+				{PayoutID: 102, Amount: money.FromRubles(2000), Reason: "в выписке нет даты списания"},
+			},
+		},
+		{
+			name:  "other payer account",
+			payer: Payer{Account: "40702810838000000004"},
+			file:  bankStatement(t, exported, 2),
+		},
+		{
+			name:    "payer account is not configured",
+			payer:   Payer{},
+			file:    bankStatement(t, exported, 2),
+			wantErr: ErrPayerAccountMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := (OneC{}).ReadConfirmation(bytes.NewReader(tt.file), tt.payer)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: confirmation %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestOneCReadConfirmationSkipsForeignDocuments(t *testing.T) {
+	account := "40702810938000000001"
+	statement := strings.Join([]string{
+		oneCHeader,
+		// Входящий платёж на наш счёт
+		"СекцияДокумент=Платежное поручение",
+		"Номер=101",
+		"ПлательщикСчет=40817810938000000002",
+		"НазначениеПлатежа=Возврат по заявке №101",
+		"ДатаСписано=02.03.2026",
+		"КонецДокумента",
+		// Наше списание, но не по заявке: номер не совпадает с назначением
+		"СекцияДокумент=Платежное поручение",
+		"Номер=555",
+		"ПлательщикСчет=" + account,
+		"НазначениеПлатежа=Аренда офиса по заявке №101",
+		"ДатаСписано=02.03.2026",
+		"КонецДокумента",
+		// Выплата в UTF-8 с ПлательщикРасчСчет вместо ПлательщикСчет
+		"СекцияДокумент=Платежное поручение",
+		"Номер=103",
+		"ПлательщикРасчСчет=" + account,
+		"Сумма=99.90",
+		"НазначениеПлатежа=Выплата агентского вознаграждения по заявке №103",
+		"ДатаСписано=02.03.2026",
+		"КонецДокумента",
+		"КонецФайла",
+	}, "\r\n")
+
+	got, err := (OneC{}).ReadConfirmation(strings.NewReader(statement), Payer{Account: account})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Confirmation{PayoutID: 103, Amount: money.FromKopecks(9990), Paid: true}
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("got %+v, want [%+v]", got, want)
+	}
+}
+
+func TestOneCWriteRejectsCardPayments(t *testing.T) {
+	registry := testRegistry()
+	registry.Payments[1].Recipient = Recipient{Name: "Петров Пётр Петрович", CardNumber: "4111111111111111"}
+
+	err := (OneC{}).Write(&bytes.Buffer{}, registry)
+	if !errors.Is(err, ErrUnsupportedPayment) {
+		t.Fatalf("error = %v, want ErrUnsupportedPayment", err)
+	}
+}
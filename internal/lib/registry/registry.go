@@ -0,0 +1,156 @@
+// Package registry выгружает выплаты агентам в реестры платежей для банка
+// и читает ответные файлы банка с подтверждением оплаты.
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/lib/money"
+	"io"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown registry format")
+	// В конфигурации не задан счёт плательщика, выписку не с чем сопоставить
+	ErrPayerAccountMissing = errors.New("payer account is not configured")
+	// Платёж нельзя выгрузить в выбранном формате (например, выплату на карту в 1С)
+	ErrUnsupportedPayment = errors.New("payment is not supported by registry format")
+)
+
+// Payer — реквизиты компании, со счёта которой уходят платежи
+type Payer struct {
+	Name        string
+	INN         string
+	KPP         string
+	Account     string
+	BankName    string
+	BIK         string
+	CorrAccount string
+}
+
+// Recipient — получатель платежа: на счёт (Account, BIK) или на карту (CardNumber)
+type Recipient struct {
+	Name        string
+	INN         string
+	CardNumber  string
+	Account     string
+	BankName    string
+	BIK         string
+	CorrAccount string
+}
+
+// Payment — платёж по одной заявке; номер заявки служит номером платёжного документа,
+// по нему сопоставляется ответ банка
+type Payment struct {
+	PayoutID  int64
+	Amount    money.Amount
+	Recipient Recipient
+	Purpose   string
+}
+
+type Registry struct {
+	BatchID   int64
+	CreatedAt time.Time
+	Payer     Payer
+	Payments  []Payment
+}
+
+// Total — сумма всех платежей реестра
+func (r Registry) Total() money.Amount {
+	var total money.Amount
+	for _, payment := range r.Payments {
+		total += payment.Amount
+	}
+	return total
+}
+
+// Confirmation — ответ банка по одному платежу. Amount равен нулю, если банк сумму не прислал.
+type Confirmation struct {
+	PayoutID int64
+	Amount   money.Amount
+	Paid     bool
+	Reason   string
+}
+
+// Format пишет реестр в формате конкретного банка и читает его ответный файл
+type Format interface {
+	ContentType() string
+	Extension() string
+	Write(w io.Writer, registry Registry) error
+	// ReadConfirmation читает ответ банка на реестр, выгруженный со счёта payer
+	ReadConfirmation(r io.Reader, payer Payer) ([]Confirmation, error)
+}
+
+// File — выгруженный реестр, готовый к отдаче пользователю
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+var (
+	mu      sync.RWMutex
+	formats = map[string]Format{
+		"csv": CSV{},
+		"1c":  OneC{},
+	}
+)
+
+// Register добавляет формат реестра или заменяет существующий с тем же именем
+func Register(name string, format Format) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	formats[name] = format
+}
+
+func Lookup(name string) (Format, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	format, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+	}
+
+	return format, nil
+}
+
+// Names возвращает имена зарегистрированных форматов по алфавиту
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// decodeText читает файл банка как UTF-8, а если он им не является — как Windows-1251
+func decodeText(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+
+	decoded, err := charmap.Windows1251.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+
+	return string(decoded), nil
+}
@@ -0,0 +1,131 @@
+// Package requisites проверяет платёжные реквизиты по контрольным суммам:
+// ИНН, БИК, номер счёта (ключ по БИК) и номер карты (алгоритм Луна).
+package requisites
+
+import "strings"
+
+// Clean убирает пробелы и дефисы, которыми пользователи разбивают номера
+func Clean(value string) string {
+	return strings.NewReplacer(" ", "", "-", "", "\u00a0", "").Replace(strings.TrimSpace(value))
+}
+
+func digits(value string) ([]int, bool) {
+	result := make([]int, len(value))
+	for i, r := range value {
+		if r < '0' || r > '9' {
+			return nil, false
+		}
+		result[i] = int(r - '0')
+	}
+	return result, len(value) > 0
+}
+
+func weightedSum(d []int, weights []int) int {
+	sum := 0
+	for i, w := range weights {
+		sum += d[i] * w
+	}
+	return sum
+}
+
+// ValidINN проверяет ИНН организации (10 цифр) или физического лица (12 цифр)
+func ValidINN(inn string) bool {
+	d, ok := digits(inn)
+	if !ok {
+		return false
+	}
+
+	switch len(d) {
+	case 10:
+		return weightedSum(d, []int{2, 4, 10, 3, 5, 9, 4, 6, 8})%11%10 == d[9]
+	case 12:
+		n11 := weightedSum(d, []int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) % 11 % 10
+		n12 := weightedSum(d, []int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) % 11 % 10
+		return n11 == d[10] && n12 == d[11]
+	default:
+		return false
+	}
+}
+
+// ValidPersonINN проверяет ИНН физического лица, в том числе самозанятого
+func ValidPersonINN(inn string) bool {
+	return len(inn) == 12 && ValidINN(inn)
+}
+
+// ValidBIK проверяет формат БИК банка РФ: 9 цифр, начинается с 04
+func ValidBIK(bik string) bool {
+	_, ok := digits(bik)
+	return ok && len(bik) == 9 && strings.HasPrefix(bik, "04")
+}
+
+// ValidAccount проверяет контрольный ключ расчётного счёта в банке с указанным БИК
+func ValidAccount(account, bik string) bool {
+	if !ValidBIK(bik) {
+		return false
+	}
+
+	// Для счетов в подразделениях Банка России ключ считается по «0» и 5–6 цифрам БИК
+	prefix := bik[6:9]
+	if prefix == "000" || prefix == "001" || prefix == "002" {
+		prefix = "0" + bik[4:6]
+	}
+
+	return accountKeyValid(prefix + account)
+}
+
+// ValidCorrAccount проверяет корреспондентский счёт банка с указанным БИК
+func ValidCorrAccount(corrAccount, bik string) bool {
+	if !ValidBIK(bik) {
+		return false
+	}
+
+	return accountKeyValid("0" + bik[4:6] + corrAccount)
+}
+
+// accountKeyValid проверяет 23 цифры (3 от БИК и 20 счёта) весами 7-1-3
+func accountKeyValid(value string) bool {
+	d, ok := digits(value)
+	if !ok || len(d) != 23 {
+		return false
+	}
+
+	weights := []int{7, 1, 3}
+	sum := 0
+	for i, digit := range d {
+		sum += digit * weights[i%3] % 10
+	}
+
+	return sum%10 == 0
+}
+
+// ValidCard проверяет номер банковской карты (13–19 цифр) по алгоритму Луна
+func ValidCard(number string) bool {
+	d, ok := digits(number)
+	if !ok || len(d) < 13 || len(d) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := len(d) - 1; i >= 0; i-- {
+		digit := d[i]
+		if (len(d)-1-i)%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+// Mask оставляет видимыми последние visible символов значения
+func Mask(value string, visible int) string {
+	runes := []rune(value)
+	if len(runes) <= visible {
+		return strings.Repeat("*", len(runes))
+	}
+
+	return strings.Repeat("*", len(runes)-visible) + string(runes[len(runes)-visible:])
+}
@@ -0,0 +1,121 @@
+package requisites
+
+import "testing"
+
+func TestValidINN(t *testing.T) {
+	tests := []struct {
+		inn    string
+		valid  bool
+		person bool
+	}{
+		{"7707083893", true, false},
+		{"7707083894", false, false},
+		{"500100732259", true, true},
+		{"500100732258", false, false},
+		{"500100732269", false, false},
+		{"77070838", false, false},
+		{"77070838a3", false, false},
+		{"", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidINN(tt.inn); got != tt.valid {
+			t.Errorf("ValidINN(%q) = %v, want %v", tt.inn, got, tt.valid)
+		}
+		if got := ValidPersonINN(tt.inn); got != tt.person {
+			t.Errorf("ValidPersonINN(%q) = %v, want %v", tt.inn, got, tt.person)
+		}
+	}
+}
+
+func TestValidBIK(t *testing.T) {
+	tests := []struct {
+		bik   string
+		valid bool
+	}{
+		{"044525225", true},
+		{"044525000", true},
+		{"054525225", false},
+		{"04452522", false},
+		{"0445252250", false},
+		{"04452522a", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidBIK(tt.bik); got != tt.valid {
+			t.Errorf("ValidBIK(%q) = %v, want %v", tt.bik, got, tt.valid)
+		}
+	}
+}
+
+func TestValidAccount(t *testing.T) {
+	tests := []struct {
+		account string
+		bik     string
+		valid   bool
+	}{
+		{"40702810938000000001", "044525225", true},
+		{"40702810838000000001", "044525225", false},
+		{"40702810938000000001", "044525226", false},
+		{"40702810938000000001", "054525225", false},
+		// Счёт в подразделении Банка России: ключ по «0» и 5–6 цифрам БИК
+		{"03100643200000001700", "044525000", true},
+		{"03100643300000001700", "044525000", false},
+		{"4070281093800000000", "044525225", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidAccount(tt.account, tt.bik); got != tt.valid {
+			t.Errorf("ValidAccount(%q, %q) = %v, want %v", tt.account, tt.bik, got, tt.valid)
+		}
+	}
+}
+
+func TestValidCorrAccount(t *testing.T) {
+	tests := []struct {
+		corrAccount string
+		bik         string
+		valid       bool
+	}{
+		{"30101810400000000225", "044525225", true},
+		{"30101810500000000225", "044525225", false},
+		{"30101810400000000225", "054525225", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidCorrAccount(tt.corrAccount, tt.bik); got != tt.valid {
+			t.Errorf("ValidCorrAccount(%q, %q) = %v, want %v", tt.corrAccount, tt.bik, got, tt.valid)
+		}
+	}
+}
+
+func TestValidCard(t *testing.T) {
+	tests := []struct {
+		number string
+		valid  bool
+	}{
+		{"4111111111111111", true},
+		{"4111111111111112", false},
+		{"2200000000000004", true},
+		{"411111111111", false},
+		{"41111111111111111111", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidCard(tt.number); got != tt.valid {
+			t.Errorf("ValidCard(%q) = %v, want %v", tt.number, got, tt.valid)
+		}
+	}
+}
+
+func TestCleanAndMask(t *testing.T) {
+	if got := Clean(" 4111 1111-1111 1111 "); got != "4111111111111111" {
+		t.Errorf("Clean = %q", got)
+	}
+	if got := Mask("4111111111111111", 4); got != "************1111" {
+		t.Errorf("Mask = %q", got)
+	}
+	if got := Mask("123", 4); got != "***" {
+		t.Errorf("Mask short = %q", got)
+	}
+}
@@ -0,0 +1,100 @@
+// Package secret шифрует отдельные поля для хранения в БД (AES-256-GCM).
+// Каждое значение шифруется текущим ключом; идентификатор ключа хранится рядом
+// с данными, поэтому старые ключи продолжают расшифровывать записи до их перешифровки.
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown encryption key")
+	ErrDecrypt    = errors.New("cannot decrypt value")
+)
+
+// Keyring хранит набор ключей и знает, каким из них шифровать новые данные
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring принимает ключи в base64 (по 32 байта) по их идентификаторам
+// и идентификатор ключа, которым шифруются новые значения
+func NewKeyring(keys map[string]string, current string) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q is not in keys", current)
+	}
+
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, encoded := range keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q: must be 32 bytes, got %d", id, len(key))
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+
+		aeads[id] = aead
+	}
+
+	return &Keyring{current: current, aeads: aeads}, nil
+}
+
+// CurrentKeyID — идентификатор ключа, которым шифрует Encrypt
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// Encrypt шифрует значение текущим ключом. additionalData привязывает шифротекст
+// к месту хранения (например, к пользователю и полю): в другом месте он не расшифруется.
+func (k *Keyring) Encrypt(plaintext, additionalData string) (string, error) {
+	aead := k.aeads[k.current]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(additionalData))
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt расшифровывает значение ключом keyID
+func (k *Keyring) Decrypt(keyID, ciphertext, additionalData string) (string, error) {
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, data, []byte(additionalData))
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	return string(plaintext), nil
+}
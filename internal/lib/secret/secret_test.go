@@ -0,0 +1,98 @@
+package secret
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var (
+	testKeyV1 = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))
+	testKeyV2 = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32)))
+)
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    map[string]string
+		current string
+		wantErr bool
+	}{
+		{"valid", map[string]string{"v1": testKeyV1}, "v1", false},
+		{"current missing", map[string]string{"v1": testKeyV1}, "v2", true},
+		{"not base64", map[string]string{"v1": "not base64!"}, "v1", true},
+		{"short key", map[string]string{"v1": base64.StdEncoding.EncodeToString([]byte("short"))}, "v1", true},
+		{"invalid old key", map[string]string{"v1": "!!", "v2": testKeyV2}, "v2", true},
+	}
+
+	for _, tt := range tests {
+		_, err := NewKeyring(tt.keys, tt.current)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old, err := NewKeyring(map[string]string{"v1": testKeyV1}, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := old.Encrypt("40702810938000000001", "user:1:account")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// После ротации новые значения шифруются v2, а записи под v1 ещё читаются
+	rotated, err := NewKeyring(map[string]string{"v1": testKeyV1, "v2": testKeyV2}, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.CurrentKeyID() != "v2" {
+		t.Fatalf("CurrentKeyID = %q, want v2", rotated.CurrentKeyID())
+	}
+
+	plaintext, err := rotated.Decrypt(old.CurrentKeyID(), ciphertext, "user:1:account")
+	if err != nil {
+		t.Fatalf("decrypt with old key: %v", err)
+	}
+	if plaintext != "40702810938000000001" {
+		t.Fatalf("decrypted %q", plaintext)
+	}
+
+	reencrypted, err := rotated.Encrypt(plaintext, "user:1:account")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		keyring        *Keyring
+		keyID          string
+		ciphertext     string
+		additionalData string
+		wantErr        error
+	}{
+		{"new key", rotated, "v2", reencrypted, "user:1:account", nil},
+		{"key removed after rotation", old, "v2", reencrypted, "user:1:account", ErrUnknownKey},
+		{"wrong key id", rotated, "v1", reencrypted, "user:1:account", ErrDecrypt},
+		{"other field", rotated, "v2", reencrypted, "user:2:account", ErrDecrypt},
+		{"not base64", rotated, "v2", "!!", "user:1:account", ErrDecrypt},
+		{"too short", rotated, "v2", base64.StdEncoding.EncodeToString([]byte("x")), "user:1:account", ErrDecrypt},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.keyring.Decrypt(tt.keyID, tt.ciphertext, tt.additionalData)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != plaintext {
+			t.Errorf("%s: got %q, %v", tt.name, got, err)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package models
+
+// Address — адрес после стандартизации: строка в едином формате, код ФИАС, город и координаты
+type Address struct {
+	Value  string
+	FiasID string
+	City   string
+	GeoLat *float64
+	GeoLon *float64
+}
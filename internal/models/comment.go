@@ -2,10 +2,20 @@ package models
 
 import "time"
 
+// Источники комментариев к лиду
+const (
+	CommentSourceAgent   = "agent"
+	CommentSourceManager = "manager"
+	CommentSourceBitrix  = "bitrix"
+)
+
 type Comment struct {
-	ID        int64
-	LeadID    int64
-	UserID    int64
-	Text      string
-	CreatedAt *time.Time
+	ID              int64      `json:"id"`
+	LeadID          int64      `json:"lead_id"`
+	UserID          *int64     `json:"user_id"`
+	AuthorName      *string    `json:"author_name"`
+	Source          string     `json:"source"`
+	Text            string     `json:"text"`
+	BitrixCommentID *int64     `json:"bitrix_comment_id"`
+	CreatedAt       *time.Time `json:"created_at"`
 }
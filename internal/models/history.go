@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Действия в истории лида
+const (
+	HistoryActionCreated = "created"
+	HistoryActionUpdated = "updated"
+	// Изменение применено, но переход между статусами не предусмотрен жизненным циклом лида
+	HistoryActionInvalidTransition = "invalid_transition"
+)
+
+// Источники изменений лида
+const (
+	// Агент создал, изменил или отменил свой лид
+	HistorySourceAgent = "agent"
+	// Действие менеджера, например оплата заявки на вывод, закрывшая лид
+	HistorySourceManager = "manager"
+	// Вебхук Bitrix о смене сделки
+	HistorySourceWebhook = "webhook"
+	// Сверка лидов со сделками Bitrix
+	HistorySourceReconciliation = "reconciliation"
+)
+
+type HistoryChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+type LeadHistory struct {
+	ID        int64           `json:"id"`
+	LeadID    int64           `json:"lead_id"`
+	Action    string          `json:"action"`
+	Source    string          `json:"source"`
+	UserID    *int64          `json:"user_id"`
+	Changes   []HistoryChange `json:"changes"`
+	CreatedAt time.Time       `json:"created_at"`
+}
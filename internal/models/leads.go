@@ -1,24 +1,123 @@
 package models
 
-import "time"
+import (
+	"ia-online-golang/internal/lib/money"
+	"time"
+)
+
+// Состояния синхронизации лида со сделкой Bitrix
+const (
+	LeadSyncPending = "pending"
+	LeadSyncSynced  = "synced"
+	LeadSyncFailed  = "failed"
+)
 
 type Lead struct {
-	ID          int64    `json:"id"`
-	UserID      int64    `json:"user_id"`
-	FIO         string   `json:"fio"`
-	Address     string   `json:"address"`
-	StatusID    int64    `json:"status_id"`
-	PhoneNumber string   `json:"phone_number"`
-	Internet    bool     `json:"is_internet"`
-	Cleaning    bool     `json:"is_cleaning"`
-	Shipping    bool     `json:"is_shipping"`
-	Comments    []string `json:"comments"`
-
-	RewardInternet float64 `json:"reward_internet"`
-	RewardCleaning float64 `json:"reward_cleaning"`
-	RewardShipping float64 `json:"reward_shipping"`
+	ID           int64    `json:"id"`
+	UserID       int64    `json:"user_id"`
+	BitrixDealID *int64   `json:"bitrix_deal_id"`
+	SyncStatus   string   `json:"sync_status"`
+	FIO          string   `json:"fio"`
+	Address      string   `json:"address"`
+	StatusID     int64    `json:"status_id"`
+	PhoneNumber  string   `json:"phone_number"`
+	Internet     bool     `json:"is_internet"`
+	Cleaning     bool     `json:"is_cleaning"`
+	Shipping     bool     `json:"is_shipping"`
+	Comments     []string `json:"comments"`
+
+	AddressNormalized *string  `json:"address_normalized"`
+	FiasID            *string  `json:"fias_id"`
+	City              *string  `json:"city"`
+	GeoLat            *float64 `json:"geo_lat"`
+	GeoLon            *float64 `json:"geo_lon"`
+
+	RewardInternet money.Amount `json:"reward_internet"`
+	RewardCleaning money.Amount `json:"reward_cleaning"`
+	RewardShipping money.Amount `json:"reward_shipping"`
+
+	// Тарифы, действовавшие при создании лида; nil — тарифа не было
+	TariffInternet *money.Amount `json:"tariff_internet"`
+	TariffCleaning *money.Amount `json:"tariff_cleaning"`
+	TariffShipping *money.Amount `json:"tariff_shipping"`
 
 	CreatedAt   *time.Time `json:"created_at"`
 	CompletedAt *time.Time `json:"completed_at"`
 	PaymentAt   *time.Time `json:"payment_at"`
+	// Выплата, которой закрыто вознаграждение по лиду
+	PayoutID *int64 `json:"payout_id"`
+}
+
+// Поля сортировки списка лидов
+const (
+	LeadSortCreatedAt   = "created_at"
+	LeadSortStatus      = "status"
+	LeadSortRewardTotal = "reward_total"
+	LeadSortRelevance   = "relevance"
+)
+
+// Направления сортировки
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// LeadCursor — позиция keyset-пагинации: последний лид предыдущей страницы
+type LeadCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// SetAddress записывает в лид результат стандартизации адреса
+func (l *Lead) SetAddress(address Address) {
+	l.AddressNormalized = nullString(address.Value)
+	l.FiasID = nullString(address.FiasID)
+	l.City = nullString(address.City)
+	l.GeoLat = address.GeoLat
+	l.GeoLon = address.GeoLon
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// LeadFilter — условия выборки лидов; nil-поля не ограничивают выборку
+type LeadFilter struct {
+	StatusID     *int64
+	StartDate    *time.Time
+	EndDate      *time.Time
+	UserID       *int64
+	Search       *string
+	IsInternet   *bool
+	IsShipping   *bool
+	IsCleaning   *bool
+	Limit        int64
+	Offset       int64
+	WithComments bool
+
+	Sort   string
+	Order  string
+	Cursor *LeadCursor
+}
+
+// DuplicateCheck — условия поиска уже поданного лида на того же клиента.
+// ExcludeLeadID — сам изменяемый лид. Existing заполняется найденным лидом.
+type DuplicateCheck struct {
+	Since            time.Time
+	ExcludeStatusIDs []int64
+	ExcludeLeadID    int64
+	Existing         *Lead
+}
+
+// LeadDuplicate — отклонённая попытка подать лид на уже закреплённого клиента
+type LeadDuplicate struct {
+	ID          int64
+	LeadID      int64
+	UserID      int64
+	PhoneNumber string
+	Address     string
+	CreatedAt   *time.Time
 }
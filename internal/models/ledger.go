@@ -0,0 +1,82 @@
+package models
+
+import (
+	"ia-online-golang/internal/lib/money"
+	"time"
+)
+
+// Виды проводок
+const (
+	LedgerKindAccrual    = "accrual"
+	LedgerKindReversal   = "reversal"
+	LedgerKindPayout     = "payout"
+	LedgerKindAdjustment = "adjustment"
+)
+
+// Счета книги вознаграждений
+const (
+	// Задолженность перед агентом, по ней считается баланс
+	LedgerAccountAgent = "agent"
+	// Расходы компании на вознаграждения
+	LedgerAccountRewards = "rewards"
+	// Деньги, выплаченные агентам
+	LedgerAccountPayouts = "payouts"
+)
+
+// Статьи начислений
+const (
+	LedgerServiceInternet = "internet"
+	LedgerServiceCleaning = "cleaning"
+	LedgerServiceShipping = "shipping"
+	LedgerServiceReferral = "referral"
+	// Комиссия пригласившему с вознаграждений его рефералов
+	LedgerServiceCommission = "commission"
+	// Ручная корректировка менеджером
+	LedgerServiceAdjustment = "adjustment"
+)
+
+// LedgerTransaction — проводка; сумма Entries всегда равна нулю
+type LedgerTransaction struct {
+	ID          int64         `json:"id"`
+	Kind        string        `json:"kind"`
+	LeadID      *int64        `json:"lead_id"`
+	ReferralID  *int64        `json:"referral_id"`
+	PayoutID    *int64        `json:"payout_id"`
+	CreatedBy   *int64        `json:"created_by"`
+	Description string        `json:"description"`
+	CreatedAt   *time.Time    `json:"created_at"`
+	Entries     []LedgerEntry `json:"entries"`
+}
+
+// LedgerEntry — строка проводки по одному счёту. Kind, LeadID, ReferralID, PayoutID
+// и Description заполняются из проводки при выборке движений агента.
+type LedgerEntry struct {
+	ID            int64        `json:"id"`
+	TransactionID int64        `json:"transaction_id"`
+	Account       string       `json:"account"`
+	UserID        *int64       `json:"user_id"`
+	Service       *string      `json:"service"`
+	Amount        money.Amount `json:"amount"`
+	CreatedAt     *time.Time   `json:"created_at"`
+
+	Kind        string `json:"kind"`
+	LeadID      *int64 `json:"lead_id"`
+	ReferralID  *int64 `json:"referral_id"`
+	PayoutID    *int64 `json:"payout_id"`
+	Description string `json:"description"`
+}
+
+// LedgerBalance — сумма по счёту агента в разрезе статьи; Service пуст у выплат
+type LedgerBalance struct {
+	Service *string
+	Amount  money.Amount
+}
+
+// AgentTransfer возвращает пару строк проводки: amount на счёт агента и
+// встречную сумму на счёт counterAccount
+func AgentTransfer(userID int64, service *string, amount money.Amount, counterAccount string) []LedgerEntry {
+	return []LedgerEntry{
+		{Account: LedgerAccountAgent, UserID: &userID, Service: service, Amount: amount},
+		{Account: counterAccount, Service: service, Amount: -amount},
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Действия, которые outbox отправляет в Bitrix
+const (
+	OutboxActionCreateDeal = "create_deal"
+	OutboxActionUpdateDeal = "update_deal"
+	OutboxActionMoveDeal   = "move_deal"
+	OutboxActionAddComment = "add_comment"
+)
+
+// Состояния записи outbox
+const (
+	OutboxStatusPending    = "pending"
+	OutboxStatusProcessing = "processing"
+	OutboxStatusDone       = "done"
+	OutboxStatusDead       = "dead"
+)
+
+// MoveDealPayload — данные события move_deal: статус, в стадию которого переводится сделка
+type MoveDealPayload struct {
+	StatusID int64 `json:"status_id"`
+}
+
+// AddCommentPayload — данные события add_comment: комментарий для таймлайна сделки
+type AddCommentPayload struct {
+	CommentID int64 `json:"comment_id"`
+}
+
+type OutboxEvent struct {
+	ID            int64           `json:"id"`
+	LeadID        int64           `json:"lead_id"`
+	Action        string          `json:"action"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     *string         `json:"last_error"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Способы выплаты агенту
+const (
+	PaymentMethodCard    = "card"
+	PaymentMethodAccount = "account"
+)
+
+// PaymentDetails — платёжные реквизиты агента в открытом виде.
+// Masked показывает, что ИНН, карта и счёт скрыты, кроме последних цифр.
+type PaymentDetails struct {
+	UserID       int64      `json:"user_id"`
+	Method       string     `json:"method"`
+	FullName     string     `json:"full_name"`
+	SelfEmployed bool       `json:"self_employed"`
+	INN          string     `json:"inn"`
+	CardNumber   *string    `json:"card_number"`
+	Account      *string    `json:"account"`
+	BIK          *string    `json:"bik"`
+	BankName     *string    `json:"bank_name"`
+	CorrAccount  *string    `json:"corr_account"`
+	Masked       bool       `json:"masked"`
+	CreatedAt    *time.Time `json:"created_at"`
+	UpdatedAt    *time.Time `json:"updated_at"`
+}
+
+// PaymentDetailsRecord — реквизиты в том виде, в каком они лежат в БД:
+// ИНН, карта и счёт зашифрованы ключом KeyID
+type PaymentDetailsRecord struct {
+	UserID              int64
+	Method              string
+	FullName            string
+	SelfEmployed        bool
+	INNEncrypted        string
+	CardNumberEncrypted *string
+	AccountEncrypted    *string
+	BIK                 *string
+	BankName            *string
+	CorrAccount         *string
+	KeyID               string
+	CreatedAt           *time.Time
+	UpdatedAt           *time.Time
+}
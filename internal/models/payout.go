@@ -0,0 +1,94 @@
+package models
+
+import (
+	"ia-online-golang/internal/lib/money"
+	"time"
+)
+
+// Статусы заявки на выплату
+const (
+	PayoutStatusRequested = "requested"
+	PayoutStatusApproved  = "approved"
+	PayoutStatusRejected  = "rejected"
+	PayoutStatusPaid      = "paid"
+)
+
+type Payout struct {
+	ID           int64         `json:"id"`
+	UserID       int64         `json:"user_id"`
+	Amount       money.Amount  `json:"amount"`
+	Status       string        `json:"status"`
+	Comment      *string       `json:"comment"`
+	RejectReason *string       `json:"reject_reason"`
+	ProcessedBy  *int64        `json:"processed_by"`
+	CreatedAt    *time.Time    `json:"created_at"`
+	UpdatedAt    *time.Time    `json:"updated_at"`
+	ApprovedAt   *time.Time    `json:"approved_at"`
+	PaidAt       *time.Time    `json:"paid_at"`
+	BatchID      *int64        `json:"batch_id"`
+	Events       []PayoutEvent `json:"events,omitempty"`
+}
+
+// PayoutEvent — шаг обработки заявки; UserID — кто его сделал
+type PayoutEvent struct {
+	ID         int64      `json:"id"`
+	PayoutID   int64      `json:"payout_id"`
+	UserID     *int64     `json:"user_id"`
+	FromStatus *string    `json:"from_status"`
+	ToStatus   string     `json:"to_status"`
+	Comment    *string    `json:"comment"`
+	CreatedAt  *time.Time `json:"created_at"`
+}
+
+type PayoutFilter struct {
+	UserID *int64
+	Status *string
+	Limit  int64
+	Offset int64
+}
+
+// PayoutFunds — деньги агента для вывода. Held — начисления моложе срока удержания,
+// Reserved — сумма незакрытых заявок.
+type PayoutFunds struct {
+	Balance  money.Amount
+	Held     money.Amount
+	Reserved money.Amount
+}
+
+// Available — сколько агент может запросить прямо сейчас
+func (f PayoutFunds) Available() money.Amount {
+	available := f.Balance - f.Held - f.Reserved
+	if available < 0 {
+		return 0
+	}
+	return available
+}
+
+// Статусы пачки выплат
+const (
+	PayoutBatchStatusCreated   = "created"
+	PayoutBatchStatusExported  = "exported"
+	PayoutBatchStatusCompleted = "completed"
+)
+
+// PayoutBatch — одобренные заявки, отправляемые в банк одним реестром.
+// Count и Total считаются по всем заявкам пачки.
+type PayoutBatch struct {
+	ID          int64             `json:"id"`
+	Status      string            `json:"status"`
+	CreatedBy   *int64            `json:"created_by"`
+	CreatedAt   *time.Time        `json:"created_at"`
+	ExportedAt  *time.Time        `json:"exported_at"`
+	CompletedAt *time.Time        `json:"completed_at"`
+	Count       int64             `json:"count"`
+	Total       money.Amount      `json:"total"`
+	Payouts     []PayoutBatchItem `json:"payouts,omitempty"`
+}
+
+// PayoutBatchItem — заявка из пачки вместе с данными агента-получателя
+type PayoutBatchItem struct {
+	Payout
+	UserName  string `json:"user_name"`
+	UserEmail string `json:"user_email"`
+	UserPhone string `json:"user_phone"`
+}
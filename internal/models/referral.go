@@ -1,17 +1,121 @@
 package models
 
-import "time"
+import (
+	"ia-online-golang/internal/lib/money"
+	"time"
+)
 
 type Referral struct {
 	ID           int64
 	UserID       int64
 	ReferralCode string
-	Cost         float64
+	Cost         money.Amount
 	CreatedAt    time.Time
 	Active       bool
+	ActivatedAt  *time.Time
+	// Срок выполнения условий активации истёк
+	ExpiredAt *time.Time
+}
+
+// Состояния реферала в программе
+const (
+	ReferralStatusPending = "pending"
+	ReferralStatusActive  = "active"
+	ReferralStatusExpired = "expired"
+)
+
+// Status возвращает состояние реферала: активирован, ждёт выполнения условий или истёк
+func (r Referral) Status() string {
+	switch {
+	case r.Active:
+		return ReferralStatusActive
+	case r.ExpiredAt != nil:
+		return ReferralStatusExpired
+	default:
+		return ReferralStatusPending
+	}
 }
 
 type ReferralAndUser struct {
 	Referral Referral
 	User     User
+	// Комиссия, полученная пригласившим с вознаграждений реферала
+	Commission money.Amount
+}
+
+// CommissionRates — доли вознаграждений агента, которые получают пригласившие его, по уровням
+// цепочки в сотых долях процента: [1000, 300] — 10% прямому пригласившему и 3% следующему
+type CommissionRates []int64
+
+// Commission считает комиссию уровня level (с 1) с суммы amount с округлением до копейки
+func (r CommissionRates) Commission(level int, amount money.Amount) money.Amount {
+	if level < 1 || level > len(r) {
+		return 0
+	}
+
+	value := amount.Kopecks() * r[level-1]
+	half := int64(5000)
+	if value < 0 {
+		half = -half
+	}
+
+	return money.FromKopecks((value + half) / 10000)
+}
+
+// ReferralRule — условия активации реферала: MinLeads лидов в статусах StatusIDs с суммой
+// вознаграждений не меньше MinReward, поданных за Window с регистрации (0 — без срока)
+type ReferralRule struct {
+	MinLeads  int64
+	StatusIDs []int64
+	Window    time.Duration
+	MinReward money.Amount
+}
+
+// ReferralProgress — сколько неактивный реферал уже выполнил по условиям активации
+type ReferralProgress struct {
+	Referral Referral
+	Leads    int64
+	Reward   money.Amount
+}
+
+// Qualifies сообщает, выполнены ли условия активации
+func (r ReferralRule) Qualifies(progress ReferralProgress) bool {
+	return progress.Leads >= r.MinLeads && progress.Reward >= r.MinReward
+}
+
+// Expired сообщает, что срок выполнения условий прошёл
+func (r ReferralRule) Expired(progress ReferralProgress, now time.Time) bool {
+	return r.Window > 0 && now.After(progress.Referral.CreatedAt.Add(r.Window))
+}
+
+// ReferralNode — реферал в сети агента с его показателями. Earned — сколько агент,
+// чью сеть смотрят, получил с этого реферала: вознаграждение за него и комиссии с его лидов.
+type ReferralNode struct {
+	Referral  Referral
+	User      User
+	InviterID int64
+	Leads     int64
+	Progress  ReferralProgress
+	Earned    money.Amount
+}
+
+// ReferralNodeFilter выбирает рефералов, приглашённых агентами InviterIDs.
+// Limit 0 — без ограничения.
+type ReferralNodeFilter struct {
+	InviterIDs []int64
+	// Агент, для которого считается Earned
+	RootID int64
+	Rule   ReferralRule
+	Limit  int64
+	Offset int64
+}
+
+// ReferralSummary — итоги по прямым рефералам агента
+type ReferralSummary struct {
+	Total   int64
+	Active  int64
+	Pending int64
+	Expired int64
+	// Всё, что агент получил по реферальной программе: за рефералов и комиссии
+	Earned money.Amount
 }
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// ReferralClick — переход по реферальной ссылке агента InviterID
+type ReferralClick struct {
+	ID           int64
+	Token        string
+	InviterID    int64
+	ReferralCode string
+	UserAgent    *string
+	// SHA-256 от соли и IP посетителя
+	IPHash      *string
+	UTMSource   *string
+	UTMMedium   *string
+	UTMCampaign *string
+	UTMTerm     *string
+	UTMContent  *string
+	CreatedAt   time.Time
+	// Пользователь, зарегистрировавшийся после перехода
+	RegisteredUserID *int64
+	RegisteredAt     *time.Time
+}
+
+// ReferralFunnelFilter выбирает агентов для воронки и период: переходы и регистрации
+// считаются по дате в [From, To). Без InviterID — все агенты с переходами или рефералами.
+type ReferralFunnelFilter struct {
+	InviterID    *int64
+	From         *time.Time
+	To           *time.Time
+	PaidStatusID int64
+}
+
+// ReferralFunnel — воронка агента: переходы → регистрации → активации → первый оплаченный лид
+type ReferralFunnel struct {
+	User     User
+	Clicks   int64
+	Visitors int64
+	// Регистрации, пришедшие с переходов по ссылке
+	ClickRegistrations int64
+	// Все регистрации по коду агента, включая введённый вручную
+	Registrations int64
+	Activated     int64
+	// Рефералы, у которых есть хотя бы один оплаченный лид
+	FirstPaidLead int64
+}
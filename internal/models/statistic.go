@@ -0,0 +1,35 @@
+package models
+
+import (
+	"ia-online-golang/internal/lib/money"
+	"time"
+)
+
+// Шаг временного ряда статистики; совпадает с единицами date_trunc в PostgreSQL
+const (
+	StatisticIntervalDay   = "day"
+	StatisticIntervalWeek  = "week"
+	StatisticIntervalMonth = "month"
+)
+
+// StatisticFilter выбирает период [From, To) и агента; без UserID — все агенты
+type StatisticFilter struct {
+	UserID   *int64
+	From     time.Time
+	To       time.Time
+	Interval string
+}
+
+// EarningsPoint — начисления по статье за шаг, начинающийся в Bucket
+type EarningsPoint struct {
+	Bucket  time.Time
+	Service string
+	Amount  money.Amount
+}
+
+// LeadStatusPoint — число лидов, поданных за шаг Bucket и находящихся сейчас в статусе Status
+type LeadStatusPoint struct {
+	Bucket time.Time
+	Status string
+	Count  int64
+}
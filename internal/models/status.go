@@ -0,0 +1,16 @@
+package models
+
+type Status struct {
+	ID         int64         `json:"id"`
+	Name       string        `json:"name"`
+	BitrixName string        `json:"bitrix_name"`
+	Stages     []StatusStage `json:"stages"`
+}
+
+// StatusStage — стадия сделки Bitrix, соответствующая статусу лида
+type StatusStage struct {
+	ID         int64  `json:"id"`
+	StatusID   int64  `json:"status_id"`
+	CategoryID int64  `json:"category_id"`
+	StageCode  string `json:"stage_code"`
+}
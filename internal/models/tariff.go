@@ -0,0 +1,29 @@
+package models
+
+import (
+	"ia-online-golang/internal/lib/money"
+	"time"
+)
+
+// Tariff — вознаграждение агента за услугу в городе на период. Service — статья начислений
+// (LedgerServiceInternet, ..., LedgerServiceReferral). City == nil — тариф для всех городов,
+// ValidTo == nil — бессрочный. Тариф города важнее общего.
+type Tariff struct {
+	ID        int64        `json:"id"`
+	Service   string       `json:"service"`
+	City      *string      `json:"city"`
+	Amount    money.Amount `json:"amount"`
+	ValidFrom time.Time    `json:"valid_from"`
+	ValidTo   *time.Time   `json:"valid_to"`
+	CreatedBy *int64       `json:"created_by"`
+	CreatedAt *time.Time   `json:"created_at"`
+	UpdatedAt *time.Time   `json:"updated_at"`
+}
+
+// TariffFilter — условия выборки тарифов; nil-поля не ограничивают выборку.
+// At оставляет тарифы, действующие в этот момент.
+type TariffFilter struct {
+	Service *string
+	City    *string
+	At      *time.Time
+}
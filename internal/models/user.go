@@ -15,7 +15,17 @@ type User struct {
 	City         string
 	PasswordHash string
 	ReferralCode string
-	CreatedAt    time.Time
-	Roles        pq.StringArray
-	IsActive     bool
+	// Код, выбранный агентом; UUID из ReferralCode остаётся рабочим
+	VanityCode *string
+	CreatedAt  time.Time
+	Roles      pq.StringArray
+	IsActive   bool
+}
+
+// Code возвращает код для ссылок: выбранный агентом, а если его нет — UUID
+func (u User) Code() string {
+	if u.VanityCode != nil {
+		return *u.VanityCode
+	}
+	return u.ReferralCode
 }
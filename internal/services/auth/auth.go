@@ -8,11 +8,13 @@ import (
 
 	"ia-online-golang/internal/services/email"
 	"ia-online-golang/internal/services/passwordcode"
+	"ia-online-golang/internal/services/tariff"
 	"ia-online-golang/internal/services/token"
 	UserService "ia-online-golang/internal/services/user"
 	"ia-online-golang/internal/utils"
 
 	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/lib/money"
 	"ia-online-golang/internal/models"
 	"ia-online-golang/internal/storage"
 
@@ -32,6 +34,8 @@ type AuthService struct {
 	EmailService             email.EmailServiceI
 	UserService              UserService.UserServiceI
 	PasswordCodeService      passwordcode.PasswordCodeServiceI
+	TariffService            tariff.TariffServiceI
+	ReferralClickRepository  storage.ReferralClickRepositoryI
 }
 
 type AuthServiceI interface {
@@ -62,7 +66,8 @@ func New(
 	emailService email.EmailServiceI,
 	userService UserService.UserServiceI,
 	passwordCodeService passwordcode.PasswordCodeServiceI,
-
+	tariffService tariff.TariffServiceI,
+	referralClickRepo storage.ReferralClickRepositoryI,
 ) *AuthService {
 	return &AuthService{
 		log:                      log,
@@ -74,6 +79,8 @@ func New(
 		TokenService:             tokenService,
 		EmailService:             emailService,
 		UserService:              userService,
+		TariffService:            tariffService,
+		ReferralClickRepository:  referralClickRepo,
 	}
 }
 
@@ -81,7 +88,7 @@ func (a *AuthService) RegistrationUser(ctx context.Context, registerDTO dto.Regi
 	const op = "AuthService.RegistrationUser"
 
 	if registerDTO.ReferralCode != "" {
-		_, err := a.UserRepository.UserByReferralCode(ctx, registerDTO.ReferralCode)
+		inviter, err := a.UserRepository.UserByReferralCode(ctx, registerDTO.ReferralCode)
 		if err != nil {
 			if errors.Is(err, storage.ErrUserNotFound) {
 				return dto.AuthTokensDTO{}, fmt.Errorf("%s: %w", op, ErrReferralIdNotFound)
@@ -89,6 +96,19 @@ func (a *AuthService) RegistrationUser(ctx context.Context, registerDTO dto.Regi
 
 			return dto.AuthTokensDTO{}, fmt.Errorf("%s: %w", op, err)
 		}
+
+		// Код мог быть выбранным агентом, реферал всегда привязывается к UUID
+		registerDTO.ReferralCode = inviter.ReferralCode
+	}
+
+	// Переход по реферальной ссылке засчитывается, если пользователь не ввёл другой код
+	click := a.referralClick(ctx, registerDTO.ClickToken)
+	if click != nil {
+		if registerDTO.ReferralCode == "" {
+			registerDTO.ReferralCode = click.ReferralCode
+		} else if registerDTO.ReferralCode != click.ReferralCode {
+			click = nil
+		}
 	}
 
 	passHash, err := bcrypt.GenerateFromPassword([]byte(registerDTO.Password), bcrypt.DefaultCost)
@@ -110,7 +130,20 @@ func (a *AuthService) RegistrationUser(ctx context.Context, registerDTO dto.Regi
 	}
 
 	if registerDTO.ReferralCode != "" {
-		err = a.ReferralRepository.SaveReferral(ctx, *userDTO.ID, registerDTO.ReferralCode)
+		// Вознаграждение за реферала фиксируется по тарифу его города на момент регистрации
+		cost, err := a.TariffService.Amount(ctx, models.LedgerServiceReferral, &registerDTO.City, time.Now())
+		if err != nil {
+			a.log.Error(err)
+
+			return dto.AuthTokensDTO{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		var referralCost money.Amount
+		if cost != nil {
+			referralCost = *cost
+		}
+
+		err = a.ReferralRepository.SaveReferral(ctx, *userDTO.ID, registerDTO.ReferralCode, referralCost)
 		if err != nil {
 			a.log.Error(err)
 
@@ -118,6 +151,12 @@ func (a *AuthService) RegistrationUser(ctx context.Context, registerDTO dto.Regi
 		}
 	}
 
+	if click != nil {
+		if err := a.ReferralClickRepository.ConvertReferralClick(ctx, click.ID, *userDTO.ID); err != nil {
+			a.log.Warnf("%s: referral click %d: %v", op, click.ID, err)
+		}
+	}
+
 	err = a.SendActivationLink(ctx, *userDTO.ID, registerDTO.Email)
 	if err != nil {
 		a.log.Error(err)
@@ -135,6 +174,39 @@ func (a *AuthService) RegistrationUser(ctx context.Context, registerDTO dto.Regi
 	return tokens, nil
 }
 
+// referralClick находит незасчитанный переход по токену из cookie и актуальный код агента.
+// Устаревший или чужой токен не мешает регистрации и просто не учитывается.
+func (a *AuthService) referralClick(ctx context.Context, token string) *models.ReferralClick {
+	const op = "AuthService.referralClick"
+
+	if token == "" {
+		return nil
+	}
+
+	click, err := a.ReferralClickRepository.ReferralClickByToken(ctx, token)
+	if err != nil {
+		if !errors.Is(err, storage.ErrReferralClickNotFound) {
+			a.log.Warnf("%s: %v", op, err)
+		}
+		return nil
+	}
+
+	if click.RegisteredUserID != nil {
+		return nil
+	}
+
+	inviter, err := a.UserRepository.UserById(ctx, click.InviterID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrUserNotFound) {
+			a.log.Warnf("%s: %v", op, err)
+		}
+		return nil
+	}
+	click.ReferralCode = inviter.ReferralCode
+
+	return &click
+}
+
 func (a *AuthService) LoginUser(ctx context.Context, loginDTO dto.LoginUserDTO) (dto.AuthTokensDTO, error) {
 	const op = "AuthService.LoginUser"
 
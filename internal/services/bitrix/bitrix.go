@@ -4,31 +4,56 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"ia-online-golang/internal/config"
 	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/lib/money"
+	"ia-online-golang/internal/models"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 // Структура EmailService для хранения настроек SMTP
 type BitrixService struct {
-	log     *logrus.Logger
-	webhook string
+	log      *logrus.Logger
+	client   *http.Client
+	webhook  string
+	deal     config.BitrixDealConfig
+	fields   config.BitrixFieldsConfig
+	services config.BitrixServicesConfig
 }
 
 type BitrixServiceI interface {
 	GetLead(ctx context.Context, id_deal int64) (ReturnDataDeal, error)
-	SendDeal(ctx context.Context, lead dto.LeadDTO, user dto.UserDTO) (ReturnDataCreate, error)
-	SendContact(ctx context.Context, dto dto.LeadDTO) (ReturnDataCreate, error)
+	ListDeals(ctx context.Context, start int) (ReturnDataDealList, error)
+	DealByOrigin(ctx context.Context, originID string) (int64, error)
+	SendDeal(ctx context.Context, originID string, lead dto.LeadDTO, user dto.UserDTO) (ReturnDataCreate, error)
+	SendContact(ctx context.Context, originID string, dto dto.LeadDTO) (ReturnDataCreate, error)
+	UpdateDeal(ctx context.Context, dealID int64, lead dto.LeadDTO) error
+	MoveDeal(ctx context.Context, dealID int64, status models.Status) error
+	AddTimelineComment(ctx context.Context, dealID int64, text string) (int64, error)
+	TimelineComment(ctx context.Context, id int64) (TimelineComment, error)
 }
 
+var (
+	ErrStageNotFound = errors.New("status has no stage in deal category")
+)
+
 // Конструктор для создания нового экземпляра EmailService
-func New(log *logrus.Logger, webhook string) *BitrixService {
+func New(log *logrus.Logger, cfg config.BitrixConfig) *BitrixService {
 	return &BitrixService{
-		log:     log,
-		webhook: webhook,
+		log:      log,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		webhook:  cfg.IncomingWebhook,
+		deal:     cfg.Deal,
+		fields:   cfg.Fields,
+		services: cfg.Services,
 	}
 }
 
@@ -39,153 +64,364 @@ func (b *BitrixService) GetLead(ctx context.Context, id_deal int64) (ReturnDataD
 		"ID": id_deal,
 	}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return ReturnDataDeal{}, fmt.Errorf("%s: %v", op, err)
+	var raw struct {
+		Result map[string]any `json:"result"`
+		Time   TimeInfo       `json:"time"`
+	}
+	if err := b.call(ctx, "crm.deal.get", data, &raw); err != nil {
+		return ReturnDataDeal{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	url := b.webhook + "crm.deal.get"
+	return ReturnDataDeal{Result: b.infoDeal(raw.Result), Time: raw.Time}, nil
+}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return ReturnDataDeal{}, fmt.Errorf("%s: %v", op, err)
-	}
-	defer resp.Body.Close()
+// ListDeals возвращает страницу сделок воронки, начиная с позиции start
+func (b *BitrixService) ListDeals(ctx context.Context, start int) (ReturnDataDealList, error) {
+	const op = "BitrixService.ListDeals"
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return ReturnDataDeal{}, fmt.Errorf("%s: %v", op, err)
+	data := map[string]any{
+		"filter": map[string]any{
+			"CATEGORY_ID": b.deal.CategoryID,
+		},
+		"select": []string{
+			"ID", "TITLE", "STAGE_ID", "CATEGORY_ID", "CONTACT_ID",
+			b.fields.RewardInternet, b.fields.RewardCleaning, b.fields.RewardShipping,
+		},
+		"order": map[string]string{
+			"ID": "ASC",
+		},
+		"start": start,
 	}
 
-	// Попробуем сначала распарсить как ошибку
-	var errData ErrorData
-	if err := json.Unmarshal(body, &errData); err == nil && errData.Error != "" {
-		return ReturnDataDeal{}, fmt.Errorf("%s: %s - %s", op, errData.Error, errData.ErrorDescription)
+	var raw struct {
+		Result []map[string]any `json:"result"`
+		Next   int              `json:"next"`
+		Total  int              `json:"total"`
+	}
+	if err := b.call(ctx, "crm.deal.list", data, &raw); err != nil {
+		return ReturnDataDealList{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	// Если ошибки нет — пробуем распарсить как успешный ответ
-	var result ReturnDataDeal
-	if err := json.Unmarshal(body, &result); err != nil {
-		return ReturnDataDeal{}, fmt.Errorf("%s: %v", op, err)
+	result := ReturnDataDealList{
+		Next:  raw.Next,
+		Total: raw.Total,
+	}
+	for _, deal := range raw.Result {
+		result.Result = append(result.Result, b.infoDeal(deal))
 	}
 
 	return result, nil
 }
 
-func (b *BitrixService) SendDeal(ctx context.Context, lead dto.LeadDTO, user dto.UserDTO) (ReturnDataCreate, error) {
+// infoDeal собирает InfoDeal из полей сделки по настроенным кодам UF_CRM_*
+func (b *BitrixService) infoDeal(raw map[string]any) InfoDeal {
+	return InfoDeal{
+		ID:              fieldString(raw, "ID"),
+		Title:           fieldString(raw, "TITLE"),
+		Status:          fieldString(raw, "STAGE_ID"),
+		CategoryID:      fieldString(raw, "CATEGORY_ID"),
+		ContactID:       fieldString(raw, "CONTACT_ID"),
+		InternetPayment: fieldString(raw, b.fields.RewardInternet),
+		CleaningPayment: fieldString(raw, b.fields.RewardCleaning),
+		ShippingPayment: fieldString(raw, b.fields.RewardShipping),
+	}
+}
+
+func fieldString(raw map[string]any, code string) string {
+	value, ok := raw[code]
+	if !ok || value == nil {
+		return ""
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ParseAmount разбирает значение денежного поля Bitrix вида "1500" или "1500|RUB"
+func ParseAmount(value string) (money.Amount, error) {
+	amount, _, _ := strings.Cut(value, "|")
+	return money.Parse(amount)
+}
+
+// DealByOrigin ищет сделку по внешнему ID, с которым её создал SendDeal. Возвращает 0, если сделки нет.
+func (b *BitrixService) DealByOrigin(ctx context.Context, originID string) (int64, error) {
+	const op = "BitrixService.DealByOrigin"
+
+	id, err := b.findByOrigin(ctx, "crm.deal.list", originID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SendDeal создаёт сделку с контактом. originID записывается в ORIGIN_ID обоих,
+// поэтому повторная попытка находит уже созданный контакт, а сделку — DealByOrigin.
+func (b *BitrixService) SendDeal(ctx context.Context, originID string, lead dto.LeadDTO, user dto.UserDTO) (ReturnDataCreate, error) {
 	op := "BitrixService.SendLead"
 
-	contact, err := b.SendContact(ctx, lead)
+	contact, err := b.SendContact(ctx, originID, lead)
 	if err != nil {
 		return ReturnDataCreate{}, fmt.Errorf("%s: %v", op, err)
 	}
 
+	data := map[string]any{
+		"fields": map[string]any{
+			"TITLE":                 b.deal.Title,
+			"TYPE_ID":               b.deal.TypeID,
+			"STAGE_ID":              b.deal.StageNew,
+			"IS_MANUAL_OPPORTUNITY": "Y",
+			"CATEGORY_ID":           b.deal.CategoryID,
+			"CONTACT_ID":            contact.Result,
+			"ORIGIN_ID":             originID,
+			b.fields.Address:        lead.Address,
+			b.fields.Services:       b.serviceIDs(lead),
+			b.fields.Comment:        lead.Comment,
+			b.fields.AgentCity:      user.City,
+			b.fields.AgentName:      user.Name,
+			b.fields.AgentPhone:     user.PhoneNumber,
+			b.fields.AgentID:        user.ID,
+		},
+	}
+
+	var result ReturnDataCreate
+	if err := b.call(ctx, "crm.deal.add", data, &result); err != nil {
+		return ReturnDataCreate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// serviceIDs возвращает ID элементов списка услуг, выбранных в лиде
+func (b *BitrixService) serviceIDs(lead dto.LeadDTO) []int {
 	var services []int
 	if lead.IsInternet {
-		services = append(services, 510)
+		services = append(services, b.services.Internet)
 	}
 	if lead.IsCleaning {
-		services = append(services, 512)
+		services = append(services, b.services.Cleaning)
 	}
 	if lead.IsShipping {
-		services = append(services, 514)
+		services = append(services, b.services.Shipping)
+	}
+	return services
+}
+
+// SendContact создаёт контакт клиента или возвращает контакт, уже созданный с тем же originID
+func (b *BitrixService) SendContact(ctx context.Context, originID string, dto dto.LeadDTO) (ReturnDataCreate, error) {
+	op := "BitrixService.SendContact"
+
+	existing, err := b.findByOrigin(ctx, "crm.contact.list", originID)
+	if err != nil {
+		return ReturnDataCreate{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if existing != 0 {
+		return ReturnDataCreate{Result: int(existing)}, nil
 	}
 
 	data := map[string]any{
 		"fields": map[string]any{
-			"TITLE":                 "Заявка с сайта ia-on.ru",
-			"TYPE_ID":               "SALE",
-			"STAGE_ID":              "C42:NEW",
-			"IS_MANUAL_OPPORTUNITY": "Y",
-			"CATEGORY_ID":           42,
-			"CONTACT_ID":            contact.Result,
-			"UF_CRM_1697646751446":  lead.Address,
-			"UF_CRM_1743744405443":  services,
-			"UF_CRM_1697294923031":  lead.Comment,
-			"UF_CRM_1703703644316":  user.City,
-			"UF_CRM_1697357613372":  user.Name,
-			"UF_CRM_1700909419606":  user.PhoneNumber,
-			"UF_CRM_1701035680304":  user.ID,
+			"NAME":      dto.Name,
+			"ORIGIN_ID": originID,
+			"PHONE": []map[string]string{
+				{
+					"VALUE":      dto.PhoneNumber,
+					"VALUE_TYPE": "WORK",
+				},
+			},
 		},
 	}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return ReturnDataCreate{}, fmt.Errorf("%s: %v", op, err)
+	var result ReturnDataCreate
+	if err := b.call(ctx, "crm.contact.add", data, &result); err != nil {
+		return ReturnDataCreate{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	url := b.webhook + "crm.deal.add"
+	return result, nil
+}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return ReturnDataCreate{}, fmt.Errorf("%s: %v", op, err)
+// findByOrigin возвращает ID первой сущности списка method с данным ORIGIN_ID или 0
+func (b *BitrixService) findByOrigin(ctx context.Context, method string, originID string) (int64, error) {
+	data := map[string]any{
+		"filter": map[string]any{
+			"ORIGIN_ID": originID,
+		},
+		"select": []string{"ID"},
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var result struct {
+		Result []map[string]any `json:"result"`
+	}
+	if err := b.call(ctx, method, data, &result); err != nil {
+		return 0, err
+	}
+
+	if len(result.Result) == 0 {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(fieldString(result.Result[0], "ID"), 10, 64)
+}
+
+// UpdateDeal переносит в сделку и её контакт изменённые агентом данные лида
+func (b *BitrixService) UpdateDeal(ctx context.Context, dealID int64, lead dto.LeadDTO) error {
+	const op = "BitrixService.UpdateDeal"
+
+	deal, err := b.GetLead(ctx, dealID)
 	if err != nil {
-		return ReturnDataCreate{}, fmt.Errorf("%s: %v", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	// Попробуем сначала распарсить как ошибку
-	var errData ErrorData
-	if err := json.Unmarshal(body, &errData); err == nil && errData.Error != "" {
-		return ReturnDataCreate{}, fmt.Errorf("%s: %s - %s", op, errData.Error, errData.ErrorDescription)
+	if deal.Result.ContactID != "" && deal.Result.ContactID != "0" {
+		contact := map[string]any{
+			"id": deal.Result.ContactID,
+			"fields": map[string]any{
+				"NAME": lead.Name,
+				"PHONE": []map[string]string{
+					{
+						"VALUE":      lead.PhoneNumber,
+						"VALUE_TYPE": "WORK",
+					},
+				},
+			},
+		}
+		if err := b.call(ctx, "crm.contact.update", contact, nil); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 	}
 
-	// Если ошибки нет — пробуем распарсить как успешный ответ
-	var result ReturnDataCreate
-	if err := json.Unmarshal(body, &result); err != nil {
-		return ReturnDataCreate{}, fmt.Errorf("%s: %v", op, err)
+	data := map[string]any{
+		"id": dealID,
+		"fields": map[string]any{
+			b.fields.Address:  lead.Address,
+			b.fields.Services: b.serviceIDs(lead),
+		},
+	}
+	if err := b.call(ctx, "crm.deal.update", data, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return result, nil
+	return nil
 }
 
-func (b *BitrixService) SendContact(ctx context.Context, dto dto.LeadDTO) (ReturnDataCreate, error) {
-	op := "BitrixService.SendContact"
+// MoveDeal переводит сделку в стадию, сопоставленную статусу в воронке из конфигурации
+func (b *BitrixService) MoveDeal(ctx context.Context, dealID int64, status models.Status) error {
+	const op = "BitrixService.MoveDeal"
+
+	stageID := ""
+	for _, stage := range status.Stages {
+		if stage.CategoryID == b.deal.CategoryID {
+			stageID = stage.StageCode
+			break
+		}
+	}
+	if stageID == "" {
+		return fmt.Errorf("%s: %w: %s", op, ErrStageNotFound, status.Name)
+	}
 
 	data := map[string]any{
+		"id": dealID,
 		"fields": map[string]any{
-			"NAME": dto.Name,
-			"PHONE": []map[string]string{
-				{
-					"VALUE":      dto.PhoneNumber,
-					"VALUE_TYPE": "WORK",
-				},
-			},
+			"STAGE_ID": stageID,
 		},
 	}
+	if err := b.call(ctx, "crm.deal.update", data, nil); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddTimelineComment добавляет комментарий в таймлайн сделки и возвращает его ID
+func (b *BitrixService) AddTimelineComment(ctx context.Context, dealID int64, text string) (int64, error) {
+	const op = "BitrixService.AddTimelineComment"
+
+	data := map[string]any{
+		"fields": map[string]any{
+			"ENTITY_ID":   dealID,
+			"ENTITY_TYPE": "deal",
+			"COMMENT":     text,
+		},
+	}
+
+	var result struct {
+		Result int64 `json:"result"`
+	}
+	if err := b.call(ctx, "crm.timeline.comment.add", data, &result); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result.Result, nil
+}
+
+// TimelineComment читает комментарий таймлайна по ID из события ONCRMTIMELINECOMMENTADD
+func (b *BitrixService) TimelineComment(ctx context.Context, id int64) (TimelineComment, error) {
+	const op = "BitrixService.TimelineComment"
+
+	var result struct {
+		Result map[string]any `json:"result"`
+	}
+	if err := b.call(ctx, "crm.timeline.comment.get", map[string]any{"id": id}, &result); err != nil {
+		return TimelineComment{}, fmt.Errorf("%s: %w", op, err)
+	}
 
+	comment := TimelineComment{
+		EntityType: strings.ToLower(fieldString(result.Result, "ENTITY_TYPE")),
+		Comment:    fieldString(result.Result, "COMMENT"),
+	}
+
+	comment.ID, _ = strconv.ParseInt(fieldString(result.Result, "ID"), 10, 64)
+	comment.EntityID, _ = strconv.ParseInt(fieldString(result.Result, "ENTITY_ID"), 10, 64)
+
+	if created, err := time.Parse(time.RFC3339, fieldString(result.Result, "CREATED")); err == nil {
+		comment.Created = &created
+	}
+
+	return comment, nil
+}
+
+// call вызывает метод REST API и, если result не nil, разбирает в него ответ
+func (b *BitrixService) call(ctx context.Context, method string, data any, result any) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		return ReturnDataCreate{}, fmt.Errorf("%s: %v", op, err)
+		return err
 	}
 
-	url := b.webhook + "crm.contact.add"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.webhook+method, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := b.client.Do(req)
 	if err != nil {
-		return ReturnDataCreate{}, fmt.Errorf("%s: %v", op, err)
+		return err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return ReturnDataCreate{}, fmt.Errorf("%s: %v", op, err)
+		return err
 	}
 
-	// Попробуем сначала распарсить как ошибку
 	var errData ErrorData
 	if err := json.Unmarshal(body, &errData); err == nil && errData.Error != "" {
-		return ReturnDataCreate{}, fmt.Errorf("%s: %s - %s", op, errData.Error, errData.ErrorDescription)
+		return fmt.Errorf("%s: %s - %s", method, errData.Error, errData.ErrorDescription)
 	}
 
-	// Если ошибки нет — пробуем распарсить как успешный ответ
-	var result ReturnDataCreate
-	if err := json.Unmarshal(body, &result); err != nil {
-		return ReturnDataCreate{}, fmt.Errorf("%s: %v", op, err)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s: unexpected status %d", method, resp.StatusCode)
 	}
 
-	return result, nil
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(body, result)
 }
@@ -7,19 +7,37 @@ type ReturnDataDeal struct {
 	Time   TimeInfo `json:"time"`
 }
 
+// ReturnDataDealList — страница crm.deal.list; Next равен 0 на последней странице
+type ReturnDataDealList struct {
+	Result []InfoDeal
+	Next   int
+	Total  int
+}
+
 type ReturnDataCreate struct {
 	Result int      `json:"result"`
 	Time   TimeInfo `json:"time"`
 }
 
+// InfoDeal — сделка Bitrix; поля вознаграждений заполняются по config.BitrixFieldsConfig
 type InfoDeal struct {
-	ID              string `json:"ID"`
-	Title           string `json:"TITLE"`
-	Status          string `json:"STAGE_ID"`
-	ContactID       string `json:"CONTACT_ID"`
-	InternetPayment string `json:"UF_CRM_1737451536004"`
-	CleaningPayment string `json:"UF_CRM_1744353480781"`
-	ShippingPayment string `json:"UF_CRM_1744354030686"`
+	ID              string
+	Title           string
+	Status          string
+	CategoryID      string
+	ContactID       string
+	InternetPayment string
+	CleaningPayment string
+	ShippingPayment string
+}
+
+// TimelineComment — комментарий таймлайна CRM (crm.timeline.comment.get)
+type TimelineComment struct {
+	ID         int64
+	EntityID   int64
+	EntityType string
+	Comment    string
+	Created    *time.Time
 }
 
 type TimeInfo struct {
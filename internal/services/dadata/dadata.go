@@ -0,0 +1,177 @@
+package dadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/config"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/models"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DadataService подсказывает и стандартизирует адреса через API Dadata
+type DadataService struct {
+	log    *logrus.Logger
+	cfg    config.DadataConfig
+	client *http.Client
+}
+
+type DadataServiceI interface {
+	SuggestAddress(ctx context.Context, query string, count int) ([]dto.AddressSuggestionDTO, error)
+	CleanAddress(ctx context.Context, address string) (models.Address, error)
+}
+
+var (
+	// Dadata не смогла разобрать адрес (qc = 2)
+	ErrAddressNotRecognized = errors.New("address is not recognized")
+)
+
+// Код качества стандартизации, при котором адрес считается мусорным
+const qcGarbage = 2
+
+func New(log *logrus.Logger, cfg config.DadataConfig) *DadataService {
+	return &DadataService{
+		log:    log,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+type suggestResponse struct {
+	Suggestions []struct {
+		Value             string `json:"value"`
+		UnrestrictedValue string `json:"unrestricted_value"`
+		Data              struct {
+			FiasID     string `json:"fias_id"`
+			City       string `json:"city"`
+			Settlement string `json:"settlement"`
+			GeoLat     string `json:"geo_lat"`
+			GeoLon     string `json:"geo_lon"`
+		} `json:"data"`
+	} `json:"suggestions"`
+}
+
+type cleanResult struct {
+	Result     string `json:"result"`
+	FiasID     string `json:"fias_id"`
+	City       string `json:"city"`
+	Settlement string `json:"settlement"`
+	GeoLat     string `json:"geo_lat"`
+	GeoLon     string `json:"geo_lon"`
+	QC         int    `json:"qc"`
+}
+
+func (d *DadataService) SuggestAddress(ctx context.Context, query string, count int) ([]dto.AddressSuggestionDTO, error) {
+	const op = "DadataService.SuggestAddress"
+
+	body := map[string]any{
+		"query": query,
+		"count": count,
+	}
+
+	var response suggestResponse
+	if err := d.post(ctx, d.cfg.SuggestURL, body, false, &response); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	suggestions := make([]dto.AddressSuggestionDTO, 0, len(response.Suggestions))
+	for _, s := range response.Suggestions {
+		suggestions = append(suggestions, dto.AddressSuggestionDTO{
+			Value:             s.Value,
+			UnrestrictedValue: s.UnrestrictedValue,
+			FiasID:            s.Data.FiasID,
+			City:              cityOrSettlement(s.Data.City, s.Data.Settlement),
+			GeoLat:            parseCoordinate(s.Data.GeoLat),
+			GeoLon:            parseCoordinate(s.Data.GeoLon),
+		})
+	}
+
+	return suggestions, nil
+}
+
+// CleanAddress приводит адрес к стандартному виду. Требует secret_key
+func (d *DadataService) CleanAddress(ctx context.Context, address string) (models.Address, error) {
+	const op = "DadataService.CleanAddress"
+
+	var response []cleanResult
+	if err := d.post(ctx, d.cfg.CleanURL, []string{address}, true, &response); err != nil {
+		return models.Address{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(response) == 0 || response[0].QC == qcGarbage || response[0].Result == "" {
+		return models.Address{}, fmt.Errorf("%s: %w", op, ErrAddressNotRecognized)
+	}
+
+	result := response[0]
+
+	return models.Address{
+		Value:  result.Result,
+		FiasID: result.FiasID,
+		City:   cityOrSettlement(result.City, result.Settlement),
+		GeoLat: parseCoordinate(result.GeoLat),
+		GeoLon: parseCoordinate(result.GeoLon),
+	}, nil
+}
+
+func (d *DadataService) post(ctx context.Context, url string, data any, withSecret bool, result any) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Token "+d.cfg.ApiKey)
+	if withSecret {
+		req.Header.Set("X-Secret", d.cfg.SecretKey)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+// Для адресов вне городов Dadata заполняет settlement вместо city
+func cityOrSettlement(city, settlement string) string {
+	if city != "" {
+		return city
+	}
+	return settlement
+}
+
+func parseCoordinate(value string) *float64 {
+	if value == "" {
+		return nil
+	}
+
+	coordinate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+
+	return &coordinate
+}
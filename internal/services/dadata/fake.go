@@ -0,0 +1,34 @@
+package dadata
+
+import (
+	"context"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/models"
+	"strings"
+)
+
+// FakeDadataService заменяет Dadata, когда ключи не заданы (локальный запуск, тесты):
+// в подсказке возвращает сам запрос, а стандартизация ничего не заполняет.
+type FakeDadataService struct{}
+
+func NewFake() *FakeDadataService {
+	return &FakeDadataService{}
+}
+
+func (f *FakeDadataService) SuggestAddress(ctx context.Context, query string, count int) ([]dto.AddressSuggestionDTO, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || count <= 0 {
+		return []dto.AddressSuggestionDTO{}, nil
+	}
+
+	return []dto.AddressSuggestionDTO{
+		{
+			Value:             query,
+			UnrestrictedValue: query,
+		},
+	}, nil
+}
+
+func (f *FakeDadataService) CleanAddress(ctx context.Context, address string) (models.Address, error) {
+	return models.Address{}, nil
+}
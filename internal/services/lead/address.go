@@ -0,0 +1,22 @@
+package lead
+
+import (
+	"context"
+	"ia-online-golang/internal/models"
+)
+
+// cleanAddress стандартизирует адрес лида через Dadata. Недоступность сервиса
+// не мешает сохранить лид: стандартизированные поля просто остаются пустыми.
+func (l *LeadService) cleanAddress(ctx context.Context, lead *models.Lead) {
+	const op = "LeadService.cleanAddress"
+
+	lead.SetAddress(models.Address{})
+
+	address, err := l.DadataService.CleanAddress(ctx, lead.Address)
+	if err != nil {
+		l.log.Warnf("%s: %v", op, err)
+		return
+	}
+
+	lead.SetAddress(address)
+}
@@ -0,0 +1,128 @@
+package lead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/storage"
+)
+
+// Comments возвращает переписку по лиду владельцу или менеджеру
+func (l *LeadService) Comments(ctx context.Context, leadID int64) ([]models.Comment, error) {
+	const op = "LeadService.Comments"
+
+	lead, err := l.LeadRepository.LeadByID(ctx, leadID)
+	if err != nil {
+		if errors.Is(err, storage.ErrLeadNotFound) {
+			return nil, ErrLeadNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkLeadAccess(ctx, lead); err != nil {
+		return nil, err
+	}
+
+	comments, err := l.CommentRepository.CommentsByLeadID(ctx, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return comments, nil
+}
+
+// AddComment сохраняет комментарий агента или ответ менеджера и ставит его
+// публикацию в таймлайн сделки в outbox
+func (l *LeadService) AddComment(ctx context.Context, leadID int64, commentDTO dto.CommentDTO) (models.Comment, error) {
+	const op = "LeadService.AddComment"
+
+	userID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return models.Comment{}, fmt.Errorf("%s: %v", op, "user id not found")
+	}
+
+	lead, err := l.LeadRepository.LeadByID(ctx, leadID)
+	if err != nil {
+		if errors.Is(err, storage.ErrLeadNotFound) {
+			return models.Comment{}, ErrLeadNotFound
+		}
+		return models.Comment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkLeadAccess(ctx, lead); err != nil {
+		return models.Comment{}, err
+	}
+
+	source := models.CommentSourceAgent
+	if userID != lead.UserID {
+		source = models.CommentSourceManager
+	}
+
+	comment := models.Comment{
+		LeadID: leadID,
+		UserID: &userID,
+		Source: source,
+		Text:   commentDTO.Text,
+	}
+
+	event := models.OutboxEvent{
+		Action: models.OutboxActionAddComment,
+	}
+
+	err = l.CommentRepository.SaveCommentWithOutbox(ctx, &comment, &event)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	saved, err := l.CommentRepository.CommentByID(ctx, comment.ID)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return saved, nil
+}
+
+// ImportBitrixComment сохраняет комментарий, оставленный в таймлайне сделки.
+// Комментарии к чужим сущностям и повторные доставки пропускаются.
+func (l *LeadService) ImportBitrixComment(ctx context.Context, bitrixCommentID int64) error {
+	const op = "LeadService.ImportBitrixComment"
+
+	timelineComment, err := l.BitrixService.TimelineComment(ctx, bitrixCommentID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if timelineComment.EntityType != "deal" {
+		return nil
+	}
+
+	lead, err := l.LeadRepository.LeadByBitrixDealID(ctx, timelineComment.EntityID)
+	if err != nil {
+		if errors.Is(err, storage.ErrLeadNotFound) {
+			l.log.Debugf("%s: deal %d has no lead", op, timelineComment.EntityID)
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	comment := models.Comment{
+		LeadID:          lead.ID,
+		Source:          models.CommentSourceBitrix,
+		Text:            timelineComment.Comment,
+		BitrixCommentID: &bitrixCommentID,
+		CreatedAt:       timelineComment.Created,
+	}
+
+	err = l.CommentRepository.SaveBitrixComment(ctx, &comment)
+	if err != nil {
+		if errors.Is(err, storage.ErrCommentExists) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
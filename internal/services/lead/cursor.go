@@ -0,0 +1,42 @@
+package lead
+
+import (
+	"encoding/base64"
+	"errors"
+	"ia-online-golang/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor упаковывает (created_at, id) последнего лида страницы в непрозрачную строку
+func encodeCursor(lead models.Lead) string {
+	raw := lead.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(lead.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (*models.LeadCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAtStr, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &models.LeadCursor{CreatedAt: createdAt, ID: id}, nil
+}
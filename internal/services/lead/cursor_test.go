@@ -0,0 +1,62 @@
+package lead
+
+import (
+	"encoding/base64"
+	"errors"
+	"ia-online-golang/internal/models"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		id        int64
+	}{
+		{"utc", time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC), 1},
+		{"nanoseconds", time.Date(2026, 3, 2, 10, 30, 0, 123456789, time.UTC), 42},
+		{"other zone", time.Date(2026, 3, 2, 13, 30, 0, 0, moscow), 9007199254740993},
+	}
+
+	for _, tt := range tests {
+		createdAt := tt.createdAt
+		cursor := encodeCursor(models.Lead{ID: tt.id, CreatedAt: &createdAt})
+
+		got, err := decodeCursor(cursor)
+		if err != nil {
+			t.Errorf("%s: decodeCursor(%q): %v", tt.name, cursor, err)
+			continue
+		}
+		if !got.CreatedAt.Equal(tt.createdAt) || got.ID != tt.id {
+			t.Errorf("%s: got (%v, %d), want (%v, %d)", tt.name, got.CreatedAt, got.ID, tt.createdAt, tt.id)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"empty", ""},
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("2026-03-02T10:30:00Z|1"))},
+		{"no separator", encode("2026-03-02T10:30:00Z")},
+		{"invalid time", encode("2026-03-02|1")},
+		{"invalid id", encode("2026-03-02T10:30:00Z|abc")},
+		{"empty id", encode("2026-03-02T10:30:00Z|")},
+	}
+
+	for _, tt := range tests {
+		if _, err := decodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: decodeCursor(%q) error = %v, want ErrInvalidCursor", tt.name, tt.cursor, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package lead
+
+import (
+	"context"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/models"
+	"time"
+)
+
+// DuplicateError сообщает, что клиент уже закреплён за ранее поданным лидом.
+// errors.Is(err, ErrLeadDuplicate) для неё истинно.
+type DuplicateError struct {
+	Duplicate dto.LeadDuplicateDTO
+}
+
+func (e *DuplicateError) Error() string {
+	return ErrLeadDuplicate.Error()
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return ErrLeadDuplicate
+}
+
+// duplicateCheck возвращает условия поиска дубля или nil, если проверка отключена.
+// Отказные лиды клиента не закрепляют.
+func (l *LeadService) duplicateCheck(ctx context.Context) (*models.DuplicateCheck, error) {
+	if l.cfg.DuplicateWindow <= 0 {
+		return nil, nil
+	}
+
+	refusal, err := l.StatusService.StatusByName(ctx, StatusRefusal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.DuplicateCheck{
+		Since:            time.Now().Add(-l.cfg.DuplicateWindow),
+		ExcludeStatusIDs: []int64{refusal.ID},
+	}, nil
+}
+
+// duplicateError записывает попытку подачи дубля и собирает сведения о первом лиде,
+// не раскрывая чужому агенту ни клиента, ни владельца
+func (l *LeadService) duplicateError(ctx context.Context, userID int64, lead models.Lead, existing models.Lead) error {
+	const op = "LeadService.duplicateError"
+
+	duplicate := models.LeadDuplicate{
+		LeadID:      existing.ID,
+		UserID:      userID,
+		PhoneNumber: lead.PhoneNumber,
+		Address:     lead.Address,
+	}
+	if err := l.LeadRepository.SaveLeadDuplicate(ctx, &duplicate); err != nil {
+		l.log.Errorf("%s: lead %d: %v", op, existing.ID, err)
+	}
+
+	details := dto.LeadDuplicateDTO{
+		Own:       existing.UserID == userID,
+		StatusID:  existing.StatusID,
+		CreatedAt: existing.CreatedAt,
+	}
+	if details.Own {
+		details.LeadID = &existing.ID
+	}
+
+	return &DuplicateError{Duplicate: details}
+}
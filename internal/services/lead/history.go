@@ -0,0 +1,156 @@
+package lead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/storage"
+	"ia-online-golang/internal/utils"
+	"time"
+)
+
+// LeadHistory возвращает историю изменений лида владельцу или менеджеру
+func (l *LeadService) LeadHistory(ctx context.Context, leadID int64) ([]models.LeadHistory, error) {
+	const op = "LeadService.LeadHistory"
+
+	lead, err := l.LeadRepository.LeadByID(ctx, leadID)
+	if err != nil {
+		if errors.Is(err, storage.ErrLeadNotFound) {
+			return nil, ErrLeadNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkLeadAccess(ctx, lead); err != nil {
+		return nil, err
+	}
+
+	history, err := l.HistoryRepository.HistoryByLeadID(ctx, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return history, nil
+}
+
+// saveHistory записывает изменения лида; ошибка записи не откатывает само изменение
+func (l *LeadService) saveHistory(ctx context.Context, leadID int64, action, source string, changes []models.HistoryChange) {
+	const op = "LeadService.saveHistory"
+
+	if action == models.HistoryActionUpdated && len(changes) == 0 {
+		return
+	}
+
+	history := models.LeadHistory{
+		LeadID:  leadID,
+		Action:  action,
+		Source:  source,
+		Changes: changes,
+	}
+
+	if userID, ok := ctx.Value(context_keys.UserIDKey).(int64); ok {
+		history.UserID = &userID
+	}
+
+	if err := l.HistoryRepository.SaveHistory(ctx, &history); err != nil {
+		l.log.Errorf("%s: lead %d: %v", op, leadID, err)
+	}
+}
+
+// checkLeadAccess разрешает доступ к лиду его владельцу и менеджерам
+func checkLeadAccess(ctx context.Context, lead *models.Lead) error {
+	if roles, ok := ctx.Value(context_keys.UserRoleKey).([]string); ok && utils.Contains(roles, "manager") {
+		return nil
+	}
+
+	userID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok || userID != lead.UserID {
+		return ErrLeadForbidden
+	}
+
+	return nil
+}
+
+// checkLeadOwner разрешает изменение лида только агенту, который его создал
+func checkLeadOwner(ctx context.Context, lead *models.Lead) error {
+	userID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok || userID != lead.UserID {
+		return ErrLeadForbidden
+	}
+
+	return nil
+}
+
+// leadChanges сравнивает два состояния лида и возвращает изменённые поля
+func leadChanges(old, new models.Lead) []models.HistoryChange {
+	var changes []models.HistoryChange
+
+	add := func(field string, oldValue, newValue any) {
+		changes = append(changes, models.HistoryChange{Field: field, Old: oldValue, New: newValue})
+	}
+
+	if old.StatusID != new.StatusID {
+		add("status_id", old.StatusID, new.StatusID)
+	}
+	if old.FIO != new.FIO {
+		add("fio", old.FIO, new.FIO)
+	}
+	if old.PhoneNumber != new.PhoneNumber {
+		add("phone_number", old.PhoneNumber, new.PhoneNumber)
+	}
+	if old.Address != new.Address {
+		add("address", old.Address, new.Address)
+	}
+	if old.Internet != new.Internet {
+		add("is_internet", old.Internet, new.Internet)
+	}
+	if old.Cleaning != new.Cleaning {
+		add("is_cleaning", old.Cleaning, new.Cleaning)
+	}
+	if old.Shipping != new.Shipping {
+		add("is_shipping", old.Shipping, new.Shipping)
+	}
+	if old.RewardInternet != new.RewardInternet {
+		add("reward_internet", old.RewardInternet, new.RewardInternet)
+	}
+	if old.RewardCleaning != new.RewardCleaning {
+		add("reward_cleaning", old.RewardCleaning, new.RewardCleaning)
+	}
+	if old.RewardShipping != new.RewardShipping {
+		add("reward_shipping", old.RewardShipping, new.RewardShipping)
+	}
+	if !sameTime(old.CompletedAt, new.CompletedAt) {
+		add("completed_at", old.CompletedAt, new.CompletedAt)
+	}
+	if !sameTime(old.PaymentAt, new.PaymentAt) {
+		add("payment_at", old.PaymentAt, new.PaymentAt)
+	}
+	if !sameInt64(old.PayoutID, new.PayoutID) {
+		add("payout_id", old.PayoutID, new.PayoutID)
+	}
+
+	return changes
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func sameInt64(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
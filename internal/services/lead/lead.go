@@ -2,14 +2,22 @@ package lead
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"ia-online-golang/internal/config"
 	"ia-online-golang/internal/dto"
 	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/lib/phone"
 	"ia-online-golang/internal/models"
 	"ia-online-golang/internal/services/bitrix"
+	"ia-online-golang/internal/services/dadata"
+	"ia-online-golang/internal/services/ledger"
+	"ia-online-golang/internal/services/status"
+	"ia-online-golang/internal/services/tariff"
 	"ia-online-golang/internal/services/user"
 	"ia-online-golang/internal/storage"
+	"ia-online-golang/internal/utils"
 	"strconv"
 	"strings"
 	"time"
@@ -19,75 +27,170 @@ import (
 
 type LeadService struct {
 	log                *logrus.Logger
+	cfg                config.LeadsConfig
+	commissionRates    models.CommissionRates
 	UserService        user.UserServiceI
 	BitrixService      bitrix.BitrixServiceI
+	StatusService      status.StatusServiceI
 	LeadRepository     storage.LeadRepositoryI
 	ReferralRepository storage.ReferralRepositoryI
 	CommentRepository  storage.CommentsRepositoryI
+	HistoryRepository  storage.HistoryRepositoryI
+	DadataService      dadata.DadataServiceI
+	LedgerService      ledger.LedgerServiceI
+	TariffService      tariff.TariffServiceI
 }
 
 type LeadServiceI interface {
-	Leads(ctx context.Context, filterDTO dto.LeadFilterDTO) ([]models.Lead, error)
+	Leads(ctx context.Context, filterDTO dto.LeadFilterDTO) (dto.LeadListDTO, error)
 	GetUserPaymentStatistic(ctx context.Context, userID int64, startDate *time.Time, endDate *time.Time) (dto.UserStatistic, error)
 	SaveLead(ctx context.Context, lead dto.LeadDTO) error
 	EditDeal(ctx context.Context, arrInfoBitrix []string) error
+	ApplyDeal(ctx context.Context, deal bitrix.InfoDeal, source string) (bool, error)
+	LeadHistory(ctx context.Context, leadID int64) ([]models.LeadHistory, error)
+	Lead(ctx context.Context, leadID int64) (models.Lead, error)
+	EditLead(ctx context.Context, leadID int64, editDTO dto.LeadEditDTO) error
+	CancelLead(ctx context.Context, leadID int64) error
+	PayLeads(ctx context.Context, payout models.Payout) error
+	LeadDealIDs(ctx context.Context) (map[int64]int64, error)
+	Comments(ctx context.Context, leadID int64) ([]models.Comment, error)
+	AddComment(ctx context.Context, leadID int64, commentDTO dto.CommentDTO) (models.Comment, error)
+	ImportBitrixComment(ctx context.Context, bitrixCommentID int64) error
 }
 
+var (
+	ErrLeadNotFound    = errors.New("lead not found")
+	ErrLeadForbidden   = errors.New("lead belongs to another user")
+	ErrLeadNotEditable = errors.New("lead can be edited only in new status")
+	ErrLeadNoServices  = errors.New("lead must have at least one service")
+	ErrLeadDuplicate   = errors.New("client already has an active lead")
+	// document_id вебхука Bitrix не похож на ["crm", "CCrmDocumentDeal", "DEAL_N"]
+	ErrInvalidDealDocument = errors.New("invalid bitrix deal document id")
+	// У лида есть изменения, ещё не доставленные в Bitrix
+	ErrLeadOutboxPending = errors.New("lead has unsent bitrix outbox events")
+)
+
 func New(
 	log *logrus.Logger,
+	cfg config.LeadsConfig,
 	leadRepository storage.LeadRepositoryI,
 	userService user.UserServiceI,
 	referralRepository storage.ReferralRepositoryI,
 	bitrixService bitrix.BitrixServiceI,
 	commentRepository storage.CommentsRepositoryI,
+	statusService status.StatusServiceI,
+	historyRepository storage.HistoryRepositoryI,
+	dadataService dadata.DadataServiceI,
+	ledgerService ledger.LedgerServiceI,
+	tariffService tariff.TariffServiceI,
 ) *LeadService {
 	return &LeadService{
 		log:                log,
+		cfg:                cfg,
+		commissionRates:    commissionRates(cfg.ReferralCommissions),
 		LeadRepository:     leadRepository,
 		UserService:        userService,
 		ReferralRepository: referralRepository,
 		BitrixService:      bitrixService,
 		CommentRepository:  commentRepository,
+		StatusService:      statusService,
+		HistoryRepository:  historyRepository,
+		DadataService:      dadataService,
+		LedgerService:      ledgerService,
+		TariffService:      tariffService,
 	}
 }
 
-func (l *LeadService) Leads(ctx context.Context, filterDTO dto.LeadFilterDTO) ([]models.Lead, error) {
+// Leads возвращает страницу лидов с общим числом и курсором следующей страницы.
+// Агент видит только свои лиды, менеджер без user_id — все.
+func (l *LeadService) Leads(ctx context.Context, filterDTO dto.LeadFilterDTO) (dto.LeadListDTO, error) {
 	const op = "LeadService.Leads"
 
-	if filterDTO.UserID == nil {
+	roles, _ := ctx.Value(context_keys.UserRoleKey).([]string)
+	if filterDTO.UserID == nil && !utils.Contains(roles, "manager") {
 		userIDValue := ctx.Value(context_keys.UserIDKey)
 		userID, ok := userIDValue.(int64)
 		if !ok {
-			return []models.Lead{}, fmt.Errorf("%s: error receiving userID ", op)
+			return dto.LeadListDTO{}, fmt.Errorf("%s: error receiving userID ", op)
 		}
 		filterDTO.UserID = &userID
 	}
 
-	leads, err := l.LeadRepository.Leads(
-		ctx,
-		filterDTO.StatusID,
-		filterDTO.StartDate,
-		filterDTO.EndDate,
-		filterDTO.Limit,
-		filterDTO.Offset,
-		filterDTO.UserID,
-		filterDTO.Search,
-		filterDTO.IsInternet,
-		filterDTO.IsShipping,
-		filterDTO.IsCleaning,
-	)
+	filter := models.LeadFilter{
+		StatusID:     filterDTO.StatusID,
+		StartDate:    filterDTO.StartDate,
+		EndDate:      filterDTO.EndDate,
+		UserID:       filterDTO.UserID,
+		Search:       filterDTO.Search,
+		IsInternet:   filterDTO.IsInternet,
+		IsShipping:   filterDTO.IsShipping,
+		IsCleaning:   filterDTO.IsCleaning,
+		Limit:        filterDTO.Limit,
+		Offset:       filterDTO.Offset,
+		WithComments: filterDTO.WithComments,
+		Sort:         filterDTO.Sort,
+		Order:        filterDTO.Order,
+	}
+
+	// Поиск без явной сортировки выдаёт сначала самые релевантные лиды
+	if filter.Sort == "" {
+		filter.Sort = models.LeadSortCreatedAt
+		if filter.Search != nil && strings.TrimSpace(*filter.Search) != "" && filterDTO.Cursor == "" {
+			filter.Sort = models.LeadSortRelevance
+		}
+	}
+	if filter.Order == "" {
+		filter.Order = models.SortDesc
+	}
+
+	// Курсор задаёт позицию в порядке (created_at, id), поэтому работает только с этой сортировкой
+	if filterDTO.Cursor != "" {
+		if filter.Sort != models.LeadSortCreatedAt {
+			return dto.LeadListDTO{}, ErrInvalidCursor
+		}
+
+		cursor, err := decodeCursor(filterDTO.Cursor)
+		if err != nil {
+			return dto.LeadListDTO{}, err
+		}
+		filter.Cursor = cursor
+	}
+
+	total, err := l.LeadRepository.CountLeads(ctx, filter)
+	if err != nil {
+		return dto.LeadListDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := dto.LeadListDTO{
+		Items: []models.Lead{},
+		Total: total,
+	}
+
+	leads, err := l.LeadRepository.Leads(ctx, filter)
 	if err != nil {
 		if errors.Is(err, storage.ErrLeadsNotFound) {
-			return []models.Lead{}, nil
+			return result, nil
 		}
 
 		l.log.Error("Error fetching leads", err)
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return dto.LeadListDTO{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return leads, nil
+	result.Items = leads
+
+	if filter.Sort == models.LeadSortCreatedAt && filter.Limit > 0 && int64(len(leads)) == filter.Limit {
+		last := leads[len(leads)-1]
+		if last.CreatedAt != nil {
+			cursor := encodeCursor(last)
+			result.NextCursor = &cursor
+		}
+	}
+
+	return result, nil
 }
 
+// SaveLead сохраняет лид локально и ставит создание сделки в очередь outbox,
+// поэтому недоступность Bitrix не мешает агенту отправить заявку.
 func (l *LeadService) SaveLead(ctx context.Context, lead dto.LeadDTO) error {
 	const op = "LeadService.SaveLead"
 
@@ -97,150 +200,390 @@ func (l *LeadService) SaveLead(ctx context.Context, lead dto.LeadDTO) error {
 		return fmt.Errorf("%s: %v", op, "user id not found")
 	}
 
-	user, err := l.UserService.UserById(ctx, userID)
+	phoneNumber, err := phone.Normalize(lead.PhoneNumber)
 	if err != nil {
-		return fmt.Errorf("%s: %v", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	lead.PhoneNumber = phoneNumber
 
-	bitrix_result, err := l.BitrixService.SendDeal(ctx, lead, user)
+	payload, err := json.Marshal(lead)
 	if err != nil {
 		return fmt.Errorf("%s: %v", op, err)
 	}
 
+	dup, err := l.duplicateCheck(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	leadDB := models.Lead{
-		ID:             int64(bitrix_result.Result),
-		UserID:         userID,
-		FIO:            lead.Name,
-		Address:        lead.Address,
-		StatusID:       0,
-		PhoneNumber:    lead.PhoneNumber,
-		Internet:       lead.IsInternet,
-		Cleaning:       lead.IsCleaning,
-		Shipping:       lead.IsShipping,
-		RewardInternet: lead.RewardInternet,
-		RewardCleaning: lead.RewardCleaning,
-		RewardShipping: lead.RewardShipping,
-	}
-
-	err = l.LeadRepository.CreateLead(ctx, &leadDB)
+		UserID:      userID,
+		SyncStatus:  models.LeadSyncPending,
+		FIO:         lead.Name,
+		Address:     lead.Address,
+		StatusID:    0,
+		PhoneNumber: lead.PhoneNumber,
+		Internet:    lead.IsInternet,
+		Cleaning:    lead.IsCleaning,
+		Shipping:    lead.IsShipping,
+	}
+
+	l.cleanAddress(ctx, &leadDB)
+
+	if err := l.snapshotTariffs(ctx, &leadDB, time.Now()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Комментарий при создании уходит в поле сделки, а не в таймлайн
+	var comment *models.Comment
+	if lead.Comment != "" {
+		comment = &models.Comment{
+			UserID: &userID,
+			Source: models.CommentSourceAgent,
+			Text:   lead.Comment,
+		}
+	}
+
+	event := models.OutboxEvent{
+		Action:  models.OutboxActionCreateDeal,
+		Payload: payload,
+	}
+
+	err = l.LeadRepository.CreateLeadWithOutbox(ctx, &leadDB, comment, &event, dup)
 	if err != nil {
+		if errors.Is(err, storage.ErrLeadDuplicate) {
+			return l.duplicateError(ctx, userID, leadDB, *dup.Existing)
+		}
 		return fmt.Errorf("%s: %v", op, err)
 	}
 
-	comment := models.Comment{
-		LeadID: int64(bitrix_result.Result),
-		UserID: userID,
-		Text:   lead.Comment,
+	l.saveHistory(ctx, leadDB.ID, models.HistoryActionCreated, models.HistorySourceAgent, nil)
+
+	return nil
+}
+
+// Lead возвращает лид владельцу или менеджеру
+func (l *LeadService) Lead(ctx context.Context, leadID int64) (models.Lead, error) {
+	const op = "LeadService.Lead"
+
+	lead, err := l.LeadRepository.LeadByID(ctx, leadID)
+	if err != nil {
+		if errors.Is(err, storage.ErrLeadNotFound) {
+			return models.Lead{}, ErrLeadNotFound
+		}
+		return models.Lead{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkLeadAccess(ctx, lead); err != nil {
+		return models.Lead{}, err
 	}
 
-	err = l.CommentRepository.SaveComment(ctx, comment)
+	return *lead, nil
+}
+
+// EditLead меняет данные лида, пока он в статусе new, и ставит обновление сделки в outbox
+func (l *LeadService) EditLead(ctx context.Context, leadID int64, editDTO dto.LeadEditDTO) error {
+	const op = "LeadService.EditLead"
+
+	lead, err := l.LeadRepository.LeadByID(ctx, leadID)
 	if err != nil {
-		return fmt.Errorf("%s: %v", op, err)
+		if errors.Is(err, storage.ErrLeadNotFound) {
+			return ErrLeadNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkLeadOwner(ctx, lead); err != nil {
+		return err
+	}
+
+	status, err := l.StatusService.StatusByID(ctx, lead.StatusID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if status.Name != StatusNew {
+		return ErrLeadNotEditable
+	}
+
+	updated := *lead
+	if editDTO.Name != nil {
+		updated.FIO = *editDTO.Name
+	}
+	if editDTO.PhoneNumber != nil {
+		phoneNumber, err := phone.Normalize(*editDTO.PhoneNumber)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		updated.PhoneNumber = phoneNumber
+	}
+	if editDTO.Address != nil {
+		updated.Address = *editDTO.Address
+	}
+	if editDTO.IsInternet != nil {
+		updated.Internet = *editDTO.IsInternet
+	}
+	if editDTO.IsCleaning != nil {
+		updated.Cleaning = *editDTO.IsCleaning
+	}
+	if editDTO.IsShipping != nil {
+		updated.Shipping = *editDTO.IsShipping
+	}
+
+	if !updated.Internet && !updated.Cleaning && !updated.Shipping {
+		return ErrLeadNoServices
+	}
+
+	if updated.Address != lead.Address {
+		l.cleanAddress(ctx, &updated)
+	}
+
+	// Для новых услуг и нового города берётся тариф на момент создания лида
+	if updated.Internet != lead.Internet || updated.Cleaning != lead.Cleaning || updated.Shipping != lead.Shipping ||
+		!sameString(updated.City, lead.City) {
+		createdAt := time.Now()
+		if lead.CreatedAt != nil {
+			createdAt = *lead.CreatedAt
+		}
+		if err := l.snapshotTariffs(ctx, &updated, createdAt); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	changes := leadChanges(*lead, updated)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// Новый телефон или адрес не должен совпасть с клиентом чужого лида
+	var dup *models.DuplicateCheck
+	if updated.PhoneNumber != lead.PhoneNumber || updated.Address != lead.Address {
+		dup, err = l.duplicateCheck(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if dup != nil {
+			dup.ExcludeLeadID = lead.ID
+		}
+	}
+
+	event := models.OutboxEvent{
+		Action:  models.OutboxActionUpdateDeal,
+		Payload: []byte("{}"),
 	}
 
+	err = l.LeadRepository.UpdateLeadWithOutbox(ctx, updated, lead.StatusID, &event, l.commissionRates, dup)
+	if err != nil {
+		if errors.Is(err, storage.ErrLeadStatusChanged) {
+			return ErrLeadNotEditable
+		}
+		if errors.Is(err, storage.ErrLeadDuplicate) {
+			return l.duplicateError(ctx, lead.UserID, updated, *dup.Existing)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	l.saveHistory(ctx, lead.ID, models.HistoryActionUpdated, models.HistorySourceAgent, changes)
+
 	return nil
 }
 
-func (l *LeadService) EditDeal(ctx context.Context, arrInfoBitrix []string) error {
-	const op = "LeadService.EditDeal"
+// CancelLead переводит лид агента в отказ и ставит перенос сделки в outbox
+func (l *LeadService) CancelLead(ctx context.Context, leadID int64) error {
+	const op = "LeadService.CancelLead"
 
-	idDealStr := arrInfoBitrix[2]
-	idStr := strings.Split(idDealStr, "_")[1]
-	idDeal, err := strconv.ParseInt(idStr, 10, 64)
+	lead, err := l.LeadRepository.LeadByID(ctx, leadID)
 	if err != nil {
-		return fmt.Errorf("%s: %v", op, err)
+		if errors.Is(err, storage.ErrLeadNotFound) {
+			return ErrLeadNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	infoDeal, err := l.BitrixService.GetLead(ctx, idDeal)
+	if err := checkLeadOwner(ctx, lead); err != nil {
+		return err
+	}
+
+	refusal, err := l.StatusService.StatusByName(ctx, StatusRefusal)
 	if err != nil {
-		return fmt.Errorf("%s: %v", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	statuses := map[string]int64{
-		"C42:NEW":               0,
-		"C42:PREPARATION":       1,
-		"C42:PREPAYMENT_INVOIC": 2,
-		"C42:EXECUTING":         7,
-		"C42:FINAL_INVOICE":     3,
-		"C42:1":                 4,
-		"C42:LOSE":              6,
-		"C42:WON":               5,
+	if lead.StatusID == refusal.ID {
+		return nil
 	}
 
-	status, ok := statuses[infoDeal.Result.Status]
-	if !ok {
-		return fmt.Errorf("%s: статус не найден для %s", op, infoDeal.Result.Status)
+	// В отличие от вебхука Bitrix, запрос агента на недопустимый переход отклоняется
+	updated, valid, err := l.transition(ctx, *lead, refusal.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	if !valid {
+		return ErrInvalidTransition
+	}
+	l.applyRewards(&updated, updated.RewardInternet, updated.RewardCleaning, updated.RewardShipping)
 
-	// Преобразуем строки в float64
-	internetPayment, err := strconv.ParseFloat(infoDeal.Result.InternetPayment, 64)
+	payload, err := json.Marshal(models.MoveDealPayload{StatusID: refusal.ID})
 	if err != nil {
-		internetPayment = 0
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	cleaningPayment, err := strconv.ParseFloat(infoDeal.Result.CleaningPayment, 64)
+	event := models.OutboxEvent{
+		Action:  models.OutboxActionMoveDeal,
+		Payload: payload,
+	}
+
+	err = l.LeadRepository.UpdateLeadWithOutbox(ctx, updated, lead.StatusID, &event, l.commissionRates, nil)
 	if err != nil {
-		cleaningPayment = 0
+		if errors.Is(err, storage.ErrLeadStatusChanged) {
+			return ErrInvalidTransition
+		}
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	shippingPayment, err := strconv.ParseFloat(infoDeal.Result.ShippingPayment, 64)
+	l.saveHistory(ctx, lead.ID, models.HistoryActionUpdated, models.HistorySourceAgent, leadChanges(*lead, updated))
+
+	return nil
+}
+
+func (l *LeadService) EditDeal(ctx context.Context, arrInfoBitrix []string) error {
+	const op = "LeadService.EditDeal"
+
+	if len(arrInfoBitrix) < 3 {
+		return fmt.Errorf("%s: %w", op, ErrInvalidDealDocument)
+	}
+
+	parts := strings.Split(arrInfoBitrix[2], "_")
+	if len(parts) != 2 {
+		return fmt.Errorf("%s: %w", op, ErrInvalidDealDocument)
+	}
+
+	idDeal, err := strconv.ParseInt(parts[1], 10, 64)
 	if err != nil {
-		shippingPayment = 0
+		return fmt.Errorf("%s: %w", op, ErrInvalidDealDocument)
+	}
+
+	infoDeal, err := l.BitrixService.GetLead(ctx, idDeal)
+	if err != nil {
+		return fmt.Errorf("%s: %v", op, err)
 	}
 
-	l.LeadRepository.UpdateLead(
-		ctx,
-		&idDeal,
-		nil,
-		&status,
-		&internetPayment,
-		&cleaningPayment,
-		&shippingPayment,
-		nil, nil, nil, nil, nil, nil, nil, nil, nil,
-	)
+	_, err = l.ApplyDeal(ctx, infoDeal.Result, models.HistorySourceWebhook)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
 	return nil
 }
 
-func (l *LeadService) GetUserPaymentStatistic(ctx context.Context, userID int64, startDate *time.Time, endDate *time.Time) (dto.UserStatistic, error) {
-	const op = "LeadService.GetUserPaymentStatistic"
+// ApplyDeal переносит стадию сделки Bitrix в лид, пересчитывает вознаграждения по тарифам
+// и суммам сделки и пишет историю с указанным источником. Возвращает true, если лид был изменён.
+func (l *LeadService) ApplyDeal(ctx context.Context, deal bitrix.InfoDeal, source string) (bool, error) {
+	const op = "LeadService.ApplyDeal"
 
-	leads, err := l.LeadRepository.Leads(ctx, nil, startDate, endDate, 0, 0, &userID, nil, nil, nil, nil)
+	idDeal, err := strconv.ParseInt(deal.ID, 10, 64)
 	if err != nil {
-		if errors.Is(err, storage.ErrLeadsNotFound) {
+		return false, fmt.Errorf("%s: %v", op, err)
+	}
 
-		} else {
-			return dto.UserStatistic{}, fmt.Errorf("%s: %v", op, err)
+	lead, err := l.LeadRepository.LeadByBitrixDealID(ctx, idDeal)
+	if err != nil {
+		if errors.Is(err, storage.ErrLeadNotFound) {
+			return false, ErrLeadNotFound
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Сделка в основной воронке может прийти без CATEGORY_ID
+	var categoryID int64
+	if deal.CategoryID != "" {
+		categoryID, err = strconv.ParseInt(deal.CategoryID, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("%s: %v", op, err)
+		}
+	}
+
+	// Сверка не должна откатывать изменения, которые ещё не дошли до Bitrix
+	// (отмена агентом, оплата по выплате): сделка в Bitrix для них пока устаревшая
+	if source == models.HistorySourceReconciliation {
+		unsent, err := l.LeadRepository.LeadHasUnsentEvents(ctx, lead.ID)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		if unsent {
+			return false, ErrLeadOutboxPending
 		}
 	}
 
-	user, err := l.UserService.UserById(ctx, userID)
+	statusID, err := l.StatusService.StatusByStage(ctx, categoryID, deal.Status)
 	if err != nil {
-		return dto.UserStatistic{}, fmt.Errorf("%s: %v", op, err)
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	result := dto.UserStatistic{}
+	// Суммы из Bitrix переводим в копейки
+	internetPayment, err := bitrix.ParseAmount(deal.InternetPayment)
+	if err != nil {
+		internetPayment = 0
+	}
+
+	cleaningPayment, err := bitrix.ParseAmount(deal.CleaningPayment)
+	if err != nil {
+		cleaningPayment = 0
+	}
 
-	referrals, err := l.ReferralRepository.ActiveReferralsByReferralId(ctx, user.ReferralCode)
+	shippingPayment, err := bitrix.ParseAmount(deal.ShippingPayment)
 	if err != nil {
-		if errors.Is(err, storage.ErrReferralsNotFound) {
+		shippingPayment = 0
+	}
 
-		} else {
-			return dto.UserStatistic{}, fmt.Errorf("%s: %v", op, err)
-		}
+	// Bitrix — источник истины, поэтому недопустимый переход не отклоняется, а помечается
+	updated, valid, err := l.transition(ctx, *lead, statusID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	l.applyRewards(&updated, internetPayment, cleaningPayment, shippingPayment)
+
+	changes := leadChanges(*lead, updated)
+	if len(changes) == 0 {
+		return false, nil
 	}
 
-	for _, lead := range leads {
-		result.Internet += lead.RewardInternet
-		result.Shipping += lead.RewardShipping
-		result.Cleaning += lead.RewardCleaning
+	err = l.LeadRepository.UpdateLeadRecord(ctx, updated, l.commissionRates)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	for _, referral := range referrals {
-		result.Referrals += referral.Cost
+	action := models.HistoryActionUpdated
+	if !valid {
+		l.log.Warnf("%s: lead %d moved from status %d to %d, transition is not allowed", op, lead.ID, lead.StatusID, statusID)
+		action = models.HistoryActionInvalidTransition
 	}
 
-	result.Total = result.Cleaning + result.Referrals + result.Internet + result.Shipping
+	l.saveHistory(ctx, lead.ID, action, source, changes)
 
-	return result, nil
+	return true, nil
+}
+
+// LeadDealIDs возвращает ID лидов со сделкой в Bitrix по ID сделки
+func (l *LeadService) LeadDealIDs(ctx context.Context) (map[int64]int64, error) {
+	const op = "LeadService.LeadDealIDs"
+
+	leads, err := l.LeadRepository.LeadDealIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return leads, nil
+}
+
+// GetUserPaymentStatistic возвращает начисления агента за период по книге начислений
+func (l *LeadService) GetUserPaymentStatistic(ctx context.Context, userID int64, startDate *time.Time, endDate *time.Time) (dto.UserStatistic, error) {
+	const op = "LeadService.GetUserPaymentStatistic"
+
+	statistic, err := l.LedgerService.Statistic(ctx, userID, startDate, endDate)
+	if err != nil {
+		return dto.UserStatistic{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return statistic, nil
 }
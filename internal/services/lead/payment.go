@@ -0,0 +1,86 @@
+package lead
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/storage"
+	"time"
+)
+
+// PayLeads отмечает оплаченными лиды агента, которые покрыты его выплатами после оплаты заявки payout.
+// Лид переходит в paid по жизненному циклу, переход уходит в Bitrix через outbox.
+func (l *LeadService) PayLeads(ctx context.Context, payout models.Payout) error {
+	const op = "LeadService.PayLeads"
+
+	leads, err := l.LeadRepository.UnpaidCoveredLeads(ctx, payout.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(leads) == 0 {
+		return nil
+	}
+
+	paid, err := l.StatusService.StatusByName(ctx, StatusPaid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, lead := range leads {
+		if err := l.payLead(ctx, lead, paid.ID, payout.ID); err != nil {
+			return fmt.Errorf("%s: lead %d: %w", op, lead.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (l *LeadService) payLead(ctx context.Context, lead models.Lead, paidStatusID, payoutID int64) error {
+	const op = "LeadService.payLead"
+
+	updated, valid, err := l.transition(ctx, lead, paidStatusID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Статус, из которого в paid не перейти, не трогаем, но выплату по лиду фиксируем
+	if !valid {
+		l.log.Warnf("%s: lead %d can not move from status %d to paid, only payout is recorded", op, lead.ID, lead.StatusID)
+
+		updated = lead
+		if updated.PaymentAt == nil {
+			now := time.Now()
+			updated.PaymentAt = &now
+		}
+	}
+	updated.PayoutID = &payoutID
+
+	if updated.StatusID == lead.StatusID {
+		err = l.LeadRepository.UpdateLeadRecord(ctx, updated, l.commissionRates)
+	} else {
+		var payload []byte
+		payload, err = json.Marshal(models.MoveDealPayload{StatusID: paidStatusID})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		event := models.OutboxEvent{
+			Action:  models.OutboxActionMoveDeal,
+			Payload: payload,
+		}
+
+		err = l.LeadRepository.UpdateLeadWithOutbox(ctx, updated, lead.StatusID, &event, l.commissionRates, nil)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrLeadStatusChanged) {
+			return ErrInvalidTransition
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	l.saveHistory(ctx, lead.ID, models.HistoryActionUpdated, models.HistorySourceManager, leadChanges(lead, updated))
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package lead
+
+import (
+	"context"
+	"ia-online-golang/internal/lib/money"
+	"ia-online-golang/internal/models"
+	"math"
+	"time"
+)
+
+// snapshotTariffs запоминает в лиде тарифы заказанных услуг, действовавшие в момент at
+// в городе лида. Дальнейшие правки тарифов на лид не влияют.
+func (l *LeadService) snapshotTariffs(ctx context.Context, lead *models.Lead, at time.Time) error {
+	services := []struct {
+		enabled bool
+		service string
+		tariff  **money.Amount
+	}{
+		{lead.Internet, models.LedgerServiceInternet, &lead.TariffInternet},
+		{lead.Cleaning, models.LedgerServiceCleaning, &lead.TariffCleaning},
+		{lead.Shipping, models.LedgerServiceShipping, &lead.TariffShipping},
+	}
+
+	for _, s := range services {
+		*s.tariff = nil
+		if !s.enabled {
+			continue
+		}
+
+		amount, err := l.TariffService.Amount(ctx, s.service, lead.City, at)
+		if err != nil {
+			return err
+		}
+		*s.tariff = amount
+	}
+
+	return nil
+}
+
+// applyRewards выставляет вознаграждения лида. Пока лид не в ready/paid, вознаграждения нет.
+// В этих статусах действует снимок тарифа; сумма из сделки Bitrix заменяет его,
+// если у лида нет тарифа или замена разрешена в конфиге (leads.bitrix_reward_override).
+func (l *LeadService) applyRewards(lead *models.Lead, internet, cleaning, shipping money.Amount) {
+	if lead.CompletedAt == nil {
+		lead.RewardInternet, lead.RewardCleaning, lead.RewardShipping = 0, 0, 0
+		return
+	}
+
+	lead.RewardInternet = l.reward(lead.TariffInternet, internet)
+	lead.RewardCleaning = l.reward(lead.TariffCleaning, cleaning)
+	lead.RewardShipping = l.reward(lead.TariffShipping, shipping)
+}
+
+func (l *LeadService) reward(tariff *money.Amount, bitrix money.Amount) money.Amount {
+	if tariff == nil || (l.cfg.BitrixRewardOverride && bitrix != 0) {
+		return bitrix
+	}
+	return *tariff
+}
+
+// commissionRates переводит проценты комиссий из конфига в сотые доли процента
+func commissionRates(percents []float64) models.CommissionRates {
+	rates := make(models.CommissionRates, len(percents))
+	for i, percent := range percents {
+		rates[i] = int64(math.Round(percent * 100))
+	}
+	return rates
+}
@@ -0,0 +1,107 @@
+package lead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/models"
+	"time"
+)
+
+// Системные имена статусов (statuses.name), на которые опирается жизненный цикл лида
+const (
+	StatusNew                = "new"
+	StatusNoContact          = "noContact"
+	StatusPending            = "pending"
+	StatusScheduled          = "scheduled"
+	StatusAppointmentControl = "appointment_control"
+	StatusReady              = "ready"
+	StatusPaid               = "paid"
+	StatusRefusal            = "refusal"
+)
+
+var ErrInvalidTransition = errors.New("invalid lead status transition")
+
+// transitions — допустимые переходы между статусами. Статусы, которых здесь нет
+// (например, добавленные менеджером позже), переходами не ограничиваются.
+var transitions = map[string][]string{
+	StatusNew:                {StatusNoContact, StatusPending, StatusScheduled, StatusAppointmentControl, StatusReady, StatusRefusal},
+	StatusNoContact:          {StatusNew, StatusPending, StatusScheduled, StatusAppointmentControl, StatusRefusal},
+	StatusPending:            {StatusNew, StatusNoContact, StatusScheduled, StatusAppointmentControl, StatusRefusal},
+	StatusScheduled:          {StatusNoContact, StatusPending, StatusAppointmentControl, StatusReady, StatusRefusal},
+	StatusAppointmentControl: {StatusPending, StatusScheduled, StatusReady, StatusRefusal},
+	StatusReady:              {StatusPaid, StatusRefusal},
+	StatusPaid:               {},
+	StatusRefusal:            {StatusNew, StatusPending},
+}
+
+func canTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+
+	allowed, known := transitions[from]
+	if !known {
+		return true
+	}
+	if _, known := transitions[to]; !known {
+		return true
+	}
+
+	for _, status := range allowed {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// transitionDates проставляет completed_at при входе в ready/paid, payment_at при входе в paid
+// и сбрасывает их, когда лид из этих статусов уходит.
+func transitionDates(to string, completedAt, paymentAt *time.Time, now time.Time) (*time.Time, *time.Time) {
+	switch to {
+	case StatusReady:
+		if completedAt == nil {
+			completedAt = &now
+		}
+		paymentAt = nil
+	case StatusPaid:
+		if completedAt == nil {
+			completedAt = &now
+		}
+		if paymentAt == nil {
+			paymentAt = &now
+		}
+	default:
+		completedAt = nil
+		paymentAt = nil
+	}
+
+	return completedAt, paymentAt
+}
+
+// transition применяет смену статуса к копии лида. Второе значение сообщает,
+// разрешён ли переход; даты при этом выставляются в любом случае.
+func (l *LeadService) transition(ctx context.Context, lead models.Lead, toStatusID int64) (models.Lead, bool, error) {
+	const op = "LeadService.transition"
+
+	if lead.StatusID == toStatusID {
+		return lead, true, nil
+	}
+
+	from, err := l.StatusService.StatusByID(ctx, lead.StatusID)
+	if err != nil {
+		return lead, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	to, err := l.StatusService.StatusByID(ctx, toStatusID)
+	if err != nil {
+		return lead, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	lead.StatusID = toStatusID
+	lead.CompletedAt, lead.PaymentAt = transitionDates(to.Name, lead.CompletedAt, lead.PaymentAt, time.Now())
+
+	return lead, canTransition(from.Name, to.Name), nil
+}
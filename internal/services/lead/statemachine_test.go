@@ -0,0 +1,76 @@
+package lead
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusNew, StatusNew, true},
+		{StatusNew, StatusPending, true},
+		{StatusNew, StatusReady, true},
+		{StatusNew, StatusRefusal, true},
+		{StatusNew, StatusPaid, false},
+		{StatusScheduled, StatusReady, true},
+		{StatusScheduled, StatusNew, false},
+		{StatusAppointmentControl, StatusNoContact, false},
+		{StatusReady, StatusPaid, true},
+		{StatusReady, StatusRefusal, true},
+		{StatusReady, StatusNew, false},
+		{StatusPaid, StatusPaid, true},
+		{StatusPaid, StatusRefusal, false},
+		{StatusPaid, StatusNew, false},
+		{StatusRefusal, StatusNew, true},
+		{StatusRefusal, StatusReady, false},
+		// Статусы, добавленные менеджером, переходами не ограничены
+		{"callback", StatusPaid, true},
+		{StatusPaid, "callback", true},
+	}
+
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestTransitionDates(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC)
+	earlier := now.AddDate(0, 0, -7)
+
+	tests := []struct {
+		name          string
+		to            string
+		completedAt   *time.Time
+		paymentAt     *time.Time
+		wantCompleted *time.Time
+		wantPayment   *time.Time
+	}{
+		{"ready sets completed", StatusReady, nil, nil, &now, nil},
+		{"ready keeps completed", StatusReady, &earlier, nil, &earlier, nil},
+		{"ready clears payment", StatusReady, &earlier, &earlier, &earlier, nil},
+		{"paid sets both", StatusPaid, nil, nil, &now, &now},
+		{"paid keeps completed", StatusPaid, &earlier, nil, &earlier, &now},
+		{"paid keeps payment", StatusPaid, &earlier, &earlier, &earlier, &earlier},
+		{"refusal clears both", StatusRefusal, &earlier, &earlier, nil, nil},
+		{"new clears both", StatusNew, &earlier, nil, nil, nil},
+	}
+
+	equal := func(a, b *time.Time) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+		return a.Equal(*b)
+	}
+
+	for _, tt := range tests {
+		completed, payment := transitionDates(tt.to, tt.completedAt, tt.paymentAt, now)
+		if !equal(completed, tt.wantCompleted) || !equal(payment, tt.wantPayment) {
+			t.Errorf("%s: got (%v, %v), want (%v, %v)", tt.name, completed, payment, tt.wantCompleted, tt.wantPayment)
+		}
+	}
+}
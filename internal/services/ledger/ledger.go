@@ -0,0 +1,124 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/storage"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LedgerService считает балансы и статистику агентов по книге начислений
+// и проводит ручные корректировки
+type LedgerService struct {
+	log              *logrus.Logger
+	LedgerRepository storage.LedgerRepositoryI
+}
+
+type LedgerServiceI interface {
+	Balance(ctx context.Context, userID int64) (dto.BalanceDTO, error)
+	Statistic(ctx context.Context, userID int64, from, to *time.Time) (dto.UserStatistic, error)
+	Entries(ctx context.Context, userID int64, limit, offset int64) ([]models.LedgerEntry, error)
+	Adjust(ctx context.Context, adjustmentDTO dto.LedgerAdjustmentDTO) (models.LedgerTransaction, error)
+}
+
+var (
+	ErrZeroAmount = errors.New("amount must not be zero")
+)
+
+func New(log *logrus.Logger, ledgerRepository storage.LedgerRepositoryI) *LedgerService {
+	return &LedgerService{
+		log:              log,
+		LedgerRepository: ledgerRepository,
+	}
+}
+
+// Balance возвращает текущий баланс агента за всё время
+func (l *LedgerService) Balance(ctx context.Context, userID int64) (dto.BalanceDTO, error) {
+	const op = "LedgerService.Balance"
+
+	balances, err := l.LedgerRepository.LedgerBalance(ctx, userID, nil, nil)
+	if err != nil {
+		return dto.BalanceDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := dto.BalanceDTO{UserID: userID}
+	for _, balance := range balances {
+		// У выплат нет статьи, всё остальное — начисления, сторно и корректировки
+		if balance.Service == nil {
+			result.PaidOut -= balance.Amount
+		} else {
+			result.Accrued += balance.Amount
+		}
+		result.Balance += balance.Amount
+	}
+
+	return result, nil
+}
+
+// Statistic возвращает начисления агента за период [from, to) в разрезе статей
+func (l *LedgerService) Statistic(ctx context.Context, userID int64, from, to *time.Time) (dto.UserStatistic, error) {
+	const op = "LedgerService.Statistic"
+
+	balances, err := l.LedgerRepository.LedgerBalance(ctx, userID, from, to)
+	if err != nil {
+		return dto.UserStatistic{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := dto.UserStatistic{}
+	for _, balance := range balances {
+		if balance.Service == nil {
+			continue
+		}
+
+		result.Add(*balance.Service, balance.Amount)
+	}
+
+	return result, nil
+}
+
+func (l *LedgerService) Entries(ctx context.Context, userID int64, limit, offset int64) ([]models.LedgerEntry, error) {
+	const op = "LedgerService.Entries"
+
+	entries, err := l.LedgerRepository.LedgerEntries(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
+
+// Adjust проводит корректировку баланса агента от имени текущего менеджера
+func (l *LedgerService) Adjust(ctx context.Context, adjustmentDTO dto.LedgerAdjustmentDTO) (models.LedgerTransaction, error) {
+	const op = "LedgerService.Adjust"
+
+	if adjustmentDTO.Amount == 0 {
+		return models.LedgerTransaction{}, ErrZeroAmount
+	}
+
+	managerID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return models.LedgerTransaction{}, fmt.Errorf("%s: error receiving userID", op)
+	}
+
+	service := models.LedgerServiceAdjustment
+	transaction := models.LedgerTransaction{
+		Kind:        models.LedgerKindAdjustment,
+		CreatedBy:   &managerID,
+		Description: adjustmentDTO.Description,
+		Entries:     models.AgentTransfer(adjustmentDTO.UserID, &service, adjustmentDTO.Amount, models.LedgerAccountRewards),
+	}
+
+	if err := l.LedgerRepository.SaveLedgerTransaction(ctx, &transaction); err != nil {
+		return models.LedgerTransaction{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	l.log.Infof("%s: manager %d adjusted balance of user %d by %s", op, managerID, adjustmentDTO.UserID, adjustmentDTO.Amount)
+
+	return transaction, nil
+}
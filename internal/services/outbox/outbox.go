@@ -0,0 +1,413 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/config"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/services/bitrix"
+	"ia-online-golang/internal/services/status"
+	"ia-online-golang/internal/services/user"
+	"ia-online-golang/internal/storage"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OutboxService периодически забирает события из bitrix_outbox и отправляет их в Bitrix
+type OutboxService struct {
+	log               *logrus.Logger
+	cfg               config.OutboxConfig
+	OutboxRepository  storage.OutboxRepositoryI
+	LeadRepository    storage.LeadRepositoryI
+	CommentRepository storage.CommentsRepositoryI
+	UserService       user.UserServiceI
+	BitrixService     bitrix.BitrixServiceI
+	StatusService     status.StatusServiceI
+	stop              chan struct{}
+}
+
+type OutboxServiceI interface {
+	Run()
+	Stop()
+	ProcessOutbox(ctx context.Context) error
+	DeadEvents(ctx context.Context, leadID *int64) ([]models.OutboxEvent, error)
+	Requeue(ctx context.Context, id int64) (models.OutboxEvent, error)
+}
+
+var (
+	ErrUnknownAction  = errors.New("unknown outbox action")
+	ErrDealNotCreated = errors.New("bitrix deal is not created yet")
+	ErrEventNotFound  = errors.New("dead outbox event not found")
+)
+
+func New(
+	log *logrus.Logger,
+	cfg config.OutboxConfig,
+	outboxRepository storage.OutboxRepositoryI,
+	leadRepository storage.LeadRepositoryI,
+	commentRepository storage.CommentsRepositoryI,
+	userService user.UserServiceI,
+	bitrixService bitrix.BitrixServiceI,
+	statusService status.StatusServiceI,
+) *OutboxService {
+	return &OutboxService{
+		log:               log,
+		cfg:               cfg,
+		OutboxRepository:  outboxRepository,
+		LeadRepository:    leadRepository,
+		CommentRepository: commentRepository,
+		UserService:       userService,
+		BitrixService:     bitrixService,
+		StatusService:     statusService,
+		stop:              make(chan struct{}),
+	}
+}
+
+func (o *OutboxService) Run() {
+	op := "OutboxService.Run"
+
+	go func() {
+		ticker := time.NewTicker(o.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := o.ProcessOutbox(context.Background()); err != nil {
+					o.log.Errorf("%s: %v", op, err)
+				}
+			case <-o.stop:
+				return
+			}
+		}
+	}()
+
+	o.log.Info("📤 Outbox Bitrix запущен")
+}
+
+func (o *OutboxService) Stop() {
+	close(o.stop)
+	o.log.Info("🛑 Outbox Bitrix остановлен")
+}
+
+// ProcessOutbox отправляет одну пачку событий
+func (o *OutboxService) ProcessOutbox(ctx context.Context) error {
+	const op = "OutboxService.ProcessOutbox"
+
+	events, err := o.OutboxRepository.ClaimOutboxEvents(ctx, o.cfg.BatchSize, o.cfg.ProcessingTimeout)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, event := range events {
+		err := o.handle(ctx, event)
+		if err == nil {
+			if err := o.OutboxRepository.CompleteOutboxEvent(ctx, event.ID); err != nil {
+				o.log.Errorf("%s: %v", op, err)
+			}
+			continue
+		}
+
+		o.fail(ctx, event, err)
+	}
+
+	return nil
+}
+
+func (o *OutboxService) handle(ctx context.Context, event models.OutboxEvent) error {
+	switch event.Action {
+	case models.OutboxActionCreateDeal:
+		return o.createDeal(ctx, event)
+	case models.OutboxActionUpdateDeal:
+		return o.updateDeal(ctx, event)
+	case models.OutboxActionMoveDeal:
+		return o.moveDeal(ctx, event)
+	case models.OutboxActionAddComment:
+		return o.addComment(ctx, event)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownAction, event.Action)
+	}
+}
+
+// fail откладывает событие с экспоненциальной задержкой, а после исчерпания попыток
+// переводит его в dead-letter. Лид помечается несинхронизированным, только если
+// потеряно изменение самой сделки, а не комментарий.
+// Событие, которое ждёт создания сделки, откладывается без траты попытки.
+func (o *OutboxService) fail(ctx context.Context, event models.OutboxEvent, cause error) {
+	const op = "OutboxService.fail"
+
+	// Пока create_deal не прошёл, последующие события лида ждут его сколько угодно,
+	// иначе при недоступности Bitrix они ушли бы в dead-letter раньше самой сделки
+	if errors.Is(cause, ErrDealNotCreated) {
+		next := time.Now().Add(o.cfg.BaseBackoff)
+
+		o.log.Debugf("%s: event %d deferred until deal is created", op, event.ID)
+
+		if err := o.OutboxRepository.RetryOutboxEvent(ctx, event.ID, event.Attempts, next, cause.Error()); err != nil {
+			o.log.Errorf("%s: %v", op, err)
+		}
+		return
+	}
+
+	attempts := event.Attempts + 1
+
+	if attempts >= o.cfg.MaxAttempts || errors.Is(cause, ErrUnknownAction) {
+		o.log.Errorf("%s: event %d moved to dead letter after %d attempts: %v", op, event.ID, attempts, cause)
+
+		if err := o.OutboxRepository.DeadOutboxEvent(ctx, event.ID, attempts, cause.Error()); err != nil {
+			o.log.Errorf("%s: %v", op, err)
+		}
+
+		if syncAction(event.Action) {
+			if err := o.LeadRepository.SetLeadSyncStatus(ctx, event.LeadID, models.LeadSyncFailed); err != nil {
+				o.log.Errorf("%s: %v", op, err)
+			}
+		}
+		return
+	}
+
+	next := time.Now().Add(o.backoff(attempts))
+
+	o.log.Warnf("%s: event %d attempt %d failed, next at %s: %v", op, event.ID, attempts, next.Format(time.RFC3339), cause)
+
+	if err := o.OutboxRepository.RetryOutboxEvent(ctx, event.ID, attempts, next, cause.Error()); err != nil {
+		o.log.Errorf("%s: %v", op, err)
+	}
+}
+
+// DeadEvents возвращает события, отправку которых outbox прекратил, по лиду leadID или все
+func (o *OutboxService) DeadEvents(ctx context.Context, leadID *int64) ([]models.OutboxEvent, error) {
+	const op = "OutboxService.DeadEvents"
+
+	events, err := o.OutboxRepository.DeadOutboxEvents(ctx, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// Requeue возвращает событие из dead-letter в очередь, например после устранения сбоя в Bitrix.
+// Лид перестаёт считаться несинхронизированным: при новой неудаче он снова будет помечен.
+func (o *OutboxService) Requeue(ctx context.Context, id int64) (models.OutboxEvent, error) {
+	const op = "OutboxService.Requeue"
+
+	event, err := o.OutboxRepository.RequeueOutboxEvent(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrOutboxEventNotFound) {
+			return models.OutboxEvent{}, ErrEventNotFound
+		}
+		return models.OutboxEvent{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if syncAction(event.Action) {
+		lead, err := o.LeadRepository.LeadByID(ctx, event.LeadID)
+		if err != nil {
+			return models.OutboxEvent{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		syncStatus := models.LeadSyncPending
+		if lead.BitrixDealID != nil {
+			syncStatus = models.LeadSyncSynced
+		}
+		if err := o.LeadRepository.SetLeadSyncStatus(ctx, lead.ID, syncStatus); err != nil {
+			return models.OutboxEvent{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	o.log.Infof("%s: event %d of lead %d requeued", op, event.ID, event.LeadID)
+
+	return event, nil
+}
+
+// syncAction сообщает, меняет ли действие саму сделку; потеря такого события
+// означает расхождение лида со сделкой
+func syncAction(action string) bool {
+	switch action {
+	case models.OutboxActionCreateDeal, models.OutboxActionUpdateDeal, models.OutboxActionMoveDeal:
+		return true
+	}
+	return false
+}
+
+func (o *OutboxService) backoff(attempts int) time.Duration {
+	delay := o.cfg.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= o.cfg.MaxBackoff {
+			return o.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
+
+func (o *OutboxService) createDeal(ctx context.Context, event models.OutboxEvent) error {
+	const op = "OutboxService.createDeal"
+
+	lead, err := o.LeadRepository.LeadByID(ctx, event.LeadID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Сделка уже создана предыдущей попыткой
+	if lead.BitrixDealID != nil {
+		return nil
+	}
+
+	var leadDTO dto.LeadDTO
+	if err := json.Unmarshal(event.Payload, &leadDTO); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Актуальные данные берём из БД, из события — только то, чего там нет
+	leadDTO.Name = lead.FIO
+	leadDTO.PhoneNumber = lead.PhoneNumber
+	leadDTO.Address = lead.Address
+	leadDTO.IsInternet = lead.Internet
+	leadDTO.IsCleaning = lead.Cleaning
+	leadDTO.IsShipping = lead.Shipping
+
+	// Сделка могла быть создана попыткой, которая не успела сохранить её ID,
+	// например если событие забрали повторно как зависшее
+	originID := dealOriginID(lead.ID)
+	dealID, err := o.BitrixService.DealByOrigin(ctx, originID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if dealID == 0 {
+		user, err := o.UserService.UserById(ctx, lead.UserID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		result, err := o.BitrixService.SendDeal(ctx, originID, leadDTO, user)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		dealID = int64(result.Result)
+	}
+
+	err = o.LeadRepository.SetLeadSynced(ctx, lead.ID, dealID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// dealOriginID — внешний ID сделки и контакта лида в Bitrix (поле ORIGIN_ID)
+func dealOriginID(leadID int64) string {
+	return "ia-online-lead-" + strconv.FormatInt(leadID, 10)
+}
+
+// updateDeal отправляет в сделку текущие данные лида. Пока сделка не создана,
+// событие откладывается: create_deal и так возьмёт актуальные данные из БД.
+func (o *OutboxService) updateDeal(ctx context.Context, event models.OutboxEvent) error {
+	const op = "OutboxService.updateDeal"
+
+	lead, err := o.LeadRepository.LeadByID(ctx, event.LeadID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if lead.BitrixDealID == nil {
+		return fmt.Errorf("%s: %w", op, ErrDealNotCreated)
+	}
+
+	leadDTO := dto.LeadDTO{
+		Name:        lead.FIO,
+		PhoneNumber: lead.PhoneNumber,
+		Address:     lead.Address,
+		IsInternet:  lead.Internet,
+		IsCleaning:  lead.Cleaning,
+		IsShipping:  lead.Shipping,
+	}
+
+	if err := o.BitrixService.UpdateDeal(ctx, *lead.BitrixDealID, leadDTO); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// moveDeal переводит сделку в стадию Bitrix, соответствующую статусу из события
+func (o *OutboxService) moveDeal(ctx context.Context, event models.OutboxEvent) error {
+	const op = "OutboxService.moveDeal"
+
+	var payload models.MoveDealPayload
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	lead, err := o.LeadRepository.LeadByID(ctx, event.LeadID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if lead.BitrixDealID == nil {
+		return fmt.Errorf("%s: %w", op, ErrDealNotCreated)
+	}
+
+	status, err := o.StatusService.StatusByID(ctx, payload.StatusID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := o.BitrixService.MoveDeal(ctx, *lead.BitrixDealID, status); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// addComment публикует комментарий в таймлайне сделки от имени автора
+func (o *OutboxService) addComment(ctx context.Context, event models.OutboxEvent) error {
+	const op = "OutboxService.addComment"
+
+	var payload models.AddCommentPayload
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	comment, err := o.CommentRepository.CommentByID(ctx, payload.CommentID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Комментарий уже опубликован предыдущей попыткой
+	if comment.BitrixCommentID != nil {
+		return nil
+	}
+
+	lead, err := o.LeadRepository.LeadByID(ctx, event.LeadID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if lead.BitrixDealID == nil {
+		return fmt.Errorf("%s: %w", op, ErrDealNotCreated)
+	}
+
+	text := comment.Text
+	if comment.AuthorName != nil {
+		text = fmt.Sprintf("%s: %s", *comment.AuthorName, comment.Text)
+	}
+
+	bitrixCommentID, err := o.BitrixService.AddTimelineComment(ctx, *lead.BitrixDealID, text)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = o.CommentRepository.SetCommentBitrixID(ctx, comment.ID, bitrixCommentID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,310 @@
+package paymentdetails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/lib/requisites"
+	"ia-online-golang/internal/lib/secret"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/storage"
+	"ia-online-golang/internal/utils"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Сколько записей перешифровывается за один запрос к БД
+const rotateBatchSize = 100
+
+// Сколько последних цифр номера видно в замаскированных реквизитах
+const visibleDigits = 4
+
+// PaymentDetailsService хранит платёжные реквизиты агентов: ИНН, номера карт и счетов
+// лежат в БД зашифрованными, полностью их видит только финансовый отдел
+type PaymentDetailsService struct {
+	log                      *logrus.Logger
+	keyring                  *secret.Keyring
+	PaymentDetailsRepository storage.PaymentDetailsRepositoryI
+}
+
+type PaymentDetailsServiceI interface {
+	PaymentDetails(ctx context.Context, userID int64) (models.PaymentDetails, error)
+	SavePaymentDetails(ctx context.Context, detailsDTO dto.PaymentDetailsDTO) (models.PaymentDetails, error)
+	Recipients(ctx context.Context, userIDs []int64) (map[int64]models.PaymentDetails, error)
+	RotateKeys(ctx context.Context) (int, error)
+}
+
+var (
+	ErrPaymentDetailsNotFound  = errors.New("payment details not found")
+	ErrPaymentDetailsForbidden = errors.New("payment details belong to another user")
+)
+
+func New(log *logrus.Logger, keyring *secret.Keyring, paymentDetailsRepository storage.PaymentDetailsRepositoryI) *PaymentDetailsService {
+	return &PaymentDetailsService{
+		log:                      log,
+		keyring:                  keyring,
+		PaymentDetailsRepository: paymentDetailsRepository,
+	}
+}
+
+// PaymentDetails возвращает реквизиты агента. Агент видит только свои, менеджер и финансы — любые;
+// без роли finance номера замаскированы.
+func (p *PaymentDetailsService) PaymentDetails(ctx context.Context, userID int64) (models.PaymentDetails, error) {
+	const op = "PaymentDetailsService.PaymentDetails"
+
+	roles, _ := ctx.Value(context_keys.UserRoleKey).([]string)
+	currentID, _ := ctx.Value(context_keys.UserIDKey).(int64)
+
+	if currentID != userID && !utils.Contains(roles, "manager") && !utils.Contains(roles, "finance") {
+		return models.PaymentDetails{}, ErrPaymentDetailsForbidden
+	}
+
+	record, err := p.PaymentDetailsRepository.PaymentDetailsByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrPaymentDetailsNotFound) {
+			return models.PaymentDetails{}, ErrPaymentDetailsNotFound
+		}
+		return models.PaymentDetails{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	details, err := p.decrypt(record)
+	if err != nil {
+		return models.PaymentDetails{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !utils.Contains(roles, "finance") {
+		details = mask(details)
+	}
+
+	return details, nil
+}
+
+// SavePaymentDetails сохраняет реквизиты текущего агента, заменяя прежние
+func (p *PaymentDetailsService) SavePaymentDetails(ctx context.Context, detailsDTO dto.PaymentDetailsDTO) (models.PaymentDetails, error) {
+	const op = "PaymentDetailsService.SavePaymentDetails"
+
+	userID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return models.PaymentDetails{}, fmt.Errorf("%s: error receiving userID", op)
+	}
+
+	details := models.PaymentDetails{
+		UserID:       userID,
+		Method:       detailsDTO.Method,
+		FullName:     detailsDTO.FullName,
+		SelfEmployed: detailsDTO.SelfEmployed,
+		INN:          requisites.Clean(detailsDTO.INN),
+	}
+
+	// Реквизиты другого способа выплаты не сохраняются, чтобы не хранить лишнего
+	switch detailsDTO.Method {
+	case models.PaymentMethodCard:
+		details.CardNumber = optional(requisites.Clean(detailsDTO.CardNumber))
+	case models.PaymentMethodAccount:
+		details.Account = optional(requisites.Clean(detailsDTO.Account))
+		details.BIK = optional(requisites.Clean(detailsDTO.BIK))
+		details.BankName = optional(detailsDTO.BankName)
+		details.CorrAccount = optional(requisites.Clean(detailsDTO.CorrAccount))
+	}
+
+	record, err := p.encrypt(details)
+	if err != nil {
+		return models.PaymentDetails{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.PaymentDetailsRepository.SavePaymentDetails(ctx, &record); err != nil {
+		return models.PaymentDetails{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.log.Infof("%s: user %d saved payment details (%s)", op, userID, details.Method)
+
+	details.CreatedAt = record.CreatedAt
+	details.UpdatedAt = record.UpdatedAt
+
+	return mask(details), nil
+}
+
+// Recipients расшифровывает реквизиты агентов для реестра платежей; агенты без реквизитов
+// в результат не попадают. Вызывающий сам отвечает за права доступа.
+func (p *PaymentDetailsService) Recipients(ctx context.Context, userIDs []int64) (map[int64]models.PaymentDetails, error) {
+	const op = "PaymentDetailsService.Recipients"
+
+	records, err := p.PaymentDetailsRepository.PaymentDetailsByUserIDs(ctx, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	recipients := make(map[int64]models.PaymentDetails, len(records))
+	for _, record := range records {
+		details, err := p.decrypt(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s: user %d: %w", op, record.UserID, err)
+		}
+		recipients[record.UserID] = details
+	}
+
+	return recipients, nil
+}
+
+// RotateKeys перешифровывает текущим ключом все записи, зашифрованные прежними ключами,
+// и возвращает число перешифрованных. После этого старые ключи можно убрать из конфига.
+func (p *PaymentDetailsService) RotateKeys(ctx context.Context) (int, error) {
+	const op = "PaymentDetailsService.RotateKeys"
+
+	current := p.keyring.CurrentKeyID()
+	rotated := 0
+
+	for {
+		records, err := p.PaymentDetailsRepository.PaymentDetailsWithStaleKey(ctx, current, rotateBatchSize)
+		if err != nil {
+			return rotated, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(records) == 0 {
+			break
+		}
+
+		for _, record := range records {
+			details, err := p.decrypt(record)
+			if err != nil {
+				return rotated, fmt.Errorf("%s: user %d: %w", op, record.UserID, err)
+			}
+
+			updated, err := p.encrypt(details)
+			if err != nil {
+				return rotated, fmt.Errorf("%s: user %d: %w", op, record.UserID, err)
+			}
+
+			err = p.PaymentDetailsRepository.ReencryptPaymentDetails(ctx, &updated, record.KeyID)
+			if err != nil {
+				// Агент пересохранил реквизиты — они уже зашифрованы текущим ключом
+				if errors.Is(err, storage.ErrPaymentDetailsChanged) {
+					continue
+				}
+				return rotated, fmt.Errorf("%s: user %d: %w", op, record.UserID, err)
+			}
+
+			rotated++
+		}
+	}
+
+	p.log.Infof("%s: %d records re-encrypted with key %s", op, rotated, current)
+
+	return rotated, nil
+}
+
+// additionalData привязывает шифротекст к агенту и полю
+func additionalData(userID int64, field string) string {
+	return fmt.Sprintf("payment_details:%d:%s", userID, field)
+}
+
+func (p *PaymentDetailsService) encrypt(details models.PaymentDetails) (models.PaymentDetailsRecord, error) {
+	record := models.PaymentDetailsRecord{
+		UserID:       details.UserID,
+		Method:       details.Method,
+		FullName:     details.FullName,
+		SelfEmployed: details.SelfEmployed,
+		BIK:          details.BIK,
+		BankName:     details.BankName,
+		CorrAccount:  details.CorrAccount,
+		KeyID:        p.keyring.CurrentKeyID(),
+	}
+
+	var err error
+	record.INNEncrypted, err = p.keyring.Encrypt(details.INN, additionalData(details.UserID, "inn"))
+	if err != nil {
+		return models.PaymentDetailsRecord{}, err
+	}
+
+	record.CardNumberEncrypted, err = p.encryptOptional(details.CardNumber, additionalData(details.UserID, "card_number"))
+	if err != nil {
+		return models.PaymentDetailsRecord{}, err
+	}
+
+	record.AccountEncrypted, err = p.encryptOptional(details.Account, additionalData(details.UserID, "account"))
+	if err != nil {
+		return models.PaymentDetailsRecord{}, err
+	}
+
+	return record, nil
+}
+
+func (p *PaymentDetailsService) encryptOptional(value *string, data string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	encrypted, err := p.keyring.Encrypt(*value, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encrypted, nil
+}
+
+func (p *PaymentDetailsService) decrypt(record models.PaymentDetailsRecord) (models.PaymentDetails, error) {
+	details := models.PaymentDetails{
+		UserID:       record.UserID,
+		Method:       record.Method,
+		FullName:     record.FullName,
+		SelfEmployed: record.SelfEmployed,
+		BIK:          record.BIK,
+		BankName:     record.BankName,
+		CorrAccount:  record.CorrAccount,
+		CreatedAt:    record.CreatedAt,
+		UpdatedAt:    record.UpdatedAt,
+	}
+
+	var err error
+	details.INN, err = p.keyring.Decrypt(record.KeyID, record.INNEncrypted, additionalData(record.UserID, "inn"))
+	if err != nil {
+		return models.PaymentDetails{}, fmt.Errorf("inn: %w", err)
+	}
+
+	details.CardNumber, err = p.decryptOptional(record.KeyID, record.CardNumberEncrypted, additionalData(record.UserID, "card_number"))
+	if err != nil {
+		return models.PaymentDetails{}, fmt.Errorf("card_number: %w", err)
+	}
+
+	details.Account, err = p.decryptOptional(record.KeyID, record.AccountEncrypted, additionalData(record.UserID, "account"))
+	if err != nil {
+		return models.PaymentDetails{}, fmt.Errorf("account: %w", err)
+	}
+
+	return details, nil
+}
+
+func (p *PaymentDetailsService) decryptOptional(keyID string, value *string, data string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	decrypted, err := p.keyring.Decrypt(keyID, *value, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decrypted, nil
+}
+
+// mask скрывает ИНН, номер карты и счёта, кроме последних цифр
+func mask(details models.PaymentDetails) models.PaymentDetails {
+	details.INN = requisites.Mask(details.INN, visibleDigits)
+	if details.CardNumber != nil {
+		details.CardNumber = optional(requisites.Mask(*details.CardNumber, visibleDigits))
+	}
+	if details.Account != nil {
+		details.Account = optional(requisites.Mask(*details.Account, visibleDigits))
+	}
+	details.Masked = true
+
+	return details
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
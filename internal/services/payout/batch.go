@@ -0,0 +1,294 @@
+package payout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/lib/registry"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/storage"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPayoutBatchNotFound   = errors.New("payout batch not found")
+	ErrPayoutBatchEmpty      = errors.New("no approved payouts for batch")
+	ErrUnknownFormat         = errors.New("unknown registry format")
+	ErrInvalidConfirmation   = errors.New("invalid confirmation file")
+	ErrPaymentDetailsMissing = errors.New("agents have no payment details")
+	ErrUnsupportedPayment    = errors.New("payment is not supported by registry format")
+)
+
+// CreateBatch собирает все одобренные заявки, ещё не отправленные в банк, в новую пачку
+func (p *PayoutService) CreateBatch(ctx context.Context) (models.PayoutBatch, error) {
+	const op = "PayoutService.CreateBatch"
+
+	managerID, err := managerID(ctx)
+	if err != nil {
+		return models.PayoutBatch{}, err
+	}
+
+	batch := models.PayoutBatch{CreatedBy: &managerID}
+	if err := p.PayoutBatchRepository.CreatePayoutBatch(ctx, &batch); err != nil {
+		if errors.Is(err, storage.ErrPayoutBatchEmpty) {
+			return models.PayoutBatch{}, ErrPayoutBatchEmpty
+		}
+		return models.PayoutBatch{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.log.Infof("%s: manager %d created batch %d: %d payouts for %s", op, managerID, batch.ID, batch.Count, batch.Total)
+
+	return batch, nil
+}
+
+func (p *PayoutService) Batches(ctx context.Context, limit, offset int64) ([]models.PayoutBatch, error) {
+	const op = "PayoutService.Batches"
+
+	batches, err := p.PayoutBatchRepository.PayoutBatches(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return batches, nil
+}
+
+// Batch возвращает пачку вместе с её заявками
+func (p *PayoutService) Batch(ctx context.Context, id int64) (models.PayoutBatch, error) {
+	const op = "PayoutService.Batch"
+
+	batch, err := p.batch(ctx, id)
+	if err != nil {
+		return models.PayoutBatch{}, err
+	}
+
+	batch.Payouts, err = p.PayoutBatchRepository.PayoutBatchItems(ctx, id)
+	if err != nil {
+		return models.PayoutBatch{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return batch, nil
+}
+
+// ExportBatch выгружает реестр платежей по ещё не оплаченным заявкам пачки в указанном формате.
+// Реестр содержит реквизиты агентов полностью, поэтому доступен только менеджеру с ролью finance.
+func (p *PayoutService) ExportBatch(ctx context.Context, id int64, format string) (registry.File, error) {
+	const op = "PayoutService.ExportBatch"
+
+	if _, err := managerID(ctx); err != nil {
+		return registry.File{}, err
+	}
+	if !isFinance(ctx) {
+		return registry.File{}, ErrPayoutForbidden
+	}
+
+	writer, err := registry.Lookup(format)
+	if err != nil {
+		return registry.File{}, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	batch, err := p.Batch(ctx, id)
+	if err != nil {
+		return registry.File{}, err
+	}
+
+	var approved []models.PayoutBatchItem
+	var userIDs []int64
+	for _, item := range batch.Payouts {
+		if item.Status == models.PayoutStatusApproved {
+			approved = append(approved, item)
+			userIDs = append(userIDs, item.UserID)
+		}
+	}
+
+	if len(approved) == 0 {
+		return registry.File{}, ErrPayoutBatchEmpty
+	}
+
+	recipients, err := p.PaymentDetailsService.Recipients(ctx, userIDs)
+	if err != nil {
+		return registry.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	reg := registry.Registry{
+		BatchID:   batch.ID,
+		CreatedAt: time.Now(),
+		Payer:     registry.Payer(p.cfg.Payer),
+	}
+
+	var missing []string
+	for _, item := range approved {
+		details, ok := recipients[item.UserID]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%d", item.ID))
+			continue
+		}
+
+		reg.Payments = append(reg.Payments, registry.Payment{
+			PayoutID:  item.ID,
+			Amount:    item.Amount,
+			Recipient: recipient(details),
+			Purpose:   paymentPurpose(item.ID, details.SelfEmployed),
+		})
+	}
+
+	if len(missing) > 0 {
+		return registry.File{}, fmt.Errorf("%w: payouts %s", ErrPaymentDetailsMissing, strings.Join(missing, ", "))
+	}
+
+	var buf bytes.Buffer
+	if err := writer.Write(&buf, reg); err != nil {
+		if errors.Is(err, registry.ErrUnsupportedPayment) {
+			return registry.File{}, fmt.Errorf("%w: %v", ErrUnsupportedPayment, err)
+		}
+		return registry.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.PayoutBatchRepository.MarkPayoutBatchExported(ctx, id); err != nil {
+		return registry.File{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.log.Infof("%s: batch %d exported as %s: %d payments for %s", op, id, format, len(reg.Payments), reg.Total())
+
+	return registry.File{
+		Name:        fmt.Sprintf("payouts_%d.%s", id, writer.Extension()),
+		ContentType: writer.ContentType(),
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+// ImportConfirmation читает ответный файл банка и отмечает оплаченными подтверждённые заявки пачки.
+// Повторная загрузка того же файла безопасна: уже оплаченные заявки только попадают в отчёт.
+func (p *PayoutService) ImportConfirmation(ctx context.Context, id int64, format string, file io.Reader) (dto.PayoutBatchImportDTO, error) {
+	const op = "PayoutService.ImportConfirmation"
+
+	if _, err := managerID(ctx); err != nil {
+		return dto.PayoutBatchImportDTO{}, err
+	}
+
+	reader, err := registry.Lookup(format)
+	if err != nil {
+		return dto.PayoutBatchImportDTO{}, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	batch, err := p.Batch(ctx, id)
+	if err != nil {
+		return dto.PayoutBatchImportDTO{}, err
+	}
+
+	confirmations, err := reader.ReadConfirmation(file, registry.Payer(p.cfg.Payer))
+	if err != nil {
+		// Не задан плательщик — ошибка конфигурации, а не файла
+		if errors.Is(err, registry.ErrPayerAccountMissing) {
+			return dto.PayoutBatchImportDTO{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return dto.PayoutBatchImportDTO{}, fmt.Errorf("%w: %v", ErrInvalidConfirmation, err)
+	}
+
+	items := make(map[int64]models.PayoutBatchItem, len(batch.Payouts))
+	for _, item := range batch.Payouts {
+		items[item.ID] = item
+	}
+
+	result := dto.PayoutBatchImportDTO{
+		BatchID:     id,
+		Paid:        []int64{},
+		AlreadyPaid: []int64{},
+		Failed:      []dto.PayoutImportIssueDTO{},
+		Skipped:     []dto.PayoutImportIssueDTO{},
+	}
+
+	skip := func(payoutID int64, reason string) {
+		result.Skipped = append(result.Skipped, dto.PayoutImportIssueDTO{PayoutID: payoutID, Reason: reason})
+	}
+
+	for _, confirmation := range confirmations {
+		item, ok := items[confirmation.PayoutID]
+		switch {
+		case !ok:
+			skip(confirmation.PayoutID, "заявки нет в этой пачке")
+			continue
+		case confirmation.Amount != 0 && confirmation.Amount != item.Amount:
+			skip(item.ID, fmt.Sprintf("сумма в файле %s не совпадает с заявкой %s", confirmation.Amount, item.Amount))
+			continue
+		case !confirmation.Paid:
+			result.Failed = append(result.Failed, dto.PayoutImportIssueDTO{PayoutID: item.ID, Reason: confirmation.Reason})
+			continue
+		case item.Status == models.PayoutStatusPaid:
+			result.AlreadyPaid = append(result.AlreadyPaid, item.ID)
+			continue
+		case item.Status != models.PayoutStatusApproved:
+			skip(item.ID, fmt.Sprintf("заявка в статусе %s", item.Status))
+			continue
+		}
+
+		decision := dto.PayoutDecisionDTO{Comment: fmt.Sprintf("Оплата подтверждена банком, пачка №%d", id)}
+		if _, err := p.MarkPayoutPaid(ctx, item.ID, decision); err != nil {
+			if errors.Is(err, ErrPayoutInvalidStatus) {
+				skip(item.ID, "статус заявки изменился")
+				continue
+			}
+			return dto.PayoutBatchImportDTO{}, fmt.Errorf("%s: payout %d: %w", op, item.ID, err)
+		}
+
+		result.Paid = append(result.Paid, item.ID)
+	}
+
+	if err := p.PayoutBatchRepository.CompletePayoutBatch(ctx, id); err != nil {
+		return dto.PayoutBatchImportDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updated, err := p.batch(ctx, id)
+	if err != nil {
+		return dto.PayoutBatchImportDTO{}, err
+	}
+	result.Completed = updated.Status == models.PayoutBatchStatusCompleted
+
+	p.log.Infof("%s: batch %d: %d paid, %d failed, %d skipped", op, id, len(result.Paid), len(result.Failed), len(result.Skipped))
+
+	return result, nil
+}
+
+func recipient(details models.PaymentDetails) registry.Recipient {
+	value := func(v *string) string {
+		if v == nil {
+			return ""
+		}
+		return *v
+	}
+
+	return registry.Recipient{
+		Name:        details.FullName,
+		INN:         details.INN,
+		CardNumber:  value(details.CardNumber),
+		Account:     value(details.Account),
+		BankName:    value(details.BankName),
+		BIK:         value(details.BIK),
+		CorrAccount: value(details.CorrAccount),
+	}
+}
+
+// paymentPurpose — назначение платежа; самозанятый сам платит налог на профессиональный доход
+func paymentPurpose(payoutID int64, selfEmployed bool) string {
+	if selfEmployed {
+		return fmt.Sprintf("Оплата агентских услуг по заявке №%d. Самозанятый, НПД. НДС не облагается", payoutID)
+	}
+	return fmt.Sprintf("Выплата агентского вознаграждения по заявке №%d. НДС не облагается", payoutID)
+}
+
+func (p *PayoutService) batch(ctx context.Context, id int64) (models.PayoutBatch, error) {
+	const op = "PayoutService.batch"
+
+	batch, err := p.PayoutBatchRepository.PayoutBatchByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrPayoutBatchNotFound) {
+			return models.PayoutBatch{}, ErrPayoutBatchNotFound
+		}
+		return models.PayoutBatch{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return batch, nil
+}
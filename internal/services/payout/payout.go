@@ -0,0 +1,447 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/config"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/lib/money"
+	"ia-online-golang/internal/lib/registry"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/services/email"
+	"ia-online-golang/internal/services/lead"
+	"ia-online-golang/internal/services/paymentdetails"
+	"ia-online-golang/internal/services/user"
+	"ia-online-golang/internal/storage"
+	"ia-online-golang/internal/utils"
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PayoutService ведёт заявки агентов на вывод вознаграждения от подачи до оплаты
+type PayoutService struct {
+	log                   *logrus.Logger
+	cfg                   config.PayoutsConfig
+	PayoutRepository      storage.PayoutRepositoryI
+	PayoutBatchRepository storage.PayoutBatchRepositoryI
+	UserService           user.UserServiceI
+	EmailService          email.EmailServiceI
+	PaymentDetailsService paymentdetails.PaymentDetailsServiceI
+	LeadService           lead.LeadServiceI
+}
+
+type PayoutServiceI interface {
+	Funds(ctx context.Context) (dto.PayoutFundsDTO, error)
+	RequestPayout(ctx context.Context, requestDTO dto.PayoutRequestDTO) (models.Payout, error)
+	Payouts(ctx context.Context, filterDTO dto.PayoutFilterDTO) ([]models.Payout, error)
+	Payout(ctx context.Context, id int64) (models.Payout, error)
+	ApprovePayout(ctx context.Context, id int64, decisionDTO dto.PayoutDecisionDTO) (models.Payout, error)
+	RejectPayout(ctx context.Context, id int64, rejectDTO dto.PayoutRejectDTO) (models.Payout, error)
+	MarkPayoutPaid(ctx context.Context, id int64, decisionDTO dto.PayoutDecisionDTO) (models.Payout, error)
+	PayPendingLeads(ctx context.Context) error
+	CreateBatch(ctx context.Context) (models.PayoutBatch, error)
+	Batches(ctx context.Context, limit, offset int64) ([]models.PayoutBatch, error)
+	Batch(ctx context.Context, id int64) (models.PayoutBatch, error)
+	ExportBatch(ctx context.Context, id int64, format string) (registry.File, error)
+	ImportConfirmation(ctx context.Context, id int64, format string, file io.Reader) (dto.PayoutBatchImportDTO, error)
+}
+
+var (
+	ErrPayoutNotFound          = errors.New("payout not found")
+	ErrPayoutForbidden         = errors.New("payout belongs to another user")
+	ErrPayoutBelowMinimum      = errors.New("payout amount is below minimum")
+	ErrPayoutInsufficientFunds = errors.New("insufficient funds for payout")
+	ErrPayoutExists            = errors.New("user already has an open payout")
+	ErrPayoutInvalidStatus     = errors.New("payout status does not allow this action")
+	ErrPaymentDetailsRequired  = errors.New("payment details are required")
+	ErrPayoutExported          = errors.New("payout is in exported batch")
+)
+
+func New(
+	log *logrus.Logger,
+	cfg config.PayoutsConfig,
+	payoutRepository storage.PayoutRepositoryI,
+	payoutBatchRepository storage.PayoutBatchRepositoryI,
+	userService user.UserServiceI,
+	emailService email.EmailServiceI,
+	paymentDetailsService paymentdetails.PaymentDetailsServiceI,
+	leadService lead.LeadServiceI,
+) *PayoutService {
+	return &PayoutService{
+		log:                   log,
+		cfg:                   cfg,
+		PayoutRepository:      payoutRepository,
+		PayoutBatchRepository: payoutBatchRepository,
+		UserService:           userService,
+		EmailService:          emailService,
+		PaymentDetailsService: paymentDetailsService,
+		LeadService:           leadService,
+	}
+}
+
+// Funds возвращает деньги текущего агента, доступные к выводу
+func (p *PayoutService) Funds(ctx context.Context) (dto.PayoutFundsDTO, error) {
+	const op = "PayoutService.Funds"
+
+	userID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return dto.PayoutFundsDTO{}, fmt.Errorf("%s: error receiving userID", op)
+	}
+
+	funds, err := p.PayoutRepository.PayoutFunds(ctx, userID, p.heldSince())
+	if err != nil {
+		return dto.PayoutFundsDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return dto.PayoutFundsDTO{
+		Balance:   funds.Balance,
+		Held:      funds.Held,
+		Reserved:  funds.Reserved,
+		Available: funds.Available(),
+		MinAmount: p.minAmount(),
+	}, nil
+}
+
+// RequestPayout создаёт заявку текущего агента на вывод
+func (p *PayoutService) RequestPayout(ctx context.Context, requestDTO dto.PayoutRequestDTO) (models.Payout, error) {
+	const op = "PayoutService.RequestPayout"
+
+	userID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return models.Payout{}, fmt.Errorf("%s: error receiving userID", op)
+	}
+
+	if requestDTO.Amount < p.minAmount() {
+		return models.Payout{}, fmt.Errorf("%w: %s", ErrPayoutBelowMinimum, p.minAmount())
+	}
+
+	// Без реквизитов заявку нечем будет оплатить
+	if _, err := p.PaymentDetailsService.PaymentDetails(ctx, userID); err != nil {
+		if errors.Is(err, paymentdetails.ErrPaymentDetailsNotFound) {
+			return models.Payout{}, ErrPaymentDetailsRequired
+		}
+		return models.Payout{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	payout := models.Payout{
+		UserID: userID,
+		Amount: requestDTO.Amount,
+	}
+	if requestDTO.Comment != "" {
+		payout.Comment = &requestDTO.Comment
+	}
+
+	err := p.PayoutRepository.CreatePayout(ctx, &payout, p.heldSince())
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPayoutInsufficientFunds):
+			return models.Payout{}, ErrPayoutInsufficientFunds
+		case errors.Is(err, storage.ErrPayoutExists):
+			return models.Payout{}, ErrPayoutExists
+		}
+		return models.Payout{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.log.Infof("%s: user %d requested payout %d for %s", op, userID, payout.ID, payout.Amount)
+
+	p.notify(ctx, payout)
+
+	return payout, nil
+}
+
+// Payouts возвращает заявки: агенту — только свои, менеджеру — все или по user_id
+func (p *PayoutService) Payouts(ctx context.Context, filterDTO dto.PayoutFilterDTO) ([]models.Payout, error) {
+	const op = "PayoutService.Payouts"
+
+	if !isManager(ctx) {
+		userID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+		if !ok {
+			return nil, fmt.Errorf("%s: error receiving userID", op)
+		}
+		filterDTO.UserID = &userID
+	}
+
+	payouts, err := p.PayoutRepository.Payouts(ctx, models.PayoutFilter{
+		UserID: filterDTO.UserID,
+		Status: filterDTO.Status,
+		Limit:  filterDTO.Limit,
+		Offset: filterDTO.Offset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return payouts, nil
+}
+
+// Payout возвращает заявку вместе с журналом её обработки
+func (p *PayoutService) Payout(ctx context.Context, id int64) (models.Payout, error) {
+	const op = "PayoutService.Payout"
+
+	payout, err := p.payout(ctx, id)
+	if err != nil {
+		return models.Payout{}, err
+	}
+
+	if !isManager(ctx) {
+		if userID, _ := ctx.Value(context_keys.UserIDKey).(int64); userID != payout.UserID {
+			return models.Payout{}, ErrPayoutForbidden
+		}
+	}
+
+	payout.Events, err = p.PayoutRepository.PayoutEvents(ctx, id)
+	if err != nil {
+		return models.Payout{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return payout, nil
+}
+
+func (p *PayoutService) ApprovePayout(ctx context.Context, id int64, decisionDTO dto.PayoutDecisionDTO) (models.Payout, error) {
+	const op = "PayoutService.ApprovePayout"
+
+	managerID, err := managerID(ctx)
+	if err != nil {
+		return models.Payout{}, err
+	}
+
+	payout, err := p.payout(ctx, id)
+	if err != nil {
+		return models.Payout{}, err
+	}
+
+	now := time.Now()
+	payout.Status = models.PayoutStatusApproved
+	payout.ProcessedBy = &managerID
+	payout.ApprovedAt = &now
+
+	event := models.PayoutEvent{UserID: &managerID, Comment: optional(decisionDTO.Comment)}
+
+	err = p.PayoutRepository.UpdatePayoutStatus(ctx, &payout, []string{models.PayoutStatusRequested}, &event)
+	if err != nil {
+		return models.Payout{}, p.storageError(op, err)
+	}
+
+	p.log.Infof("%s: manager %d approved payout %d", op, managerID, payout.ID)
+
+	p.notify(ctx, payout)
+
+	return payout, nil
+}
+
+func (p *PayoutService) RejectPayout(ctx context.Context, id int64, rejectDTO dto.PayoutRejectDTO) (models.Payout, error) {
+	const op = "PayoutService.RejectPayout"
+
+	managerID, err := managerID(ctx)
+	if err != nil {
+		return models.Payout{}, err
+	}
+
+	payout, err := p.payout(ctx, id)
+	if err != nil {
+		return models.Payout{}, err
+	}
+
+	payout.Status = models.PayoutStatusRejected
+	payout.ProcessedBy = &managerID
+	payout.RejectReason = &rejectDTO.Reason
+
+	event := models.PayoutEvent{UserID: &managerID, Comment: &rejectDTO.Reason}
+
+	from := []string{models.PayoutStatusRequested, models.PayoutStatusApproved}
+	err = p.PayoutRepository.UpdatePayoutStatus(ctx, &payout, from, &event)
+	if err != nil {
+		return models.Payout{}, p.storageError(op, err)
+	}
+
+	p.log.Infof("%s: manager %d rejected payout %d", op, managerID, payout.ID)
+
+	p.notify(ctx, payout)
+
+	return payout, nil
+}
+
+// MarkPayoutPaid отмечает одобренную заявку оплаченной, списывает сумму с баланса агента
+// и закрывает покрытые выплатами лиды
+func (p *PayoutService) MarkPayoutPaid(ctx context.Context, id int64, decisionDTO dto.PayoutDecisionDTO) (models.Payout, error) {
+	const op = "PayoutService.MarkPayoutPaid"
+
+	managerID, err := managerID(ctx)
+	if err != nil {
+		return models.Payout{}, err
+	}
+
+	payout, err := p.payout(ctx, id)
+	if err != nil {
+		return models.Payout{}, err
+	}
+
+	payout.ProcessedBy = &managerID
+
+	event := models.PayoutEvent{UserID: &managerID, Comment: optional(decisionDTO.Comment)}
+
+	if err := p.PayoutRepository.MarkPayoutPaid(ctx, &payout, &event); err != nil {
+		return models.Payout{}, p.storageError(op, err)
+	}
+
+	p.log.Infof("%s: manager %d marked payout %d as paid", op, managerID, payout.ID)
+
+	// Выплата уже проведена, поэтому сбой на лидах не отменяет её: планировщик повторит PayPendingLeads
+	if err := p.payLeads(ctx, payout); err != nil {
+		p.log.Errorf("%s: payout %d: %v", op, payout.ID, err)
+	}
+
+	p.notify(ctx, payout)
+
+	return payout, nil
+}
+
+// PayPendingLeads закрывает лиды по оплаченным заявкам, на которых это не удалось сразу после оплаты
+func (p *PayoutService) PayPendingLeads(ctx context.Context) error {
+	const op = "PayoutService.PayPendingLeads"
+
+	payouts, err := p.PayoutRepository.PayoutsWithUnpaidLeads(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var failed int
+	for _, payout := range payouts {
+		if err := p.payLeads(ctx, payout); err != nil {
+			p.log.Errorf("%s: payout %d: %v", op, payout.ID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%s: %d of %d payouts failed", op, failed, len(payouts))
+	}
+
+	return nil
+}
+
+// payLeads отмечает оплаченными покрытые выплатой лиды и запоминает, что заявка закрыта
+func (p *PayoutService) payLeads(ctx context.Context, payout models.Payout) error {
+	const op = "PayoutService.payLeads"
+
+	if err := p.LeadService.PayLeads(ctx, payout); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := p.PayoutRepository.MarkPayoutLeadsPaid(ctx, payout.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *PayoutService) payout(ctx context.Context, id int64) (models.Payout, error) {
+	const op = "PayoutService.payout"
+
+	payout, err := p.PayoutRepository.PayoutByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrPayoutNotFound) {
+			return models.Payout{}, ErrPayoutNotFound
+		}
+		return models.Payout{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return payout, nil
+}
+
+func (p *PayoutService) storageError(op string, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrPayoutNotFound):
+		return ErrPayoutNotFound
+	case errors.Is(err, storage.ErrPayoutStatusChanged):
+		return ErrPayoutInvalidStatus
+	case errors.Is(err, storage.ErrPayoutExported):
+		return ErrPayoutExported
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// notify сообщает агенту о смене статуса заявки, а о новой заявке — ещё и менеджеру.
+// Ошибка отправки не отменяет саму операцию.
+func (p *PayoutService) notify(ctx context.Context, payout models.Payout) {
+	const op = "PayoutService.notify"
+
+	subject, body := payoutMessage(payout)
+
+	agent, err := p.UserService.UserById(ctx, payout.UserID)
+	if err != nil {
+		p.log.Warnf("%s: payout %d: %v", op, payout.ID, err)
+	} else if err := p.EmailService.SendEmail(ctx, agent.Email, subject, body); err != nil {
+		p.log.Warnf("%s: payout %d: %v", op, payout.ID, err)
+	}
+
+	if payout.Status == models.PayoutStatusRequested && p.cfg.ManagerEmail != "" {
+		managerBody := fmt.Sprintf("<p>Агент %s (%s) запросил выплату %s ₽, заявка №%d.</p>", agent.Name, agent.Email, payout.Amount, payout.ID)
+		if err := p.EmailService.SendEmail(ctx, p.cfg.ManagerEmail, "Новая заявка на выплату", managerBody); err != nil {
+			p.log.Warnf("%s: payout %d: %v", op, payout.ID, err)
+		}
+	}
+}
+
+func payoutMessage(payout models.Payout) (string, string) {
+	switch payout.Status {
+	case models.PayoutStatusApproved:
+		return "Заявка на выплату одобрена",
+			fmt.Sprintf("<p>Заявка №%d на %s ₽ одобрена и передана на оплату.</p>", payout.ID, payout.Amount)
+	case models.PayoutStatusRejected:
+		reason := ""
+		if payout.RejectReason != nil {
+			reason = *payout.RejectReason
+		}
+		return "Заявка на выплату отклонена",
+			fmt.Sprintf("<p>Заявка №%d на %s ₽ отклонена.</p><p>Причина: %s</p>", payout.ID, payout.Amount, reason)
+	case models.PayoutStatusPaid:
+		return "Выплата отправлена",
+			fmt.Sprintf("<p>Выплата по заявке №%d на %s ₽ отправлена.</p>", payout.ID, payout.Amount)
+	default:
+		return "Заявка на выплату принята",
+			fmt.Sprintf("<p>Заявка №%d на %s ₽ принята и ждёт рассмотрения.</p>", payout.ID, payout.Amount)
+	}
+}
+
+func (p *PayoutService) heldSince() time.Time {
+	return time.Now().Add(-p.cfg.Hold)
+}
+
+func (p *PayoutService) minAmount() money.Amount {
+	return money.FromRubles(p.cfg.MinAmount)
+}
+
+func isManager(ctx context.Context) bool {
+	roles, _ := ctx.Value(context_keys.UserRoleKey).([]string)
+	return utils.Contains(roles, "manager")
+}
+
+func isFinance(ctx context.Context) bool {
+	roles, _ := ctx.Value(context_keys.UserRoleKey).([]string)
+	return utils.Contains(roles, "finance")
+}
+
+func managerID(ctx context.Context) (int64, error) {
+	if !isManager(ctx) {
+		return 0, ErrPayoutForbidden
+	}
+
+	userID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return 0, fmt.Errorf("error receiving userID")
+	}
+
+	return userID, nil
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,128 @@
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/services/bitrix"
+	"ia-online-golang/internal/services/lead"
+	"ia-online-golang/internal/services/status"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Пауза между страницами crm.deal.list, чтобы не упираться в лимит запросов Bitrix
+const pageDelay = 500 * time.Millisecond
+
+// ReconciliationService сверяет статусы и вознаграждения лидов со сделками Bitrix
+// на случай, если исходящий вебхук не дошёл, и ищет лиды, сделки которых пропали из Bitrix.
+type ReconciliationService struct {
+	log           *logrus.Logger
+	BitrixService bitrix.BitrixServiceI
+	LeadService   lead.LeadServiceI
+}
+
+type ReconciliationServiceI interface {
+	Reconcile(ctx context.Context) (dto.ReconciliationReport, error)
+}
+
+func New(log *logrus.Logger, bitrixService bitrix.BitrixServiceI, leadService lead.LeadServiceI) *ReconciliationService {
+	return &ReconciliationService{
+		log:           log,
+		BitrixService: bitrixService,
+		LeadService:   leadService,
+	}
+}
+
+func (r *ReconciliationService) Reconcile(ctx context.Context) (dto.ReconciliationReport, error) {
+	const op = "ReconciliationService.Reconcile"
+
+	report := dto.ReconciliationReport{
+		StartedAt:     time.Now(),
+		MissingDeals:  []int64{},
+		UnknownStages: map[string]int{},
+		PendingDeals:  []int64{},
+		LostLeads:     []int64{},
+	}
+
+	// Лиды со сделками снимаем до обхода: сделки, созданные во время сверки, не считаются потерянными
+	dealLeads, err := r.LeadService.LeadDealIDs(ctx)
+	if err != nil {
+		return report, fmt.Errorf("%s: %w", op, err)
+	}
+
+	start := 0
+	for {
+		page, err := r.BitrixService.ListDeals(ctx, start)
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, deal := range page.Result {
+			r.reconcileDeal(ctx, deal, &report)
+
+			if id, err := strconv.ParseInt(deal.ID, 10, 64); err == nil {
+				delete(dealLeads, id)
+			}
+		}
+
+		if page.Next == 0 {
+			break
+		}
+		start = page.Next
+
+		select {
+		case <-ctx.Done():
+			return report, fmt.Errorf("%s: %w", op, ctx.Err())
+		case <-time.After(pageDelay):
+		}
+	}
+
+	for _, leadID := range dealLeads {
+		report.LostLeads = append(report.LostLeads, leadID)
+	}
+	sort.Slice(report.LostLeads, func(i, j int) bool { return report.LostLeads[i] < report.LostLeads[j] })
+	report.Lost = len(report.LostLeads)
+
+	report.FinishedAt = time.Now()
+
+	r.log.Infof("%s: total=%d fixed=%d unchanged=%d missing=%d unknown=%d pending=%d failed=%d lost=%d",
+		op, report.Total, report.Fixed, report.Unchanged, report.Missing, report.Unknown, report.Pending, report.Failed, report.Lost)
+
+	return report, nil
+}
+
+func (r *ReconciliationService) reconcileDeal(ctx context.Context, deal bitrix.InfoDeal, report *dto.ReconciliationReport) {
+	const op = "ReconciliationService.reconcileDeal"
+
+	report.Total++
+
+	changed, err := r.LeadService.ApplyDeal(ctx, deal, models.HistorySourceReconciliation)
+	switch {
+	case err == nil && changed:
+		report.Fixed++
+	case err == nil:
+		report.Unchanged++
+	case errors.Is(err, lead.ErrLeadNotFound):
+		report.Missing++
+		if id, err := strconv.ParseInt(deal.ID, 10, 64); err == nil {
+			report.MissingDeals = append(report.MissingDeals, id)
+		}
+	case errors.Is(err, lead.ErrLeadOutboxPending):
+		report.Pending++
+		if id, err := strconv.ParseInt(deal.ID, 10, 64); err == nil {
+			report.PendingDeals = append(report.PendingDeals, id)
+		}
+	case errors.Is(err, status.ErrStageNotMapped):
+		report.Unknown++
+		report.UnknownStages[deal.Status]++
+	default:
+		report.Failed++
+		r.log.Errorf("%s: deal %s: %v", op, deal.ID, err)
+	}
+}
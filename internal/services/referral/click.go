@@ -0,0 +1,130 @@
+package referral
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/storage"
+	"ia-online-golang/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// Системное имя статуса оплаченного лида для воронки
+const paidStatusName = "paid"
+
+var (
+	ErrReferralCodeNotFound = errors.New("referral code not found")
+)
+
+// RecordClick записывает переход по реферальной ссылке. Токен перехода кладётся в cookie
+// и при регистрации связывает нового пользователя с агентом.
+func (r *ReferralService) RecordClick(ctx context.Context, clickDTO dto.ReferralClickDTO) (models.ReferralClick, error) {
+	const op = "ReferralService.RecordClick"
+
+	inviter, err := r.UserRepository.UserByReferralCode(ctx, clickDTO.Code)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.ReferralClick{}, ErrReferralCodeNotFound
+		}
+		return models.ReferralClick{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	click := models.ReferralClick{
+		Token:        uuid.New().String(),
+		InviterID:    inviter.ID,
+		ReferralCode: inviter.ReferralCode,
+		UserAgent:    optional(clickDTO.UserAgent),
+		IPHash:       optional(r.hashIP(clickDTO.IP)),
+		UTMSource:    optional(clickDTO.UTMSource),
+		UTMMedium:    optional(clickDTO.UTMMedium),
+		UTMCampaign:  optional(clickDTO.UTMCampaign),
+		UTMTerm:      optional(clickDTO.UTMTerm),
+		UTMContent:   optional(clickDTO.UTMContent),
+	}
+
+	if err := r.ReferralClickRepository.SaveReferralClick(ctx, &click); err != nil {
+		return models.ReferralClick{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return click, nil
+}
+
+// Funnel возвращает воронку агентов за период. Агент видит только свою воронку,
+// менеджер — любого агента или, без UserID, всех агентов с переходами или рефералами.
+func (r *ReferralService) Funnel(ctx context.Context, filterDTO dto.ReferralFunnelFilterDTO) ([]dto.ReferralFunnelDTO, error) {
+	const op = "ReferralService.Funnel"
+
+	currentID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return nil, fmt.Errorf("%s: error receiving userID", op)
+	}
+
+	roles, _ := ctx.Value(context_keys.UserRoleKey).([]string)
+	isManager := utils.Contains(roles, "manager")
+
+	filter := models.ReferralFunnelFilter{
+		InviterID: filterDTO.UserID,
+		From:      filterDTO.From,
+		To:        filterDTO.To,
+	}
+
+	if !isManager {
+		if filterDTO.UserID != nil && *filterDTO.UserID != currentID {
+			return nil, ErrReferralForbidden
+		}
+		filter.InviterID = &currentID
+	}
+
+	paid, err := r.StatusService.StatusByName(ctx, paidStatusName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	filter.PaidStatusID = paid.ID
+
+	funnels, err := r.ReferralClickRepository.ReferralFunnel(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	funnelsDTO := make([]dto.ReferralFunnelDTO, 0, len(funnels))
+	for _, f := range funnels {
+		funnelsDTO = append(funnelsDTO, dto.ReferralFunnelDTO{
+			UserID:             f.User.ID,
+			Name:               f.User.Name,
+			PhoneNumber:        f.User.PhoneNumber,
+			City:               f.User.City,
+			Clicks:             f.Clicks,
+			Visitors:           f.Visitors,
+			ClickRegistrations: f.ClickRegistrations,
+			Registrations:      f.Registrations,
+			Activated:          f.Activated,
+			FirstPaidLead:      f.FirstPaidLead,
+		})
+	}
+
+	return funnelsDTO, nil
+}
+
+// hashIP хеширует IP с солью из конфига, чтобы отличать посетителей, не храня их адреса.
+// Без соли IP не сохраняется.
+func (r *ReferralService) hashIP(ip string) string {
+	if ip == "" || r.cfg.Clicks.IPSalt == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(r.cfg.Clicks.IPSalt + ip))
+	return hex.EncodeToString(sum[:])
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
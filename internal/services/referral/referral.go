@@ -4,26 +4,65 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"ia-online-golang/internal/config"
 	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/lib/money"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/services/email"
+	"ia-online-golang/internal/services/status"
 	"ia-online-golang/internal/storage"
+	"ia-online-golang/internal/utils"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type ReferralService struct {
-	log                *logrus.Logger
-	ReferralRepository storage.ReferralRepositoryI
+	log                     *logrus.Logger
+	cfg                     config.ReferralsConfig
+	ReferralRepository      storage.ReferralRepositoryI
+	ReferralClickRepository storage.ReferralClickRepositoryI
+	UserRepository          storage.UserRepositoryI
+	StatusService           status.StatusServiceI
+	EmailService            email.EmailServiceI
 }
 
 type ReferralServiceI interface {
 	ReferralsUser(ctx context.Context, referral_code string) ([]dto.ReferralDTO, error)
+	Referrals(ctx context.Context, filterDTO dto.ReferralFilterDTO) (dto.ReferralNetworkDTO, error)
 	UpdateActiveReferrals(ctx context.Context) error
+	RecordClick(ctx context.Context, clickDTO dto.ReferralClickDTO) (models.ReferralClick, error)
+	Funnel(ctx context.Context, filterDTO dto.ReferralFunnelFilterDTO) ([]dto.ReferralFunnelDTO, error)
 }
 
-func New(log *logrus.Logger, referralRepository storage.ReferralRepositoryI) *ReferralService {
+// Глубина дерева рефералов по умолчанию и максимальная
+const (
+	defaultReferralDepth = 1
+	MaxReferralDepth     = 5
+)
+
+var (
+	ErrReferralForbidden = errors.New("referral network of another user is available only to managers")
+)
+
+func New(
+	log *logrus.Logger,
+	cfg config.ReferralsConfig,
+	referralRepository storage.ReferralRepositoryI,
+	referralClickRepository storage.ReferralClickRepositoryI,
+	userRepository storage.UserRepositoryI,
+	statusService status.StatusServiceI,
+	emailService email.EmailServiceI,
+) *ReferralService {
 	return &ReferralService{
-		log:                log,
-		ReferralRepository: referralRepository,
+		log:                     log,
+		cfg:                     cfg,
+		ReferralRepository:      referralRepository,
+		ReferralClickRepository: referralClickRepository,
+		UserRepository:          userRepository,
+		StatusService:           statusService,
+		EmailService:            emailService,
 	}
 }
 
@@ -47,6 +86,7 @@ func (r *ReferralService) ReferralsUser(ctx context.Context, referral_code strin
 			PhoneNumber: ref.User.PhoneNumber,
 			City:        ref.User.City,
 			Active:      ref.Referral.Active,
+			Commission:  ref.Commission,
 		}
 		referralsDTO = append(referralsDTO, referralDTO)
 	}
@@ -54,21 +94,217 @@ func (r *ReferralService) ReferralsUser(ctx context.Context, referral_code strin
 	return referralsDTO, nil
 }
 
+// Referrals возвращает сеть агента: итоги, страницу прямых рефералов с прогрессом активации
+// и, при Depth > 1, их рефералов до указанной глубины. Чужую сеть видит только менеджер.
+func (r *ReferralService) Referrals(ctx context.Context, filterDTO dto.ReferralFilterDTO) (dto.ReferralNetworkDTO, error) {
+	const op = "ReferralService.Referrals"
+
+	currentID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return dto.ReferralNetworkDTO{}, fmt.Errorf("%s: error receiving userID", op)
+	}
+
+	rootID := currentID
+	if filterDTO.UserID != nil && *filterDTO.UserID != currentID {
+		roles, _ := ctx.Value(context_keys.UserRoleKey).([]string)
+		if !utils.Contains(roles, "manager") {
+			return dto.ReferralNetworkDTO{}, ErrReferralForbidden
+		}
+		rootID = *filterDTO.UserID
+	}
+
+	depth := filterDTO.Depth
+	if depth <= 0 {
+		depth = defaultReferralDepth
+	}
+	depth = min(depth, MaxReferralDepth)
+
+	rule, err := r.rule(ctx)
+	if err != nil {
+		return dto.ReferralNetworkDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	summary, err := r.ReferralRepository.ReferralSummary(ctx, rootID)
+	if err != nil {
+		return dto.ReferralNetworkDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	network := dto.ReferralNetworkDTO{
+		UserID:  rootID,
+		Total:   summary.Total,
+		Active:  summary.Active,
+		Pending: summary.Pending,
+		Expired: summary.Expired,
+		Earned:  summary.Earned,
+		Rule: dto.ReferralRuleDTO{
+			MinLeads:  rule.MinLeads,
+			MinReward: rule.MinReward,
+			Statuses:  r.cfg.Statuses,
+		},
+		Referrals: []*dto.ReferralNodeDTO{},
+	}
+
+	filter := models.ReferralNodeFilter{
+		InviterIDs: []int64{rootID},
+		RootID:     rootID,
+		Rule:       rule,
+		Limit:      filterDTO.Limit,
+		Offset:     filterDTO.Offset,
+	}
+
+	// Дерево строится по уровням; visited защищает от замкнутых цепочек приглашений
+	visited := map[int64]bool{rootID: true}
+	parents := map[int64]*dto.ReferralNodeDTO{}
+
+	for level := 1; level <= depth && len(filter.InviterIDs) > 0; level++ {
+		nodes, err := r.ReferralRepository.ReferralNodes(ctx, filter)
+		if err != nil {
+			return dto.ReferralNetworkDTO{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		next := map[int64]*dto.ReferralNodeDTO{}
+		filter = models.ReferralNodeFilter{RootID: rootID, Rule: rule}
+
+		for _, node := range nodes {
+			if visited[node.User.ID] {
+				continue
+			}
+			visited[node.User.ID] = true
+
+			nodeDTO := referralNodeDTO(node, rule, level)
+			if parent, ok := parents[node.InviterID]; ok {
+				parent.Referrals = append(parent.Referrals, nodeDTO)
+			} else {
+				network.Referrals = append(network.Referrals, nodeDTO)
+			}
+
+			next[node.User.ID] = nodeDTO
+			filter.InviterIDs = append(filter.InviterIDs, node.User.ID)
+		}
+
+		parents = next
+	}
+
+	return network, nil
+}
+
+func referralNodeDTO(node models.ReferralNode, rule models.ReferralRule, level int) *dto.ReferralNodeDTO {
+	nodeDTO := &dto.ReferralNodeDTO{
+		ID:          node.Referral.ID,
+		UserID:      node.User.ID,
+		Name:        node.User.Name,
+		PhoneNumber: node.User.PhoneNumber,
+		City:        node.User.City,
+		Level:       level,
+		Status:      node.Referral.Status(),
+		CreatedAt:   node.Referral.CreatedAt,
+		ActivatedAt: node.Referral.ActivatedAt,
+		ExpiredAt:   node.Referral.ExpiredAt,
+		Leads:       node.Leads,
+		Earned:      node.Earned,
+	}
+
+	if nodeDTO.Status == models.ReferralStatusPending {
+		nodeDTO.Progress = &dto.ReferralProgressDTO{
+			Leads:     node.Progress.Leads,
+			MinLeads:  rule.MinLeads,
+			Reward:    node.Progress.Reward,
+			MinReward: rule.MinReward,
+		}
+		if rule.Window > 0 {
+			deadline := node.Referral.CreatedAt.Add(rule.Window)
+			nodeDTO.Progress.Deadline = &deadline
+		}
+	}
+
+	return nodeDTO
+}
+
+// UpdateActiveReferrals проверяет неактивных рефералов по условиям из конфига: выполнившие их
+// активируются с начислением пригласившему и письмом ему, не успевшие в срок — истекают
 func (r *ReferralService) UpdateActiveReferrals(ctx context.Context) error {
 	op := "ReferralService.UpdateActive"
 
-	referrals, err := r.ReferralRepository.GetInactiveReferralsWithReadyLeads(ctx)
+	rule, err := r.rule(ctx)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	for _, referral := range referrals {
-		err = r.ReferralRepository.UpdateActive(ctx, referral.ID, true)
-		if err != nil {
-			r.log.Error(err)
-			return fmt.Errorf("%s: %w", op, err)
+	referrals, err := r.ReferralRepository.PendingReferrals(ctx, rule)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	activated, expired := 0, 0
+
+	for _, progress := range referrals {
+		switch {
+		case rule.Qualifies(progress):
+			ok, err := r.ReferralRepository.ActivateReferral(ctx, progress.Referral.ID)
+			if err != nil {
+				r.log.Error(err)
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			if ok {
+				activated++
+				r.notifyActivated(ctx, progress.Referral)
+			}
+		case rule.Expired(progress, now):
+			if err := r.ReferralRepository.ExpireReferral(ctx, progress.Referral.ID); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			expired++
 		}
 	}
 
+	if activated > 0 || expired > 0 {
+		r.log.Infof("%s: %d referrals activated, %d expired", op, activated, expired)
+	}
+
 	return nil
 }
+
+// rule собирает условия активации из конфига, переводя имена статусов в ID
+func (r *ReferralService) rule(ctx context.Context) (models.ReferralRule, error) {
+	rule := models.ReferralRule{
+		MinLeads:  r.cfg.MinLeads,
+		Window:    r.cfg.Window,
+		MinReward: money.FromRubles(r.cfg.MinReward),
+	}
+
+	for _, name := range r.cfg.Statuses {
+		st, err := r.StatusService.StatusByName(ctx, name)
+		if err != nil {
+			return models.ReferralRule{}, fmt.Errorf("referral status %q: %w", name, err)
+		}
+		rule.StatusIDs = append(rule.StatusIDs, st.ID)
+	}
+
+	return rule, nil
+}
+
+// notifyActivated пишет пригласившему об активации реферала. Ошибка отправки не отменяет активацию.
+func (r *ReferralService) notifyActivated(ctx context.Context, referral models.Referral) {
+	const op = "ReferralService.notifyActivated"
+
+	inviter, err := r.UserRepository.UserByReferralCode(ctx, referral.ReferralCode)
+	if err != nil {
+		r.log.Warnf("%s: referral %d: %v", op, referral.ID, err)
+		return
+	}
+
+	name := fmt.Sprintf("№%d", referral.UserID)
+	if user, err := r.UserRepository.UserById(ctx, referral.UserID); err == nil {
+		name = user.Name
+	}
+
+	body := fmt.Sprintf("<p>Приглашённый вами агент %s выполнил условия программы.</p>", name)
+	if referral.Cost != 0 {
+		body += fmt.Sprintf("<p>Вам начислено вознаграждение %s ₽.</p>", referral.Cost)
+	}
+
+	if err := r.EmailService.SendEmail(ctx, inviter.Email, "Реферал активирован", body); err != nil {
+		r.log.Warnf("%s: referral %d: %v", op, referral.ID, err)
+	}
+}
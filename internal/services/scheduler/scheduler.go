@@ -2,6 +2,9 @@ package scheduler
 
 import (
 	"context"
+	"ia-online-golang/internal/config"
+	"ia-online-golang/internal/services/payout"
+	"ia-online-golang/internal/services/reconciliation"
 	"ia-online-golang/internal/services/referral"
 
 	"github.com/robfig/cron/v3"
@@ -9,9 +12,12 @@ import (
 )
 
 type SchedulerService struct {
-	log             *logrus.Logger
-	ReferralService referral.ReferralServiceI
-	cron            *cron.Cron
+	log                   *logrus.Logger
+	cfg                   config.SchedulerConfig
+	ReferralService       referral.ReferralServiceI
+	ReconciliationService reconciliation.ReconciliationServiceI
+	PayoutService         payout.PayoutServiceI
+	cron                  *cron.Cron
 }
 
 type SchedulerServiceI interface {
@@ -19,19 +25,27 @@ type SchedulerServiceI interface {
 	Stop()
 }
 
-func New(log *logrus.Logger, referralService referral.ReferralServiceI) *SchedulerService {
+func New(
+	log *logrus.Logger,
+	cfg config.SchedulerConfig,
+	referralService referral.ReferralServiceI,
+	reconciliationService reconciliation.ReconciliationServiceI,
+	payoutService payout.PayoutServiceI,
+) *SchedulerService {
 	return &SchedulerService{
-		log:             log,
-		ReferralService: referralService,
-		cron:            cron.New(),
+		log:                   log,
+		cfg:                   cfg,
+		ReferralService:       referralService,
+		ReconciliationService: reconciliationService,
+		PayoutService:         payoutService,
+		cron:                  cron.New(),
 	}
 }
 
 func (s *SchedulerService) Run() {
 	op := "SchedulerService.Run"
 
-	// Пример: запуск каждый день в 3:00 ночи
-	_, err := s.cron.AddFunc("*/10 * * * * *", func() {
+	_, err := s.cron.AddFunc(s.cfg.Referrals, func() {
 		ctx := context.Background()
 		err := s.ReferralService.UpdateActiveReferrals(ctx)
 		if err != nil {
@@ -45,6 +59,32 @@ func (s *SchedulerService) Run() {
 		s.log.Fatalf("%s:%v", op, err)
 	}
 
+	// Сверка лидов со сделками Bitrix
+	_, err = s.cron.AddFunc(s.cfg.Reconciliation, func() {
+		ctx := context.Background()
+		_, err := s.ReconciliationService.Reconcile(ctx)
+		if err != nil {
+			s.log.Errorf("%s:%v", op, err)
+		}
+	})
+
+	if err != nil {
+		s.log.Fatalf("%s:%v", op, err)
+	}
+
+	// Повтор закрытия лидов по оплаченным выплатам
+	_, err = s.cron.AddFunc(s.cfg.PayoutLeads, func() {
+		ctx := context.Background()
+		err := s.PayoutService.PayPendingLeads(ctx)
+		if err != nil {
+			s.log.Errorf("%s:%v", op, err)
+		}
+	})
+
+	if err != nil {
+		s.log.Fatalf("%s:%v", op, err)
+	}
+
 	s.cron.Start()
 	s.log.Info("⏱️ Планировщик запущен")
 }
@@ -0,0 +1,162 @@
+package statistic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/storage"
+	"ia-online-golang/internal/utils"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StatisticService строит статистику начислений и лидов за произвольный период
+// в виде временного ряда. Агрегация выполняется в базе.
+type StatisticService struct {
+	log                 *logrus.Logger
+	StatisticRepository storage.StatisticRepositoryI
+}
+
+type StatisticServiceI interface {
+	Series(ctx context.Context, filterDTO dto.StatisticFilterDTO) (dto.StatisticSeriesDTO, error)
+}
+
+// Наибольшее число шагов в ряду: год по дням
+const maxStatisticPoints = 366
+
+var (
+	ErrStatisticForbidden = errors.New("statistics of another user are available only to managers")
+	ErrStatisticPeriod    = errors.New("to must be later than from")
+	ErrTooManyPoints      = fmt.Errorf("period is too long for the interval: at most %d points", maxStatisticPoints)
+)
+
+func New(log *logrus.Logger, statisticRepository storage.StatisticRepositoryI) *StatisticService {
+	return &StatisticService{
+		log:                 log,
+		StatisticRepository: statisticRepository,
+	}
+}
+
+// Series возвращает итоги и ряд за период. По умолчанию — с начала месяца по текущий момент
+// с шагом в день. Агент видит только себя, менеджер — любого агента или, без UserID, всех.
+func (s *StatisticService) Series(ctx context.Context, filterDTO dto.StatisticFilterDTO) (dto.StatisticSeriesDTO, error) {
+	const op = "StatisticService.Series"
+
+	currentID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return dto.StatisticSeriesDTO{}, fmt.Errorf("%s: error receiving userID", op)
+	}
+
+	roles, _ := ctx.Value(context_keys.UserRoleKey).([]string)
+
+	filter := models.StatisticFilter{
+		UserID:   filterDTO.UserID,
+		Interval: filterDTO.Interval,
+	}
+
+	if !utils.Contains(roles, "manager") {
+		if filterDTO.UserID != nil && *filterDTO.UserID != currentID {
+			return dto.StatisticSeriesDTO{}, ErrStatisticForbidden
+		}
+		filter.UserID = &currentID
+	}
+
+	if filter.Interval == "" {
+		filter.Interval = models.StatisticIntervalDay
+	}
+
+	now := time.Now()
+	filter.To = now
+	if filterDTO.To != nil {
+		filter.To = *filterDTO.To
+	}
+	filter.From = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if filterDTO.From != nil {
+		filter.From = *filterDTO.From
+	}
+
+	if !filter.To.After(filter.From) {
+		return dto.StatisticSeriesDTO{}, ErrStatisticPeriod
+	}
+
+	// Длину ряда проверяем до запросов, чтобы не строить в базе ряд за десятилетия
+	if statisticPoints(filter) > maxStatisticPoints {
+		return dto.StatisticSeriesDTO{}, ErrTooManyPoints
+	}
+
+	buckets, err := s.StatisticRepository.StatisticBuckets(ctx, filter)
+	if err != nil {
+		return dto.StatisticSeriesDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	earnings, err := s.StatisticRepository.EarningsSeries(ctx, filter)
+	if err != nil {
+		return dto.StatisticSeriesDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	leads, err := s.StatisticRepository.LeadStatusSeries(ctx, filter)
+	if err != nil {
+		return dto.StatisticSeriesDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	series := dto.StatisticSeriesDTO{
+		UserID:   filter.UserID,
+		From:     filter.From,
+		To:       filter.To,
+		Interval: filter.Interval,
+		Leads:    map[string]int64{},
+		Points:   make([]dto.StatisticPointDTO, len(buckets)),
+	}
+
+	// Шаги сопоставляются по моменту начала: база отдаёт их в одной и той же зоне
+	index := make(map[int64]int, len(buckets))
+	for i, bucket := range buckets {
+		index[bucket.Unix()] = i
+		series.Points[i] = dto.StatisticPointDTO{Start: bucket, Leads: map[string]int64{}}
+	}
+
+	for _, point := range earnings {
+		series.Earnings.Add(point.Service, point.Amount)
+		if i, ok := index[point.Bucket.Unix()]; ok {
+			series.Points[i].Earnings.Add(point.Service, point.Amount)
+		}
+	}
+
+	for _, point := range leads {
+		series.Leads[point.Status] += point.Count
+		if i, ok := index[point.Bucket.Unix()]; ok {
+			series.Points[i].Leads[point.Status] += point.Count
+		}
+	}
+
+	return series, nil
+}
+
+// statisticPoints считает шаги периода так же, как date_trunc и generate_series в базе:
+// от начала шага, в который попадает From, до To. Счёт обрывается после maxStatisticPoints.
+func statisticPoints(filter models.StatisticFilter) int {
+	from := filter.From
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+
+	next := func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	switch filter.Interval {
+	case models.StatisticIntervalWeek:
+		// Неделя в date_trunc начинается с понедельника
+		start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	case models.StatisticIntervalMonth:
+		start = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	}
+
+	points := 0
+	for t := start; t.Before(filter.To) && points <= maxStatisticPoints; t = next(t) {
+		points++
+	}
+
+	return points
+}
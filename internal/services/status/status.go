@@ -0,0 +1,257 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/storage"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StatusService отдаёт статусы лидов и соответствие стадий Bitrix,
+// держа их в памяти и перечитывая из БД не реже раза в cacheTTL.
+type StatusService struct {
+	log              *logrus.Logger
+	StatusRepository storage.StatusRepositoryI
+	cacheTTL         time.Duration
+
+	mu       sync.RWMutex
+	statuses []models.Status
+	byStage  map[stageKey]int64
+	byID     map[int64]models.Status
+	loadedAt time.Time
+}
+
+type StatusServiceI interface {
+	Statuses(ctx context.Context) ([]models.Status, error)
+	StatusByStage(ctx context.Context, categoryID int64, stageCode string) (int64, error)
+	StatusByID(ctx context.Context, id int64) (models.Status, error)
+	StatusByName(ctx context.Context, name string) (models.Status, error)
+	CreateStatus(ctx context.Context, statusDTO dto.StatusDTO) (models.Status, error)
+	EditStatus(ctx context.Context, statusDTO dto.StatusDTO) error
+}
+
+// stageKey — стадия Bitrix; коды стадий уникальны только внутри воронки
+type stageKey struct {
+	categoryID int64
+	stageCode  string
+}
+
+var (
+	ErrStageNotMapped = errors.New("bitrix stage is not mapped to status")
+	ErrStatusNotFound = errors.New("status not found")
+	ErrStatusExists   = errors.New("status or stage already exists")
+	ErrStatusSystem   = errors.New("system status cannot be renamed")
+)
+
+// systemStatuses — имена статусов, на которые опирается жизненный цикл лида
+// (константы Status* пакета lead). У них менеджер меняет только bitrix_name и стадии.
+var systemStatuses = map[string]bool{
+	"new":                 true,
+	"noContact":           true,
+	"pending":             true,
+	"scheduled":           true,
+	"appointment_control": true,
+	"ready":               true,
+	"paid":                true,
+	"refusal":             true,
+}
+
+func New(log *logrus.Logger, statusRepository storage.StatusRepositoryI, cacheTTL time.Duration) *StatusService {
+	return &StatusService{
+		log:              log,
+		StatusRepository: statusRepository,
+		cacheTTL:         cacheTTL,
+	}
+}
+
+func (s *StatusService) Statuses(ctx context.Context) ([]models.Status, error) {
+	const op = "StatusService.Statuses"
+
+	if err := s.load(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.statuses, nil
+}
+
+// StatusByStage возвращает ID статуса для стадии сделки в воронке categoryID, например 42 и "C42:WON"
+func (s *StatusService) StatusByStage(ctx context.Context, categoryID int64, stageCode string) (int64, error) {
+	const op = "StatusService.StatusByStage"
+
+	if err := s.load(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statusID, ok := s.byStage[stageKey{categoryID: categoryID, stageCode: stageCode}]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w: %d/%s", op, ErrStageNotMapped, categoryID, stageCode)
+	}
+
+	return statusID, nil
+}
+
+func (s *StatusService) StatusByID(ctx context.Context, id int64) (models.Status, error) {
+	const op = "StatusService.StatusByID"
+
+	if err := s.load(ctx); err != nil {
+		return models.Status{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status, ok := s.byID[id]
+	if !ok {
+		return models.Status{}, ErrStatusNotFound
+	}
+
+	return status, nil
+}
+
+// StatusByName ищет статус по системному имени, например "refusal"
+func (s *StatusService) StatusByName(ctx context.Context, name string) (models.Status, error) {
+	const op = "StatusService.StatusByName"
+
+	if err := s.load(ctx); err != nil {
+		return models.Status{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, status := range s.statuses {
+		if status.Name == name {
+			return status, nil
+		}
+	}
+
+	return models.Status{}, ErrStatusNotFound
+}
+
+func (s *StatusService) CreateStatus(ctx context.Context, statusDTO dto.StatusDTO) (models.Status, error) {
+	const op = "StatusService.CreateStatus"
+
+	status := dtoToStatus(statusDTO)
+
+	err := s.StatusRepository.CreateStatus(ctx, &status)
+	if err != nil {
+		if errors.Is(err, storage.ErrStatusExists) {
+			return models.Status{}, ErrStatusExists
+		}
+		return models.Status{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.invalidate()
+
+	return status, nil
+}
+
+func (s *StatusService) EditStatus(ctx context.Context, statusDTO dto.StatusDTO) error {
+	const op = "StatusService.EditStatus"
+
+	if statusDTO.ID == nil {
+		return ErrStatusNotFound
+	}
+
+	current, err := s.StatusByID(ctx, *statusDTO.ID)
+	if err != nil {
+		if errors.Is(err, ErrStatusNotFound) {
+			return ErrStatusNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if systemStatuses[current.Name] && statusDTO.Name != current.Name {
+		return ErrStatusSystem
+	}
+
+	status := dtoToStatus(statusDTO)
+
+	err = s.StatusRepository.UpdateStatus(ctx, status)
+	if err != nil {
+		if errors.Is(err, storage.ErrStatusNotFound) {
+			return ErrStatusNotFound
+		}
+		if errors.Is(err, storage.ErrStatusExists) {
+			return ErrStatusExists
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.invalidate()
+
+	return nil
+}
+
+// load перечитывает статусы, если кеш пуст или устарел
+func (s *StatusService) load(ctx context.Context) error {
+	s.mu.RLock()
+	fresh := s.byStage != nil && time.Since(s.loadedAt) < s.cacheTTL
+	s.mu.RUnlock()
+
+	if fresh {
+		return nil
+	}
+
+	statuses, err := s.StatusRepository.Statuses(ctx)
+	if err != nil {
+		return err
+	}
+
+	byStage := make(map[stageKey]int64)
+	byID := make(map[int64]models.Status)
+	for _, status := range statuses {
+		byID[status.ID] = status
+		for _, stage := range status.Stages {
+			byStage[stageKey{categoryID: stage.CategoryID, stageCode: stage.StageCode}] = status.ID
+		}
+	}
+
+	s.mu.Lock()
+	s.statuses = statuses
+	s.byStage = byStage
+	s.byID = byID
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *StatusService) invalidate() {
+	s.mu.Lock()
+	s.byStage = nil
+	s.mu.Unlock()
+}
+
+func dtoToStatus(statusDTO dto.StatusDTO) models.Status {
+	status := models.Status{
+		Name:       statusDTO.Name,
+		BitrixName: statusDTO.BitrixName,
+	}
+
+	if statusDTO.ID != nil {
+		status.ID = *statusDTO.ID
+	}
+
+	for _, stage := range statusDTO.Stages {
+		status.Stages = append(status.Stages, models.StatusStage{
+			StatusID:   status.ID,
+			CategoryID: stage.CategoryID,
+			StageCode:  stage.StageCode,
+		})
+	}
+
+	return status
+}
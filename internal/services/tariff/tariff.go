@@ -0,0 +1,148 @@
+package tariff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/dto"
+	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/lib/money"
+	"ia-online-golang/internal/models"
+	"ia-online-golang/internal/storage"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TariffService ведёт тарифы вознаграждений: менеджер задаёт сумму за услугу
+// в городе на период, а лиды и рефералы запоминают тариф на момент создания
+type TariffService struct {
+	log              *logrus.Logger
+	TariffRepository storage.TariffRepositoryI
+}
+
+type TariffServiceI interface {
+	Tariffs(ctx context.Context, filterDTO dto.TariffFilterDTO) ([]models.Tariff, error)
+	CreateTariff(ctx context.Context, tariffDTO dto.TariffDTO) (models.Tariff, error)
+	EditTariff(ctx context.Context, tariffDTO dto.TariffDTO) (models.Tariff, error)
+	Amount(ctx context.Context, service string, city *string, at time.Time) (*money.Amount, error)
+}
+
+var (
+	ErrTariffNotFound = errors.New("tariff not found")
+	ErrTariffOverlap  = errors.New("tariff period overlaps another tariff")
+	ErrTariffPeriod   = errors.New("valid_to must be later than valid_from")
+)
+
+func New(log *logrus.Logger, tariffRepository storage.TariffRepositoryI) *TariffService {
+	return &TariffService{
+		log:              log,
+		TariffRepository: tariffRepository,
+	}
+}
+
+func (t *TariffService) Tariffs(ctx context.Context, filterDTO dto.TariffFilterDTO) ([]models.Tariff, error) {
+	const op = "TariffService.Tariffs"
+
+	tariffs, err := t.TariffRepository.Tariffs(ctx, models.TariffFilter{
+		Service: filterDTO.Service,
+		City:    filterDTO.City,
+		At:      filterDTO.At,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tariffs, nil
+}
+
+// CreateTariff заводит тариф от имени текущего менеджера
+func (t *TariffService) CreateTariff(ctx context.Context, tariffDTO dto.TariffDTO) (models.Tariff, error) {
+	const op = "TariffService.CreateTariff"
+
+	tariff, err := tariffFromDTO(tariffDTO)
+	if err != nil {
+		return models.Tariff{}, err
+	}
+
+	if managerID, ok := ctx.Value(context_keys.UserIDKey).(int64); ok {
+		tariff.CreatedBy = &managerID
+	}
+
+	if err := t.TariffRepository.CreateTariff(ctx, &tariff); err != nil {
+		if errors.Is(err, storage.ErrTariffOverlap) {
+			return models.Tariff{}, ErrTariffOverlap
+		}
+		return models.Tariff{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	t.log.Infof("%s: tariff %d created: %s %s from %s", op, tariff.ID, tariff.Service, tariff.Amount, tariff.ValidFrom.Format(time.RFC3339))
+
+	return tariff, nil
+}
+
+// EditTariff полностью заменяет тариф. Уже созданные лиды сохраняют прежнюю сумму.
+func (t *TariffService) EditTariff(ctx context.Context, tariffDTO dto.TariffDTO) (models.Tariff, error) {
+	const op = "TariffService.EditTariff"
+
+	if tariffDTO.ID == nil {
+		return models.Tariff{}, ErrTariffNotFound
+	}
+
+	tariff, err := tariffFromDTO(tariffDTO)
+	if err != nil {
+		return models.Tariff{}, err
+	}
+	tariff.ID = *tariffDTO.ID
+
+	if err := t.TariffRepository.UpdateTariff(ctx, &tariff); err != nil {
+		if errors.Is(err, storage.ErrTariffNotFound) {
+			return models.Tariff{}, ErrTariffNotFound
+		}
+		if errors.Is(err, storage.ErrTariffOverlap) {
+			return models.Tariff{}, ErrTariffOverlap
+		}
+		return models.Tariff{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	t.log.Infof("%s: tariff %d updated: %s %s", op, tariff.ID, tariff.Service, tariff.Amount)
+
+	return tariff, nil
+}
+
+// Amount возвращает сумму тарифа услуги для города в момент at или nil, если тариф не задан
+func (t *TariffService) Amount(ctx context.Context, service string, city *string, at time.Time) (*money.Amount, error) {
+	const op = "TariffService.Amount"
+
+	tariff, err := t.TariffRepository.ActiveTariff(ctx, service, city, at)
+	if err != nil {
+		if errors.Is(err, storage.ErrTariffNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &tariff.Amount, nil
+}
+
+func tariffFromDTO(tariffDTO dto.TariffDTO) (models.Tariff, error) {
+	if tariffDTO.ValidTo != nil && !tariffDTO.ValidTo.After(tariffDTO.ValidFrom) {
+		return models.Tariff{}, ErrTariffPeriod
+	}
+
+	tariff := models.Tariff{
+		Service:   tariffDTO.Service,
+		Amount:    tariffDTO.Amount,
+		ValidFrom: tariffDTO.ValidFrom,
+		ValidTo:   tariffDTO.ValidTo,
+	}
+
+	if tariffDTO.City != nil {
+		if city := strings.TrimSpace(*tariffDTO.City); city != "" {
+			tariff.City = &city
+		}
+	}
+
+	return tariff, nil
+}
@@ -1,18 +1,10 @@
 package token
 
-import "ia-online-golang/internal/dto"
-
+// PayloadUserAccess — только личность и роли. Профиль, статистика и рефералы
+// отдаются через /api/v1/me, /api/v1/me/statistics и /api/v1/referrals.
 type PayloadUserAccess struct {
-	UserID       int64             `json:"user_id"`
-	Roles        []string          `json:"roles"`
-	Name         string            `json:"name"`
-	Email        string            `json:"email"`
-	PhoneNumber  string            `json:"phone_number"`
-	City         string            `json:"city"`
-	Telegram     string            `json:"telegram"`
-	ReferralCode string            `json:"referral_code"`
-	Referrals    []dto.ReferralDTO `json:"referrals"`
-	Statistic    dto.UserStatistic `json:"statistic"`
+	UserID int64    `json:"user_id"`
+	Roles  []string `json:"roles"`
 }
 type PayloadUserRefresh struct {
 	UserID int64 `json:"user_id"`
@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"ia-online-golang/internal/dto"
-	"ia-online-golang/internal/services/lead"
-	"ia-online-golang/internal/services/referral"
 	"ia-online-golang/internal/services/user"
 	"ia-online-golang/internal/storage"
 	"time"
@@ -24,8 +22,6 @@ type TokenService struct {
 	ExpirationTimeRefresh int64
 	TokenRepository       storage.TokenRepositoryI
 	UserService           user.UserServiceI
-	LeadService           lead.LeadServiceI
-	ReferralService       referral.ReferralServiceI
 }
 
 type TokenServiceI interface {
@@ -55,9 +51,7 @@ func New(log *logrus.Logger,
 	expiryTimeAccess int64,
 	expiryTimeRefresh int64,
 	tokenRepository storage.TokenRepositoryI,
-	userService user.UserServiceI,
-	leadService lead.LeadServiceI,
-	referralService referral.ReferralServiceI) *TokenService {
+	userService user.UserServiceI) *TokenService {
 	return &TokenService{
 		log:                   log,
 		SecretKeyAccess:       secretKeyAccess,
@@ -66,8 +60,6 @@ func New(log *logrus.Logger,
 		ExpirationTimeRefresh: expiryTimeRefresh,
 		TokenRepository:       tokenRepository,
 		UserService:           userService,
-		LeadService:           leadService,
-		ReferralService:       referralService,
 	}
 }
 
@@ -80,30 +72,9 @@ func (s *TokenService) CreateUserTokens(ctx context.Context, userID int64) (dto.
 		return dto.AuthTokensDTO{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	now := time.Now()
-	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-
-	statistic, err := s.LeadService.GetUserPaymentStatistic(ctx, userID, &firstOfMonth, nil)
-	if err != nil {
-		return dto.AuthTokensDTO{}, fmt.Errorf("%s: %w", op, err)
-	}
-
-	referrals, err := s.ReferralService.ReferralsUser(ctx, user.ReferralCode)
-	if err != nil {
-		return dto.AuthTokensDTO{}, fmt.Errorf("%s: %w", op, err)
-	}
-
 	payloadAccess := PayloadUserAccess{
-		UserID:       *user.ID,
-		Roles:        user.Roles,
-		Name:         user.Name,
-		Email:        user.Email,
-		PhoneNumber:  user.PhoneNumber,
-		City:         user.City,
-		Telegram:     user.Telegram,
-		ReferralCode: user.ReferralCode,
-		Statistic:    statistic,
-		Referrals:    referrals,
+		UserID: *user.ID,
+		Roles:  user.Roles,
 	}
 	payloadRefresh := PayloadUserRefresh{
 		UserID: *user.ID,
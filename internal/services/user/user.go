@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"ia-online-golang/internal/dto"
 	"ia-online-golang/internal/http/context_keys"
+	"ia-online-golang/internal/lib/phone"
+	"ia-online-golang/internal/lib/refcode"
 	"ia-online-golang/internal/models"
 	"ia-online-golang/internal/storage"
 	"ia-online-golang/internal/utils"
@@ -25,12 +27,17 @@ type UserServiceI interface {
 	SaveUser(ctx context.Context, userRegisterDTO dto.RegisterUserDTO, passHash string) (dto.UserDTO, error)
 	Users(ctx context.Context) ([]dto.UserDTO, error)
 	EditUser(ctx context.Context, userDTO dto.UserDTO) error
+	SetReferralCode(ctx context.Context, code string) (dto.UserDTO, error)
 }
 
 var (
 	ErrUserAlreadyExists = errors.New("user already exists")
 	ErrUserNotActivated  = errors.New("user not activated")
 	ErrUserNotFound      = errors.New("user not found")
+
+	ErrReferralCodeInvalid   = refcode.ErrInvalidCode
+	ErrReferralCodeForbidden = refcode.ErrForbiddenCode
+	ErrReferralCodeTaken     = errors.New("referral code is already taken")
 )
 
 func New(
@@ -78,7 +85,13 @@ func (u *UserService) UserByEmail(ctx context.Context, email string) (dto.UserDT
 func (u *UserService) SaveUser(ctx context.Context, userRegisterDTO dto.RegisterUserDTO, passHash string) (dto.UserDTO, error) {
 	op := "UserService.SaveUser"
 
-	err := u.UserRepository.ValidationUser(ctx, userRegisterDTO.Email, userRegisterDTO.PhoneNumber, userRegisterDTO.Telegram)
+	phoneNumber, err := phone.Normalize(userRegisterDTO.PhoneNumber)
+	if err != nil {
+		return dto.UserDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+	userRegisterDTO.PhoneNumber = phoneNumber
+
+	err = u.UserRepository.ValidationUser(ctx, userRegisterDTO.Email, userRegisterDTO.PhoneNumber, userRegisterDTO.Telegram)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
 			return dto.UserDTO{}, fmt.Errorf("%s: %w", op, ErrUserAlreadyExists)
@@ -131,6 +144,14 @@ func (u *UserService) Users(ctx context.Context) ([]dto.UserDTO, error) {
 func (u *UserService) EditUser(ctx context.Context, userDTO dto.UserDTO) error {
 	op := "UserService.EditUser"
 
+	if userDTO.PhoneNumber != "" {
+		phoneNumber, err := phone.Normalize(userDTO.PhoneNumber)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		userDTO.PhoneNumber = phoneNumber
+	}
+
 	user := utils.DtoToUser(userDTO)
 
 	if userDTO.ID == nil {
@@ -154,3 +175,36 @@ func (u *UserService) EditUser(ctx context.Context, userDTO dto.UserDTO) error {
 
 	return nil
 }
+
+// SetReferralCode задаёт текущему агенту выбранный им код. UUID-код продолжает работать,
+// а прежний выбранный код освобождается.
+func (u *UserService) SetReferralCode(ctx context.Context, code string) (dto.UserDTO, error) {
+	const op = "UserService.SetReferralCode"
+
+	userID, ok := ctx.Value(context_keys.UserIDKey).(int64)
+	if !ok {
+		return dto.UserDTO{}, fmt.Errorf("%s: error receiving userID", op)
+	}
+
+	code, err := refcode.Normalize(code)
+	if err != nil {
+		return dto.UserDTO{}, ErrReferralCodeInvalid
+	}
+	if err := refcode.Allowed(code); err != nil {
+		return dto.UserDTO{}, ErrReferralCodeForbidden
+	}
+
+	if err := u.UserRepository.UpdateVanityCode(ctx, userID, code); err != nil {
+		if errors.Is(err, storage.ErrVanityCodeTaken) {
+			return dto.UserDTO{}, ErrReferralCodeTaken
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return dto.UserDTO{}, ErrUserNotFound
+		}
+		return dto.UserDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	u.log.Infof("%s: user %d set referral code %q", op, userID, code)
+
+	return u.UserById(ctx, userID)
+}
@@ -2,12 +2,41 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"ia-online-golang/internal/models"
 )
 
 type CommentsRepositoryI interface {
 	SaveComment(ctx context.Context, comment models.Comment) error
+	CommentByID(ctx context.Context, id int64) (models.Comment, error)
+	CommentsByLeadID(ctx context.Context, leadID int64) ([]models.Comment, error)
+	SaveCommentWithOutbox(ctx context.Context, comment *models.Comment, event *models.OutboxEvent) error
+	SaveBitrixComment(ctx context.Context, comment *models.Comment) error
+	SetCommentBitrixID(ctx context.Context, id int64, bitrixCommentID int64) error
+}
+
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrCommentExists   = errors.New("comment already exists")
+)
+
+// commentColumns — поля комментария вместе с именем автора для scanComment
+const commentColumns = "c.id, c.lead_id, c.user_id, u.name, c.source, c.text, c.bitrix_comment_id, c.created_at"
+
+func scanComment(row rowScanner, comment *models.Comment) error {
+	return row.Scan(
+		&comment.ID,
+		&comment.LeadID,
+		&comment.UserID,
+		&comment.AuthorName,
+		&comment.Source,
+		&comment.Text,
+		&comment.BitrixCommentID,
+		&comment.CreatedAt,
+	)
 }
 
 // var (
@@ -48,11 +77,152 @@ type CommentsRepositoryI interface {
 func (s *Storage) SaveComment(ctx context.Context, comment models.Comment) error {
 	const op = "CommentRepository.SaveComment"
 
-	query := `INSERT INTO comments (lead_id, user_id, text) VALUES ($1, $2, $3)`
-	_, err := s.db.ExecContext(ctx, query, comment.LeadID, comment.UserID, comment.Text)
+	query := `INSERT INTO comments (lead_id, user_id, source, text) VALUES ($1, $2, $3, $4)`
+	_, err := s.db.ExecContext(ctx, query, comment.LeadID, comment.UserID, comment.Source, comment.Text)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
+
+func (s *Storage) CommentByID(ctx context.Context, id int64) (models.Comment, error) {
+	const op = "CommentRepository.CommentByID"
+
+	query := "SELECT " + commentColumns + " FROM comments c LEFT JOIN users u ON u.id = c.user_id WHERE c.id = $1"
+
+	var comment models.Comment
+	err := scanComment(s.db.QueryRowContext(ctx, query, id), &comment)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return comment, ErrCommentNotFound
+		}
+		return comment, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return comment, nil
+}
+
+// CommentsByLeadID возвращает комментарии лида в порядке создания
+func (s *Storage) CommentsByLeadID(ctx context.Context, leadID int64) ([]models.Comment, error) {
+	const op = "CommentRepository.CommentsByLeadID"
+
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.lead_id = $1
+		ORDER BY c.created_at, c.id
+	`
+	rows, err := s.db.QueryContext(ctx, query, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		var comment models.Comment
+		if err := scanComment(rows, &comment); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		comments = append(comments, comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return comments, nil
+}
+
+// SaveCommentWithOutbox сохраняет комментарий и событие его отправки в Bitrix в одной транзакции
+func (s *Storage) SaveCommentWithOutbox(ctx context.Context, comment *models.Comment, event *models.OutboxEvent) error {
+	const op = "CommentRepository.SaveCommentWithOutbox"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO comments (lead_id, user_id, source, text) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err = tx.QueryRowContext(ctx, query, comment.LeadID, comment.UserID, comment.Source, comment.Text).Scan(&comment.ID, &comment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	payload, err := json.Marshal(models.AddCommentPayload{CommentID: comment.ID})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.LeadID = comment.LeadID
+	event.Payload = payload
+
+	outboxQuery := `INSERT INTO bitrix_outbox (lead_id, action, payload) VALUES ($1, $2, $3) RETURNING id`
+	err = tx.QueryRowContext(ctx, outboxQuery, event.LeadID, event.Action, event.Payload).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveBitrixComment сохраняет комментарий из таймлайна Bitrix.
+// Повторная доставка того же комментария возвращает ErrCommentExists.
+func (s *Storage) SaveBitrixComment(ctx context.Context, comment *models.Comment) error {
+	const op = "CommentRepository.SaveBitrixComment"
+
+	query := `
+		INSERT INTO comments (lead_id, user_id, source, text, bitrix_comment_id, created_at)
+		VALUES ($1, NULL, $2, $3, $4, COALESCE($5, CURRENT_TIMESTAMP))
+		ON CONFLICT (bitrix_comment_id) DO NOTHING
+		RETURNING id, created_at
+	`
+	err := s.db.QueryRowContext(ctx, query,
+		comment.LeadID, comment.Source, comment.Text, comment.BitrixCommentID, comment.CreatedAt,
+	).Scan(&comment.ID, &comment.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCommentExists
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetCommentBitrixID связывает комментарий с записью таймлайна. Если вебхук успел
+// принести эту же запись как комментарий из Bitrix, такой дубль удаляется.
+func (s *Storage) SetCommentBitrixID(ctx context.Context, id int64, bitrixCommentID int64) error {
+	const op = "CommentRepository.SetCommentBitrixID"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	deleteQuery := "DELETE FROM comments WHERE bitrix_comment_id = $1 AND id <> $2 AND source = $3"
+	_, err = tx.ExecContext(ctx, deleteQuery, bitrixCommentID, id, models.CommentSourceBitrix)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := "UPDATE comments SET bitrix_comment_id = $2 WHERE id = $1"
+	_, err = tx.ExecContext(ctx, query, id, bitrixCommentID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
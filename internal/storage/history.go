@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ia-online-golang/internal/models"
+)
+
+type HistoryRepositoryI interface {
+	SaveHistory(ctx context.Context, history *models.LeadHistory) error
+	HistoryByLeadID(ctx context.Context, leadID int64) ([]models.LeadHistory, error)
+}
+
+func (s *Storage) SaveHistory(ctx context.Context, history *models.LeadHistory) error {
+	const op = "storage.history.SaveHistory"
+
+	changes, err := json.Marshal(history.Changes)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+		INSERT INTO history (lead_id, action, source, user_id, changes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	err = s.db.QueryRowContext(ctx, query, history.LeadID, history.Action, history.Source, history.UserID, changes).
+		Scan(&history.ID, &history.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// HistoryByLeadID возвращает историю лида в хронологическом порядке
+func (s *Storage) HistoryByLeadID(ctx context.Context, leadID int64) ([]models.LeadHistory, error) {
+	const op = "storage.history.HistoryByLeadID"
+
+	query := `
+		SELECT id, lead_id, action, source, user_id, changes, created_at
+		FROM history
+		WHERE lead_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	history := []models.LeadHistory{}
+	for rows.Next() {
+		var h models.LeadHistory
+		var changes []byte
+		if err := rows.Scan(&h.ID, &h.LeadID, &h.Action, &h.Source, &h.UserID, &changes, &h.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := json.Unmarshal(changes, &h.Changes); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		history = append(history, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return history, nil
+}
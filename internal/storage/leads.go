@@ -8,50 +8,87 @@ import (
 	"ia-online-golang/internal/models"
 	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type LeadRepositoryI interface {
 	LeadByID(ctx context.Context, id int64) (*models.Lead, error)
+	LeadByBitrixDealID(ctx context.Context, dealID int64) (*models.Lead, error)
 	CreateLead(ctx context.Context, lead *models.Lead) error
-	Leads(ctx context.Context,
-		statusID *int64,
-		startDate, endDate *time.Time,
-		limit, offset int64,
-		userID *int64,
-		Search *string,
-		IsInternet, IsShipping, IsCleaning *bool) ([]models.Lead, error)
+	CreateLeadWithOutbox(ctx context.Context, lead *models.Lead, comment *models.Comment, event *models.OutboxEvent, dup *models.DuplicateCheck) error
+	SaveLeadDuplicate(ctx context.Context, duplicate *models.LeadDuplicate) error
+	SetLeadSynced(ctx context.Context, id int64, dealID int64) error
+	SetLeadSyncStatus(ctx context.Context, id int64, syncStatus string) error
+	Leads(ctx context.Context, filter models.LeadFilter) ([]models.Lead, error)
+	CountLeads(ctx context.Context, filter models.LeadFilter) (int64, error)
 	UpdateLead(ctx context.Context,
 		id, userID, statusID *int64,
-		reward_internet, reward_cleaning, reward_shipping *float64,
 		fio, phone_number, address *string,
 		internet, cleaning, shipping *bool,
 		created_at, completed_at, payment_at *time.Time) error
+	UpdateLeadRecord(ctx context.Context, lead models.Lead, rates models.CommissionRates) error
+	UpdateLeadWithOutbox(ctx context.Context, lead models.Lead, fromStatusID int64, event *models.OutboxEvent, rates models.CommissionRates, dup *models.DuplicateCheck) error
+	UnpaidCoveredLeads(ctx context.Context, userID int64) ([]models.Lead, error)
+	LeadHasUnsentEvents(ctx context.Context, leadID int64) (bool, error)
+	LeadDealIDs(ctx context.Context) (map[int64]int64, error)
 	DeleteLead(ctx context.Context, id int64) error
 }
 
 var (
 	ErrLeadNotFound  = errors.New("lead not found")
 	ErrLeadsNotFound = errors.New("leads not found")
+	// Статус лида изменился между чтением и записью
+	ErrLeadStatusChanged = errors.New("lead status changed")
+	ErrLeadDuplicate     = errors.New("lead duplicate")
 )
 
+// Колонки лида в порядке, который ожидает scanLead
+const leadColumns = `id, user_id, bitrix_deal_id, sync_status, fio, address, status_id, phone_number, internet, cleaning, shipping,
+	created_at, completed_at, payment_at, reward_internet, reward_cleaning, reward_shipping,
+	address_normalized, fias_id, city, geo_lat, geo_lon, payout_id, tariff_internet, tariff_cleaning, tariff_shipping`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLead(row rowScanner, lead *models.Lead) error {
+	return row.Scan(
+		&lead.ID, &lead.UserID, &lead.BitrixDealID, &lead.SyncStatus, &lead.FIO, &lead.Address, &lead.StatusID, &lead.PhoneNumber,
+		&lead.Internet, &lead.Cleaning, &lead.Shipping, &lead.CreatedAt, &lead.CompletedAt, &lead.PaymentAt,
+		&lead.RewardInternet, &lead.RewardCleaning, &lead.RewardShipping,
+		&lead.AddressNormalized, &lead.FiasID, &lead.City, &lead.GeoLat, &lead.GeoLon, &lead.PayoutID,
+		&lead.TariffInternet, &lead.TariffCleaning, &lead.TariffShipping,
+	)
+}
+
 func (s *Storage) LeadByID(ctx context.Context, id int64) (*models.Lead, error) {
 	const op = "storage.leads.GetLeadByID"
 
-	query := `
-		SELECT id, user_id, fio, address, status_id, phone_number, internet, cleaning, shipping, created_at, completed_at, payment_at
-		FROM leads
-		WHERE id = $1
-	`
+	query := "SELECT " + leadColumns + " FROM leads WHERE id = $1"
 
 	lead := &models.Lead{}
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&lead.ID, &lead.UserID, &lead.FIO, &lead.Address, &lead.StatusID, &lead.PhoneNumber, &lead.Internet,
-		&lead.Cleaning, &lead.Shipping, &lead.CreatedAt, &lead.CompletedAt, &lead.PaymentAt,
-	)
+	err := scanLead(s.db.QueryRowContext(ctx, query, id), lead)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrLeadNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return lead, nil
+}
+
+func (s *Storage) LeadByBitrixDealID(ctx context.Context, dealID int64) (*models.Lead, error) {
+	const op = "storage.leads.LeadByBitrixDealID"
 
+	query := "SELECT " + leadColumns + " FROM leads WHERE bitrix_deal_id = $1"
+
+	lead := &models.Lead{}
+	err := scanLead(s.db.QueryRowContext(ctx, query, dealID), lead)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // Нет записи с таким ID
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrLeadNotFound
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -63,178 +100,393 @@ func (s *Storage) CreateLead(ctx context.Context, lead *models.Lead) error {
 	const op = "storage.leads.CreateLead"
 
 	query := `
-		INSERT INTO leads (id, user_id, fio, address, status_id, phone_number, internet, cleaning, shipping)
+		INSERT INTO leads (user_id, fio, address, status_id, phone_number, internet, cleaning, shipping, sync_status)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id
+		RETURNING id, created_at
 	`
 
 	// Выполнение запроса и возврат нового ID
 	err := s.db.QueryRowContext(ctx, query,
-		lead.ID, lead.UserID, lead.FIO, lead.Address, lead.StatusID, lead.PhoneNumber, lead.Internet,
-		lead.Cleaning, lead.Shipping,
-	).Scan(&lead.ID)
+		lead.UserID, lead.FIO, lead.Address, lead.StatusID, lead.PhoneNumber, lead.Internet,
+		lead.Cleaning, lead.Shipping, lead.SyncStatus,
+	).Scan(&lead.ID, &lead.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CreateLeadWithOutbox сохраняет лид, комментарий и событие outbox в одной транзакции.
+// Если задан dup, сначала ищет ранее поданный лид на тот же телефон или адрес и при
+// находке возвращает ErrLeadDuplicate, заполнив dup.Existing.
+func (s *Storage) CreateLeadWithOutbox(ctx context.Context, lead *models.Lead, comment *models.Comment, event *models.OutboxEvent, dup *models.DuplicateCheck) error {
+	const op = "storage.leads.CreateLeadWithOutbox"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if dup != nil {
+		existing, err := findDuplicateLead(ctx, tx, lead, dup)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if existing != nil {
+			dup.Existing = existing
+			return ErrLeadDuplicate
+		}
+	}
+
+	leadQuery := `
+		INSERT INTO leads (user_id, fio, address, status_id, phone_number, internet, cleaning, shipping, sync_status,
+			address_normalized, fias_id, city, geo_lat, geo_lon, tariff_internet, tariff_cleaning, tariff_shipping)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRowContext(ctx, leadQuery,
+		lead.UserID, lead.FIO, lead.Address, lead.StatusID, lead.PhoneNumber, lead.Internet,
+		lead.Cleaning, lead.Shipping, lead.SyncStatus,
+		lead.AddressNormalized, lead.FiasID, lead.City, lead.GeoLat, lead.GeoLon,
+		lead.TariffInternet, lead.TariffCleaning, lead.TariffShipping,
+	).Scan(&lead.ID, &lead.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if comment != nil {
+		comment.LeadID = lead.ID
 
+		commentQuery := `INSERT INTO comments (lead_id, user_id, source, text) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+		err = tx.QueryRowContext(ctx, commentQuery, comment.LeadID, comment.UserID, comment.Source, comment.Text).Scan(&comment.ID, &comment.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	event.LeadID = lead.ID
+
+	outboxQuery := `INSERT INTO bitrix_outbox (lead_id, action, payload) VALUES ($1, $2, $3) RETURNING id`
+	err = tx.QueryRowContext(ctx, outboxQuery, event.LeadID, event.Action, event.Payload).Scan(&event.ID)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
-func (s *Storage) Leads(ctx context.Context, statusID *int64, startDate, endDate *time.Time, limit, offset int64, userID *int64, Search *string, IsInternet, IsShipping, IsCleaning *bool) ([]models.Lead, error) {
+func (s *Storage) SetLeadSynced(ctx context.Context, id int64, dealID int64) error {
+	const op = "storage.leads.SetLeadSynced"
+
+	query := "UPDATE leads SET bitrix_deal_id = $2, sync_status = $3 WHERE id = $1"
+	result, err := s.db.ExecContext(ctx, query, id, dealID, models.LeadSyncSynced)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrLeadNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) SetLeadSyncStatus(ctx context.Context, id int64, syncStatus string) error {
+	const op = "storage.leads.SetLeadSyncStatus"
+
+	query := "UPDATE leads SET sync_status = $2 WHERE id = $1"
+	result, err := s.db.ExecContext(ctx, query, id, syncStatus)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrLeadNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) Leads(ctx context.Context, filter models.LeadFilter) ([]models.Lead, error) {
 	const op = "storage.leads.GetLeads"
 
+	where, args, rank := leadFilterWhere(filter)
+	argCount := len(args) + 1
+
 	// Стартовый запрос для выборки лидов
-	query := `
-		SELECT id, user_id, fio, address, status_id, phone_number, internet, cleaning, shipping, created_at, completed_at, payment_at, reward_internet, reward_cleaning, reward_shipping
-		FROM leads
-		WHERE 1=1
-	`
+	query := "SELECT " + leadColumns + " FROM leads WHERE 1=1" + where
 
-	// Список аргументов для фильтрации
-	var args []interface{}
+	// Keyset-пагинация: строки строго после курсора в порядке (created_at, id)
+	if filter.Cursor != nil {
+		cmp := "<"
+		if filter.Order == models.SortAsc {
+			cmp = ">"
+		}
+		query += fmt.Sprintf(" AND (created_at, id) %s ($%d, $%d)", cmp, argCount, argCount+1)
+		args = append(args, filter.Cursor.CreatedAt, filter.Cursor.ID)
+		argCount += 2
+	}
+
+	query += " ORDER BY " + leadOrderBy(filter, rank)
+
+	// Добавление пагинации, если указаны значения
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d", argCount)
+		args = append(args, filter.Limit)
+		argCount++
+
+		// offset имеет смысл только если задан limit и не задан курсор
+		if filter.Cursor == nil {
+			query += fmt.Sprintf(" OFFSET $%d", argCount)
+			args = append(args, filter.Offset)
+			argCount++
+		}
+	}
+
+	// Выполнение запроса для получения лидов
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	// Считывание результатов в срез
+	var leads []models.Lead
+	for rows.Next() {
+		lead := models.Lead{}
+		if err := scanLead(rows, &lead); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Добавляем лид в список
+		leads = append(leads, lead)
+	}
+
+	// Проверка на ошибку после чтения строк
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Если лидов не найдено, возвращаем ошибку
+	if len(leads) == 0 {
+		return nil, ErrLeadsNotFound
+	}
+
+	if filter.WithComments {
+		if err := s.attachComments(ctx, leads); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return leads, nil
+}
+
+// CountLeads возвращает число лидов, подходящих под фильтр, без учёта пагинации
+func (s *Storage) CountLeads(ctx context.Context, filter models.LeadFilter) (int64, error) {
+	const op = "storage.leads.CountLeads"
+
+	where, args, _ := leadFilterWhere(filter)
+
+	var total int64
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM leads WHERE 1=1"+where, args...).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return total, nil
+}
+
+// leadFilterWhere собирает условия фильтра в виде " AND ..." и аргументы, нумеруемые с $1.
+// rank — выражение релевантности поиска, пустое без поискового запроса.
+func leadFilterWhere(filter models.LeadFilter) (query string, args []any, rank string) {
 	argCount := 1
 
 	// Фильтрация по статусу
-	if statusID != nil {
+	if filter.StatusID != nil {
 		query += fmt.Sprintf(" AND status_id = $%d", argCount)
-		args = append(args, *statusID)
+		args = append(args, *filter.StatusID)
 		argCount++
 	}
 
 	// Фильтрация по дате создания
-	if startDate != nil {
+	if filter.StartDate != nil {
 		query += fmt.Sprintf(" AND created_at >= $%d", argCount)
-		args = append(args, *startDate)
+		args = append(args, *filter.StartDate)
 		argCount++
 	}
 
 	// Фильтрация по дате завершения
-	if endDate != nil {
+	if filter.EndDate != nil {
 		query += fmt.Sprintf(" AND completed_at <= $%d", argCount)
-		args = append(args, *endDate)
+		args = append(args, *filter.EndDate)
 		argCount++
 	}
 
 	// Фильтрация по пользователю
-	if userID != nil {
+	if filter.UserID != nil {
 		query += fmt.Sprintf(" AND user_id = $%d", argCount)
-		args = append(args, *userID)
+		args = append(args, *filter.UserID)
 		argCount++
 	}
 
 	// Фильтрация по интернету
-	if IsInternet != nil {
+	if filter.IsInternet != nil {
 		query += fmt.Sprintf(" AND internet = $%d", argCount)
-		args = append(args, *IsInternet)
+		args = append(args, *filter.IsInternet)
 		argCount++
 	}
 
 	// Фильтрация по доставке
-	if IsShipping != nil {
+	if filter.IsShipping != nil {
 		query += fmt.Sprintf(" AND shipping = $%d", argCount)
-		args = append(args, *IsShipping)
+		args = append(args, *filter.IsShipping)
 		argCount++
 	}
 
 	// Фильтрация по уборке
-	if IsCleaning != nil {
+	if filter.IsCleaning != nil {
 		query += fmt.Sprintf(" AND cleaning = $%d", argCount)
-		args = append(args, *IsCleaning)
+		args = append(args, *filter.IsCleaning)
 		argCount++
 	}
 
-	// Фильтрация по поисковому запросу
-	if Search != nil && *Search != "" {
-		query += fmt.Sprintf(`
-			AND (
-				LOWER(fio) ILIKE LOWER($%d) OR
-				LOWER(address) ILIKE LOWER($%d) OR
-				LOWER(phone_number) ILIKE LOWER($%d)
+	// Поиск: телефон — по нормализованным цифрам, остальное — полнотекстово и по триграммам
+	if filter.Search != nil && strings.TrimSpace(*filter.Search) != "" {
+		search := strings.TrimSpace(*filter.Search)
+
+		if digits, ok := searchPhoneDigits(search); ok {
+			query += fmt.Sprintf(" AND phone_digits LIKE '%%' || $%d || '%%'", argCount)
+			rank = fmt.Sprintf("(CASE WHEN phone_digits = $%d THEN 1 ELSE 0 END)", argCount)
+			args = append(args, digits)
+		} else {
+			query += fmt.Sprintf(`
+				AND (
+					search_vector @@ websearch_to_tsquery('russian', $%[1]d) OR
+					fio %% $%[1]d OR address %% $%[1]d OR
+					fio ILIKE '%%' || $%[1]d || '%%' OR address ILIKE '%%' || $%[1]d || '%%'
+				)
+			`, argCount)
+			rank = fmt.Sprintf(
+				"(ts_rank(search_vector, websearch_to_tsquery('russian', $%[1]d)) + GREATEST(similarity(fio, $%[1]d), similarity(address, $%[1]d)))",
+				argCount,
 			)
-		`, argCount, argCount, argCount)
-		args = append(args, "%"+*Search+"%")
+			args = append(args, search)
+		}
 		argCount++
 	}
 
-	// Добавление пагинации, если указаны значения
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT $%d", argCount)
-		args = append(args, limit)
-		argCount++
+	return query, args, rank
+}
 
-		// offset имеет смысл только если задан limit
-		query += fmt.Sprintf(" OFFSET $%d", argCount)
-		args = append(args, offset)
-		argCount++
+// searchPhoneDigits распознаёт в поисковой строке телефон или его часть и приводит
+// цифры к виду phone_digits: 8XXXXXXXXXX и XXXXXXXXXX становятся 7XXXXXXXXXX
+func searchPhoneDigits(search string) (string, bool) {
+	var digits strings.Builder
+	for _, r := range search {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case strings.ContainsRune("+-() ", r):
+		default:
+			return "", false
+		}
 	}
 
-	// Выполнение запроса для получения лидов
-	rows, err := s.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	d := digits.String()
+	if len(d) < 4 {
+		return "", false
 	}
-	defer rows.Close()
 
-	// Считывание результатов в срез
-	var leads []models.Lead
-	for rows.Next() {
-		lead := models.Lead{}
-		if err := rows.Scan(
-			&lead.ID, &lead.UserID, &lead.FIO, &lead.Address, &lead.StatusID, &lead.PhoneNumber, &lead.Internet,
-			&lead.Cleaning, &lead.Shipping, &lead.CreatedAt, &lead.CompletedAt, &lead.PaymentAt, &lead.RewardInternet, &lead.RewardCleaning, &lead.RewardShipping,
-		); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
+	switch {
+	case len(d) == 11 && d[0] == '8':
+		d = "7" + d[1:]
+	case len(d) == 10 && d[0] == '9':
+		d = "7" + d
+	}
 
-		// Получение комментариев для каждого лида
-		commentsQuery := `
-			SELECT c.text
-			FROM comments c
-			WHERE c.lead_id = $1
-			ORDER BY created_at
-		`
-		commentsRows, err := s.db.QueryContext(ctx, commentsQuery, lead.ID)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
-		}
-		defer commentsRows.Close()
-
-		var comments []string
-		for commentsRows.Next() {
-			comment := ""
-			if err := commentsRows.Scan(
-				&comment, // Используем указатель на строку
-			); err != nil {
-				return nil, fmt.Errorf("%s: %w", op, err)
-			}
-			comments = append(comments, comment)
-		}
+	return d, true
+}
 
-		// Присваиваем комментарии лиду
-		lead.Comments = comments
+// leadOrderBy возвращает ORDER BY для сортировки фильтра; id в конце делает порядок однозначным
+func leadOrderBy(filter models.LeadFilter, rank string) string {
+	direction := "DESC"
+	if filter.Order == models.SortAsc {
+		direction = "ASC"
+	}
 
-		// Добавляем лид в список
-		leads = append(leads, lead)
+	switch filter.Sort {
+	case models.LeadSortRelevance:
+		if rank != "" {
+			return fmt.Sprintf("%s DESC, created_at DESC, id DESC", rank)
+		}
+		return "created_at DESC, id DESC"
+	case models.LeadSortStatus:
+		return fmt.Sprintf("status_id %[1]s, created_at %[1]s, id %[1]s", direction)
+	case models.LeadSortRewardTotal:
+		return fmt.Sprintf("(reward_internet + reward_cleaning + reward_shipping) %[1]s, created_at %[1]s, id %[1]s", direction)
+	default:
+		return fmt.Sprintf("created_at %[1]s, id %[1]s", direction)
 	}
+}
 
-	// Проверка на ошибку после чтения строк
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+// attachComments загружает тексты комментариев для всех лидов одним запросом
+func (s *Storage) attachComments(ctx context.Context, leads []models.Lead) error {
+	ids := make([]int64, len(leads))
+	index := make(map[int64]int, len(leads))
+	for i, lead := range leads {
+		ids[i] = lead.ID
+		index[lead.ID] = i
+		leads[i].Comments = []string{}
 	}
 
-	// Если лидов не найдено, возвращаем ошибку
-	if len(leads) == 0 {
-		return nil, ErrLeadsNotFound
+	query := `
+		SELECT lead_id, text
+		FROM comments
+		WHERE lead_id = ANY($1)
+		ORDER BY lead_id, created_at, id
+	`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
 	}
+	defer rows.Close()
 
-	return leads, nil
+	for rows.Next() {
+		var leadID int64
+		var text string
+		if err := rows.Scan(&leadID, &text); err != nil {
+			return err
+		}
+		if i, ok := index[leadID]; ok {
+			leads[i].Comments = append(leads[i].Comments, text)
+		}
+	}
+
+	return rows.Err()
 }
 
+// UpdateLead обновляет только переданные поля. Вознаграждения меняются через
+// UpdateLeadRecord, чтобы изменение попало в книгу начислений.
 func (s *Storage) UpdateLead(
 	ctx context.Context,
 	id, userID, statusID *int64,
-	reward_internet, reward_cleaning, reward_shipping *float64,
 	fio, phone_number, address *string,
 	internet, cleaning, shipping *bool,
 	created_at, completed_at, payment_at *time.Time,
@@ -276,15 +528,6 @@ func (s *Storage) UpdateLead(
 	if shipping != nil {
 		addClause("shipping", shipping)
 	}
-	if reward_internet != nil {
-		addClause("reward_internet", reward_internet)
-	}
-	if reward_cleaning != nil {
-		addClause("reward_cleaning", reward_cleaning)
-	}
-	if reward_shipping != nil {
-		addClause("reward_shipping", reward_shipping)
-	}
 	if created_at != nil {
 		addClause("created_at", created_at)
 	}
@@ -312,6 +555,291 @@ func (s *Storage) UpdateLead(
 	return nil
 }
 
+// findDuplicateLead ищет самый ранний лид на тот же телефон или адрес, кроме dup.ExcludeLeadID.
+// Блокировки по телефону и по адресу до конца транзакции не дают двум агентам одновременно
+// подать одного клиента. Берутся всегда в одном порядке — сначала телефон, — чтобы не было взаимоблокировок.
+func findDuplicateLead(ctx context.Context, tx *sql.Tx, lead *models.Lead, dup *models.DuplicateCheck) (*models.Lead, error) {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('leads_phone'), hashtext(regexp_replace($1, '\\D', '', 'g')))", lead.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if address := strings.TrimSpace(lead.Address); address != "" {
+		_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('leads_address'), hashtext(lower(regexp_replace($1, '\\s+', ' ', 'g'))))", address)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		SELECT ` + leadColumns + `
+		FROM leads
+		WHERE created_at >= $1
+			AND NOT (status_id = ANY($2))
+			AND id <> $5
+			AND (
+				phone_digits = regexp_replace($3, '\D', '', 'g') OR
+				($4 <> '' AND lower(regexp_replace(address, '\s+', ' ', 'g')) = lower(regexp_replace($4, '\s+', ' ', 'g')))
+			)
+		ORDER BY created_at, id
+		LIMIT 1
+	`
+
+	existing := &models.Lead{}
+	err = scanLead(tx.QueryRowContext(ctx, query, dup.Since, pq.Array(dup.ExcludeStatusIDs), lead.PhoneNumber, strings.TrimSpace(lead.Address), dup.ExcludeLeadID), existing)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+func (s *Storage) SaveLeadDuplicate(ctx context.Context, duplicate *models.LeadDuplicate) error {
+	const op = "storage.leads.SaveLeadDuplicate"
+
+	query := `
+		INSERT INTO lead_duplicates (lead_id, user_id, phone_number, address)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	err := s.db.QueryRowContext(ctx, query, duplicate.LeadID, duplicate.UserID, duplicate.PhoneNumber, duplicate.Address).
+		Scan(&duplicate.ID, &duplicate.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// updateLeadRecordQuery обновляет все изменяемые поля лида; аргументы — leadRecordArgs
+const updateLeadRecordQuery = `
+	UPDATE leads
+	SET status_id = $2, fio = $3, phone_number = $4, address = $5,
+		internet = $6, cleaning = $7, shipping = $8,
+		reward_internet = $9, reward_cleaning = $10, reward_shipping = $11,
+		completed_at = $12, payment_at = $13,
+		address_normalized = $14, fias_id = $15, city = $16, geo_lat = $17, geo_lon = $18,
+		tariff_internet = $19, tariff_cleaning = $20, tariff_shipping = $21,
+		payout_id = $22
+	WHERE id = $1
+`
+
+func leadRecordArgs(lead models.Lead) []any {
+	return []any{
+		lead.ID, lead.StatusID, lead.FIO, lead.PhoneNumber, lead.Address,
+		lead.Internet, lead.Cleaning, lead.Shipping,
+		lead.RewardInternet, lead.RewardCleaning, lead.RewardShipping,
+		lead.CompletedAt, lead.PaymentAt,
+		lead.AddressNormalized, lead.FiasID, lead.City, lead.GeoLat, lead.GeoLon,
+		lead.TariffInternet, lead.TariffCleaning, lead.TariffShipping,
+		lead.PayoutID,
+	}
+}
+
+// UpdateLeadRecord записывает все изменяемые поля лида, в том числе сброс дат в NULL,
+// и в той же транзакции проводит изменение вознаграждений и комиссий по книге начислений
+func (s *Storage) UpdateLeadRecord(ctx context.Context, lead models.Lead, rates models.CommissionRates) error {
+	const op = "storage.leads.UpdateLeadRecord"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, updateLeadRecordQuery, leadRecordArgs(lead)...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrLeadNotFound
+	}
+
+	if err := postLeadRewards(ctx, tx, lead, rates); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpdateLeadWithOutbox записывает лид и событие outbox в одной транзакции.
+// Если статус лида уже не fromStatusID, ничего не меняет и возвращает ErrLeadStatusChanged.
+// Если задан dup, проверяет новые телефон и адрес на дубль так же, как CreateLeadWithOutbox.
+func (s *Storage) UpdateLeadWithOutbox(ctx context.Context, lead models.Lead, fromStatusID int64, event *models.OutboxEvent, rates models.CommissionRates, dup *models.DuplicateCheck) error {
+	const op = "storage.leads.UpdateLeadWithOutbox"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var currentStatusID int64
+	err = tx.QueryRowContext(ctx, "SELECT status_id FROM leads WHERE id = $1 FOR UPDATE", lead.ID).Scan(&currentStatusID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrLeadNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if currentStatusID != fromStatusID {
+		return ErrLeadStatusChanged
+	}
+
+	if dup != nil {
+		existing, err := findDuplicateLead(ctx, tx, &lead, dup)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if existing != nil {
+			dup.Existing = existing
+			return ErrLeadDuplicate
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, updateLeadRecordQuery, leadRecordArgs(lead)...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := postLeadRewards(ctx, tx, lead, rates); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.LeadID = lead.ID
+
+	outboxQuery := `INSERT INTO bitrix_outbox (lead_id, action, payload) VALUES ($1, $2, $3) RETURNING id`
+	err = tx.QueryRowContext(ctx, outboxQuery, event.LeadID, event.Action, event.Payload).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UnpaidCoveredLeads возвращает ещё не оплаченные завершённые лиды агента, которые покрыты
+// его выплатами. Заработок идёт по порядку: вознаграждение лида по книге — на дату завершения,
+// прочие начисления (рефералы, комиссии, корректировки) — на дату проводки. Лид покрыт,
+// если всё заработанное до него включительно не больше выплаченного агенту.
+func (s *Storage) UnpaidCoveredLeads(ctx context.Context, userID int64) ([]models.Lead, error) {
+	const op = "storage.leads.UnpaidCoveredLeads"
+
+	query := `
+		WITH agent_entries AS (
+			SELECT e.amount, e.service, e.created_at, t.kind, t.lead_id
+			FROM ledger_entries e
+			JOIN ledger_transactions t ON t.id = e.transaction_id
+			WHERE e.account = $1 AND e.user_id = $2
+		), paid AS (
+			SELECT COALESCE(-SUM(amount), 0) AS total
+			FROM agent_entries
+			WHERE kind = $3
+		), earnings AS (
+			SELECT l.id AS lead_id, l.payout_id, l.completed_at AS earned_at, SUM(a.amount) AS amount
+			FROM leads l
+			JOIN agent_entries a ON a.lead_id = l.id AND a.service = ANY($4)
+			WHERE l.user_id = $2 AND l.completed_at IS NOT NULL
+			GROUP BY l.id
+			HAVING SUM(a.amount) > 0
+			UNION ALL
+			SELECT NULL, NULL, created_at, amount
+			FROM agent_entries
+			WHERE kind <> $3 AND NOT (COALESCE(service, '') = ANY($4))
+		), running AS (
+			SELECT lead_id, payout_id,
+			       SUM(amount) OVER (ORDER BY earned_at, lead_id NULLS FIRST ROWS UNBOUNDED PRECEDING) AS total
+			FROM earnings
+		)
+		SELECT ` + leadColumns + `
+		FROM leads
+		WHERE id IN (
+			SELECT r.lead_id FROM running r, paid p
+			WHERE r.lead_id IS NOT NULL AND r.payout_id IS NULL AND r.total <= p.total
+		)
+		ORDER BY completed_at, id
+	`
+
+	services := []string{models.LedgerServiceInternet, models.LedgerServiceCleaning, models.LedgerServiceShipping}
+	rows, err := s.db.QueryContext(ctx, query, models.LedgerAccountAgent, userID, models.LedgerKindPayout, pq.Array(services))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var leads []models.Lead
+	for rows.Next() {
+		var lead models.Lead
+		if err := scanLead(rows, &lead); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		leads = append(leads, lead)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return leads, nil
+}
+
+// LeadHasUnsentEvents сообщает, есть ли у лида события outbox, ещё не доставленные в Bitrix,
+// в том числе ушедшие в dead-letter
+func (s *Storage) LeadHasUnsentEvents(ctx context.Context, leadID int64) (bool, error) {
+	const op = "storage.leads.LeadHasUnsentEvents"
+
+	query := `SELECT EXISTS (SELECT 1 FROM bitrix_outbox WHERE lead_id = $1 AND status <> $2)`
+
+	var unsent bool
+	if err := s.db.QueryRowContext(ctx, query, leadID, models.OutboxStatusDone).Scan(&unsent); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return unsent, nil
+}
+
+// LeadDealIDs возвращает ID лидов, у которых создана сделка, по ID сделки
+func (s *Storage) LeadDealIDs(ctx context.Context) (map[int64]int64, error) {
+	const op = "storage.leads.LeadDealIDs"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT bitrix_deal_id, id FROM leads WHERE bitrix_deal_id IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	leads := make(map[int64]int64)
+	for rows.Next() {
+		var dealID, leadID int64
+		if err := rows.Scan(&dealID, &leadID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		leads[dealID] = leadID
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return leads, nil
+}
+
 func (s *Storage) DeleteLead(ctx context.Context, id int64) error {
 	const op = "storage.leads.DeleteLead"
 
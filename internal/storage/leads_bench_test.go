@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"ia-online-golang/internal/models"
+)
+
+// Сравнение выборки лидов с комментариями: построчные запросы против одного пакетного.
+// Нужна PostgreSQL с применёнными миграциями; временный агент с лидами удаляется после замера:
+//
+//	BENCH_STORAGE_PATH="postgres://..." go test ./internal/storage -run '^$' -bench Leads
+const (
+	benchLeads           = 500
+	benchCommentsPerLead = 3
+)
+
+func BenchmarkLeadsPerLeadComments(b *testing.B) {
+	s, userID := benchLeadsStorage(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := leadsPerLeadComments(ctx, s, userID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLeadsWithComments(b *testing.B) {
+	s, userID := benchLeadsStorage(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Leads(ctx, models.LeadFilter{UserID: &userID, WithComments: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLeadsWithoutComments(b *testing.B) {
+	s, userID := benchLeadsStorage(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Leads(ctx, models.LeadFilter{UserID: &userID}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchLeadsStorage подключается к базе из BENCH_STORAGE_PATH и создаёт агента
+// с benchLeads лидами по benchCommentsPerLead комментариев
+func benchLeadsStorage(b *testing.B) (*Storage, int64) {
+	b.Helper()
+
+	dsn := os.Getenv("BENCH_STORAGE_PATH")
+	if dsn == "" {
+		b.Skip("BENCH_STORAGE_PATH is not set")
+	}
+
+	s, err := NewStorage(dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { s.Close() })
+
+	ctx := context.Background()
+
+	userID, err := seedBenchLeads(ctx, s)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		if err := cleanupBenchLeads(ctx, s, userID); err != nil {
+			b.Errorf("clean up seeded data for user %d: %v", userID, err)
+		}
+	})
+
+	return s, userID
+}
+
+func seedBenchLeads(ctx context.Context, s *Storage) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	suffix := time.Now().UnixNano()
+
+	var userID int64
+	userQuery := `
+		INSERT INTO users (phone_number, email, name, password_hash, referral_code)
+		VALUES ($1, $2, 'benchleads', '-', $3)
+		RETURNING id
+	`
+	err = tx.QueryRowContext(ctx, userQuery,
+		fmt.Sprintf("+0%d", suffix%1e15),
+		fmt.Sprintf("benchleads-%d@example.invalid", suffix),
+		fmt.Sprintf("benchleads-%d", suffix),
+	).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+
+	leadsQuery := `
+		INSERT INTO leads (user_id, fio, phone_number, address, internet, cleaning, shipping, status_id)
+		SELECT $1, 'Бенчмарк ' || n, '+7900' || lpad(n::text, 7, '0'), 'ул. Тестовая, ' || n, true, false, false, 0
+		FROM generate_series(1, $2) AS n
+	`
+	if _, err := tx.ExecContext(ctx, leadsQuery, userID, benchLeads); err != nil {
+		return 0, err
+	}
+
+	commentsQuery := `
+		INSERT INTO comments (lead_id, user_id, source, text)
+		SELECT l.id, $1, 'agent', 'Комментарий ' || n
+		FROM leads l, generate_series(1, $2) AS n
+		WHERE l.user_id = $1
+	`
+	if _, err := tx.ExecContext(ctx, commentsQuery, userID, benchCommentsPerLead); err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
+func cleanupBenchLeads(ctx context.Context, s *Storage, userID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		"DELETE FROM comments WHERE lead_id IN (SELECT id FROM leads WHERE user_id = $1)",
+		"DELETE FROM leads WHERE user_id = $1",
+		"DELETE FROM users WHERE id = $1",
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// leadsPerLeadComments повторяет прежнюю выборку: отдельный запрос комментариев на каждый лид
+func leadsPerLeadComments(ctx context.Context, s *Storage, userID int64) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT id FROM leads WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		commentRows, err := s.db.QueryContext(ctx, "SELECT text FROM comments WHERE lead_id = $1 ORDER BY created_at", id)
+		if err != nil {
+			return err
+		}
+		for commentRows.Next() {
+			var text string
+			if err := commentRows.Scan(&text); err != nil {
+				commentRows.Close()
+				return err
+			}
+		}
+		commentRows.Close()
+	}
+
+	return nil
+}
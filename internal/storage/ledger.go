@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/lib/money"
+	"ia-online-golang/internal/models"
+	"strings"
+	"time"
+)
+
+type LedgerRepositoryI interface {
+	SaveLedgerTransaction(ctx context.Context, transaction *models.LedgerTransaction) error
+	LedgerBalance(ctx context.Context, userID int64, from, to *time.Time) ([]models.LedgerBalance, error)
+	LedgerEntries(ctx context.Context, userID int64, limit, offset int64) ([]models.LedgerEntry, error)
+}
+
+var (
+	ErrLedgerUnbalanced = errors.New("ledger transaction is not balanced")
+)
+
+func (s *Storage) SaveLedgerTransaction(ctx context.Context, transaction *models.LedgerTransaction) error {
+	const op = "storage.ledger.SaveLedgerTransaction"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err := insertLedgerTransaction(ctx, tx, transaction); err != nil {
+		if errors.Is(err, ErrLedgerUnbalanced) {
+			return ErrLedgerUnbalanced
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// insertLedgerTransaction записывает проводку со всеми строками в транзакции tx.
+// Баланс проверяется и здесь, и триггером в БД при коммите.
+func insertLedgerTransaction(ctx context.Context, tx *sql.Tx, transaction *models.LedgerTransaction) error {
+	var sum money.Amount
+	for _, entry := range transaction.Entries {
+		sum += entry.Amount
+	}
+	if sum != 0 || len(transaction.Entries) == 0 {
+		return ErrLedgerUnbalanced
+	}
+
+	query := `
+		INSERT INTO ledger_transactions (kind, lead_id, referral_id, payout_id, created_by, description)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err := tx.QueryRowContext(ctx, query,
+		transaction.Kind, transaction.LeadID, transaction.ReferralID, transaction.PayoutID, transaction.CreatedBy, transaction.Description,
+	).Scan(&transaction.ID, &transaction.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	entryQuery := `
+		INSERT INTO ledger_entries (transaction_id, account, user_id, service, amount, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	for i := range transaction.Entries {
+		entry := &transaction.Entries[i]
+		entry.TransactionID = transaction.ID
+		entry.CreatedAt = transaction.CreatedAt
+
+		err := tx.QueryRowContext(ctx, entryQuery,
+			entry.TransactionID, entry.Account, entry.UserID, entry.Service, entry.Amount, entry.CreatedAt,
+		).Scan(&entry.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// postLeadRewards доводит начисления по лиду в книге до текущих вознаграждений лида:
+// разница по каждой услуге записывается начислением или сторно на счёт агента,
+// а с общей разницы пригласившим агента начисляется комиссия по ставкам rates.
+func postLeadRewards(ctx context.Context, tx *sql.Tx, lead models.Lead, rates models.CommissionRates) error {
+	query := `
+		SELECT e.service, SUM(e.amount)
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		WHERE t.lead_id = $1 AND t.kind IN ($2, $3) AND e.account = $4
+		GROUP BY e.service
+	`
+	rows, err := tx.QueryContext(ctx, query, lead.ID, models.LedgerKindAccrual, models.LedgerKindReversal, models.LedgerAccountAgent)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	posted := make(map[string]money.Amount)
+	for rows.Next() {
+		var service sql.NullString
+		var amount money.Amount
+		if err := rows.Scan(&service, &amount); err != nil {
+			return err
+		}
+		posted[service.String] = amount
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rewards := []struct {
+		service string
+		amount  money.Amount
+	}{
+		{models.LedgerServiceInternet, lead.RewardInternet},
+		{models.LedgerServiceCleaning, lead.RewardCleaning},
+		{models.LedgerServiceShipping, lead.RewardShipping},
+	}
+
+	var total money.Amount
+	for _, reward := range rewards {
+		delta := reward.amount - posted[reward.service]
+		if delta == 0 {
+			continue
+		}
+		total += delta
+
+		kind := models.LedgerKindAccrual
+		if delta < 0 {
+			kind = models.LedgerKindReversal
+		}
+
+		service := reward.service
+		transaction := models.LedgerTransaction{
+			Kind:        kind,
+			LeadID:      &lead.ID,
+			Description: fmt.Sprintf("Вознаграждение по лиду %d (%s): %s", lead.ID, service, reward.amount),
+			Entries:     models.AgentTransfer(lead.UserID, &service, delta, models.LedgerAccountRewards),
+		}
+
+		if err := insertLedgerTransaction(ctx, tx, &transaction); err != nil {
+			return err
+		}
+	}
+
+	if total == 0 || len(rates) == 0 {
+		return nil
+	}
+
+	return postLeadCommissions(ctx, tx, lead, total, rates)
+}
+
+// postLeadCommissions начисляет (или сторнирует при delta < 0) комиссию с изменения
+// вознаграждения по лиду всем пригласившим агента на глубину len(rates).
+// Комиссия считается с разницы, поэтому смена ставок не пересчитывает прошлые начисления.
+func postLeadCommissions(ctx context.Context, tx *sql.Tx, lead models.Lead, delta money.Amount, rates models.CommissionRates) error {
+	query := `
+		WITH RECURSIVE chain (user_id, level) AS (
+			SELECT u.id, 1
+			FROM referrals r
+			JOIN users u ON u.referral_code = r.referral_id
+			WHERE r.user_id = $1
+			UNION ALL
+			SELECT u.id, c.level + 1
+			FROM chain c
+			JOIN referrals r ON r.user_id = c.user_id
+			JOIN users u ON u.referral_code = r.referral_id
+			WHERE c.level < $2
+		)
+		SELECT user_id, level FROM chain ORDER BY level
+	`
+	rows, err := tx.QueryContext(ctx, query, lead.UserID, len(rates))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	service := models.LedgerServiceCommission
+	var entries []models.LedgerEntry
+	for rows.Next() {
+		var inviterID int64
+		var level int
+		if err := rows.Scan(&inviterID, &level); err != nil {
+			return err
+		}
+
+		// Цепочка приглашений может замкнуться на самого агента
+		if inviterID == lead.UserID {
+			continue
+		}
+
+		commission := rates.Commission(level, delta)
+		if commission == 0 {
+			continue
+		}
+		entries = append(entries, models.AgentTransfer(inviterID, &service, commission, models.LedgerAccountRewards)...)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	kind := models.LedgerKindAccrual
+	if delta < 0 {
+		kind = models.LedgerKindReversal
+	}
+
+	transaction := models.LedgerTransaction{
+		Kind:        kind,
+		LeadID:      &lead.ID,
+		Description: fmt.Sprintf("Комиссия с вознаграждения агента %d по лиду %d: %s", lead.UserID, lead.ID, delta),
+		Entries:     entries,
+	}
+
+	return insertLedgerTransaction(ctx, tx, &transaction)
+}
+
+// LedgerBalance суммирует движения по счёту агента в разрезе статей.
+// Без from и to возвращает текущий баланс.
+func (s *Storage) LedgerBalance(ctx context.Context, userID int64, from, to *time.Time) ([]models.LedgerBalance, error) {
+	const op = "storage.ledger.LedgerBalance"
+
+	conditions := []string{"account = $1", "user_id = $2"}
+	args := []any{models.LedgerAccountAgent, userID}
+
+	if from != nil {
+		args = append(args, *from)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if to != nil {
+		args = append(args, *to)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	query := "SELECT service, SUM(amount) FROM ledger_entries WHERE " + strings.Join(conditions, " AND ") + " GROUP BY service"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var balances []models.LedgerBalance
+	for rows.Next() {
+		var balance models.LedgerBalance
+		if err := rows.Scan(&balance.Service, &balance.Amount); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		balances = append(balances, balance)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return balances, nil
+}
+
+// LedgerEntries возвращает движения по счёту агента, новые сначала
+func (s *Storage) LedgerEntries(ctx context.Context, userID int64, limit, offset int64) ([]models.LedgerEntry, error) {
+	const op = "storage.ledger.LedgerEntries"
+
+	query := `
+		SELECT e.id, e.transaction_id, e.account, e.user_id, e.service, e.amount, e.created_at,
+		       t.kind, t.lead_id, t.referral_id, t.payout_id, t.description
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		WHERE e.account = $1 AND e.user_id = $2
+		ORDER BY e.created_at DESC, e.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, models.LedgerAccountAgent, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	entries := []models.LedgerEntry{}
+	for rows.Next() {
+		var e models.LedgerEntry
+		err := rows.Scan(
+			&e.ID, &e.TransactionID, &e.Account, &e.UserID, &e.Service, &e.Amount, &e.CreatedAt,
+			&e.Kind, &e.LeadID, &e.ReferralID, &e.PayoutID, &e.Description,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return entries, nil
+}
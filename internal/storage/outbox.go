@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/models"
+	"time"
+)
+
+type OutboxRepositoryI interface {
+	SaveOutboxEvent(ctx context.Context, event *models.OutboxEvent) error
+	ClaimOutboxEvents(ctx context.Context, limit int, staleAfter time.Duration) ([]models.OutboxEvent, error)
+	CompleteOutboxEvent(ctx context.Context, id int64) error
+	RetryOutboxEvent(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error
+	DeadOutboxEvent(ctx context.Context, id int64, attempts int, lastError string) error
+	DeadOutboxEvents(ctx context.Context, leadID *int64) ([]models.OutboxEvent, error)
+	RequeueOutboxEvent(ctx context.Context, id int64) (models.OutboxEvent, error)
+}
+
+var (
+	ErrOutboxEventNotFound = errors.New("dead outbox event not found")
+)
+
+// Колонки события в порядке, который ожидает scanOutboxEvent
+const outboxColumns = `id, lead_id, action, payload, status, attempts, last_error, next_attempt_at, created_at`
+
+func scanOutboxEvent(row rowScanner, e *models.OutboxEvent) error {
+	return row.Scan(&e.ID, &e.LeadID, &e.Action, &e.Payload, &e.Status, &e.Attempts, &e.LastError, &e.NextAttemptAt, &e.CreatedAt)
+}
+
+func (s *Storage) SaveOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	const op = "storage.outbox.SaveOutboxEvent"
+
+	query := `INSERT INTO bitrix_outbox (lead_id, action, payload) VALUES ($1, $2, $3) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, event.LeadID, event.Action, event.Payload).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ClaimOutboxEvents помечает готовые к отправке события как обрабатываемые и возвращает их.
+// События, зависшие в обработке дольше staleAfter, забираются повторно.
+func (s *Storage) ClaimOutboxEvents(ctx context.Context, limit int, staleAfter time.Duration) ([]models.OutboxEvent, error) {
+	const op = "storage.outbox.ClaimOutboxEvents"
+
+	query := `
+		UPDATE bitrix_outbox
+		SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id
+			FROM bitrix_outbox
+			WHERE (status = $2 AND next_attempt_at <= CURRENT_TIMESTAMP)
+			   OR (status = $1 AND updated_at <= $3)
+			ORDER BY id
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns + `
+	`
+
+	staleBefore := time.Now().Add(-staleAfter)
+
+	rows, err := s.db.QueryContext(ctx, query, models.OutboxStatusProcessing, models.OutboxStatusPending, staleBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var e models.OutboxEvent
+		if err := scanOutboxEvent(rows, &e); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) CompleteOutboxEvent(ctx context.Context, id int64) error {
+	const op = "storage.outbox.CompleteOutboxEvent"
+
+	query := "UPDATE bitrix_outbox SET status = $2, last_error = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1"
+	_, err := s.db.ExecContext(ctx, query, id, models.OutboxStatusDone)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetryOutboxEvent(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string) error {
+	const op = "storage.outbox.RetryOutboxEvent"
+
+	query := `
+		UPDATE bitrix_outbox
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	_, err := s.db.ExecContext(ctx, query, id, models.OutboxStatusPending, attempts, nextAttemptAt, lastError)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeadOutboxEvent(ctx context.Context, id int64, attempts int, lastError string) error {
+	const op = "storage.outbox.DeadOutboxEvent"
+
+	query := `
+		UPDATE bitrix_outbox
+		SET status = $2, attempts = $3, last_error = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+	_, err := s.db.ExecContext(ctx, query, id, models.OutboxStatusDead, attempts, lastError)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeadOutboxEvents возвращает события в dead-letter, по лиду leadID или все
+func (s *Storage) DeadOutboxEvents(ctx context.Context, leadID *int64) ([]models.OutboxEvent, error) {
+	const op = "storage.outbox.DeadOutboxEvents"
+
+	query := `
+		SELECT ` + outboxColumns + `
+		FROM bitrix_outbox
+		WHERE status = $1 AND ($2::bigint IS NULL OR lead_id = $2)
+		ORDER BY id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, models.OutboxStatusDead, leadID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	events := []models.OutboxEvent{}
+	for rows.Next() {
+		var e models.OutboxEvent
+		if err := scanOutboxEvent(rows, &e); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// RequeueOutboxEvent возвращает событие из dead-letter в очередь с обнулёнными попытками
+func (s *Storage) RequeueOutboxEvent(ctx context.Context, id int64) (models.OutboxEvent, error) {
+	const op = "storage.outbox.RequeueOutboxEvent"
+
+	query := `
+		UPDATE bitrix_outbox
+		SET status = $2, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $3
+		RETURNING ` + outboxColumns
+
+	var e models.OutboxEvent
+	err := scanOutboxEvent(s.db.QueryRowContext(ctx, query, id, models.OutboxStatusPending, models.OutboxStatusDead), &e)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OutboxEvent{}, ErrOutboxEventNotFound
+		}
+		return models.OutboxEvent{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return e, nil
+}
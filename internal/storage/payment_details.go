@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/models"
+
+	"github.com/lib/pq"
+)
+
+type PaymentDetailsRepositoryI interface {
+	SavePaymentDetails(ctx context.Context, record *models.PaymentDetailsRecord) error
+	PaymentDetailsByUserID(ctx context.Context, userID int64) (models.PaymentDetailsRecord, error)
+	PaymentDetailsByUserIDs(ctx context.Context, userIDs []int64) ([]models.PaymentDetailsRecord, error)
+	PaymentDetailsWithStaleKey(ctx context.Context, currentKeyID string, limit int64) ([]models.PaymentDetailsRecord, error)
+	ReencryptPaymentDetails(ctx context.Context, record *models.PaymentDetailsRecord, oldKeyID string) error
+}
+
+var (
+	ErrPaymentDetailsNotFound = errors.New("payment details not found")
+	// Запись изменили между чтением и перешифровкой
+	ErrPaymentDetailsChanged = errors.New("payment details changed")
+)
+
+const paymentDetailsColumns = `user_id, method, full_name, self_employed, inn_encrypted, card_number_encrypted,
+	account_encrypted, bik, bank_name, corr_account, key_id, created_at, updated_at`
+
+func scanPaymentDetails(row rowScanner, record *models.PaymentDetailsRecord) error {
+	return row.Scan(
+		&record.UserID, &record.Method, &record.FullName, &record.SelfEmployed, &record.INNEncrypted,
+		&record.CardNumberEncrypted, &record.AccountEncrypted, &record.BIK, &record.BankName, &record.CorrAccount,
+		&record.KeyID, &record.CreatedAt, &record.UpdatedAt,
+	)
+}
+
+// SavePaymentDetails создаёт или полностью заменяет реквизиты агента
+func (s *Storage) SavePaymentDetails(ctx context.Context, record *models.PaymentDetailsRecord) error {
+	const op = "storage.payment_details.SavePaymentDetails"
+
+	query := `
+		INSERT INTO payment_details (user_id, method, full_name, self_employed, inn_encrypted, card_number_encrypted,
+			account_encrypted, bik, bank_name, corr_account, key_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (user_id) DO UPDATE SET
+			method = EXCLUDED.method,
+			full_name = EXCLUDED.full_name,
+			self_employed = EXCLUDED.self_employed,
+			inn_encrypted = EXCLUDED.inn_encrypted,
+			card_number_encrypted = EXCLUDED.card_number_encrypted,
+			account_encrypted = EXCLUDED.account_encrypted,
+			bik = EXCLUDED.bik,
+			bank_name = EXCLUDED.bank_name,
+			corr_account = EXCLUDED.corr_account,
+			key_id = EXCLUDED.key_id,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + paymentDetailsColumns
+
+	err := scanPaymentDetails(s.db.QueryRowContext(ctx, query,
+		record.UserID, record.Method, record.FullName, record.SelfEmployed, record.INNEncrypted, record.CardNumberEncrypted,
+		record.AccountEncrypted, record.BIK, record.BankName, record.CorrAccount, record.KeyID,
+	), record)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) PaymentDetailsByUserID(ctx context.Context, userID int64) (models.PaymentDetailsRecord, error) {
+	const op = "storage.payment_details.PaymentDetailsByUserID"
+
+	var record models.PaymentDetailsRecord
+	err := scanPaymentDetails(s.db.QueryRowContext(ctx, "SELECT "+paymentDetailsColumns+" FROM payment_details WHERE user_id = $1", userID), &record)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PaymentDetailsRecord{}, ErrPaymentDetailsNotFound
+		}
+		return models.PaymentDetailsRecord{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return record, nil
+}
+
+// PaymentDetailsByUserIDs возвращает реквизиты тех агентов из списка, у которых они есть
+func (s *Storage) PaymentDetailsByUserIDs(ctx context.Context, userIDs []int64) ([]models.PaymentDetailsRecord, error) {
+	const op = "storage.payment_details.PaymentDetailsByUserIDs"
+
+	return s.queryPaymentDetails(ctx, op, "SELECT "+paymentDetailsColumns+" FROM payment_details WHERE user_id = ANY($1)", pq.Array(userIDs))
+}
+
+// PaymentDetailsWithStaleKey возвращает записи, зашифрованные не текущим ключом
+func (s *Storage) PaymentDetailsWithStaleKey(ctx context.Context, currentKeyID string, limit int64) ([]models.PaymentDetailsRecord, error) {
+	const op = "storage.payment_details.PaymentDetailsWithStaleKey"
+
+	query := "SELECT " + paymentDetailsColumns + " FROM payment_details WHERE key_id <> $1 ORDER BY user_id LIMIT $2"
+
+	return s.queryPaymentDetails(ctx, op, query, currentKeyID, limit)
+}
+
+func (s *Storage) queryPaymentDetails(ctx context.Context, op, query string, args ...any) ([]models.PaymentDetailsRecord, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var records []models.PaymentDetailsRecord
+	for rows.Next() {
+		var record models.PaymentDetailsRecord
+		if err := scanPaymentDetails(rows, &record); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return records, nil
+}
+
+// ReencryptPaymentDetails сохраняет перешифрованные поля, только если запись всё ещё
+// зашифрована ключом oldKeyID; иначе её успел перезаписать агент и возвращается ErrPaymentDetailsChanged
+func (s *Storage) ReencryptPaymentDetails(ctx context.Context, record *models.PaymentDetailsRecord, oldKeyID string) error {
+	const op = "storage.payment_details.ReencryptPaymentDetails"
+
+	query := `
+		UPDATE payment_details
+		SET inn_encrypted = $2, card_number_encrypted = $3, account_encrypted = $4, key_id = $5
+		WHERE user_id = $1 AND key_id = $6
+	`
+	result, err := s.db.ExecContext(ctx, query,
+		record.UserID, record.INNEncrypted, record.CardNumberEncrypted, record.AccountEncrypted, record.KeyID, oldKeyID,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return ErrPaymentDetailsChanged
+	}
+
+	return nil
+}
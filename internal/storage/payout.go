@@ -0,0 +1,423 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/models"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PayoutRepositoryI interface {
+	PayoutFunds(ctx context.Context, userID int64, heldSince time.Time) (models.PayoutFunds, error)
+	CreatePayout(ctx context.Context, payout *models.Payout, heldSince time.Time) error
+	PayoutByID(ctx context.Context, id int64) (models.Payout, error)
+	PayoutEvents(ctx context.Context, payoutID int64) ([]models.PayoutEvent, error)
+	Payouts(ctx context.Context, filter models.PayoutFilter) ([]models.Payout, error)
+	UpdatePayoutStatus(ctx context.Context, payout *models.Payout, fromStatuses []string, event *models.PayoutEvent) error
+	MarkPayoutPaid(ctx context.Context, payout *models.Payout, event *models.PayoutEvent) error
+	PayoutsWithUnpaidLeads(ctx context.Context) ([]models.Payout, error)
+	MarkPayoutLeadsPaid(ctx context.Context, id int64) error
+}
+
+var (
+	ErrPayoutNotFound          = errors.New("payout not found")
+	ErrPayoutExists            = errors.New("user already has an open payout")
+	ErrPayoutInsufficientFunds = errors.New("insufficient funds for payout")
+	// Статус заявки изменился между чтением и записью
+	ErrPayoutStatusChanged = errors.New("payout status changed")
+	// Заявка уже ушла в банк в выгруженном реестре
+	ErrPayoutExported = errors.New("payout is in exported batch")
+)
+
+const payoutColumns = `id, user_id, amount, status, comment, reject_reason, processed_by,
+	created_at, updated_at, approved_at, paid_at, batch_id`
+
+// payoutFields возвращает указатели на поля заявки в порядке payoutColumns
+func payoutFields(payout *models.Payout) []any {
+	return []any{
+		&payout.ID, &payout.UserID, &payout.Amount, &payout.Status, &payout.Comment, &payout.RejectReason,
+		&payout.ProcessedBy, &payout.CreatedAt, &payout.UpdatedAt, &payout.ApprovedAt, &payout.PaidAt, &payout.BatchID,
+	}
+}
+
+func scanPayout(row rowScanner, payout *models.Payout) error {
+	return row.Scan(payoutFields(payout)...)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// payoutFunds считает баланс агента по книге, начисления на удержании
+// (проведённые позже heldSince) и сумму незакрытых заявок
+func payoutFunds(ctx context.Context, q queryRower, userID int64, heldSince time.Time) (models.PayoutFunds, error) {
+	var funds models.PayoutFunds
+
+	query := `
+		SELECT COALESCE(SUM(amount), 0),
+		       COALESCE(SUM(amount) FILTER (WHERE amount > 0 AND created_at > $3), 0)
+		FROM ledger_entries
+		WHERE account = $1 AND user_id = $2
+	`
+	err := q.QueryRowContext(ctx, query, models.LedgerAccountAgent, userID, heldSince).Scan(&funds.Balance, &funds.Held)
+	if err != nil {
+		return funds, err
+	}
+
+	query = "SELECT COALESCE(SUM(amount), 0) FROM payouts WHERE user_id = $1 AND status = ANY($2)"
+	open := pq.Array([]string{models.PayoutStatusRequested, models.PayoutStatusApproved})
+	if err := q.QueryRowContext(ctx, query, userID, open).Scan(&funds.Reserved); err != nil {
+		return funds, err
+	}
+
+	return funds, nil
+}
+
+func (s *Storage) PayoutFunds(ctx context.Context, userID int64, heldSince time.Time) (models.PayoutFunds, error) {
+	const op = "storage.payout.PayoutFunds"
+
+	funds, err := payoutFunds(ctx, s.db, userID, heldSince)
+	if err != nil {
+		return models.PayoutFunds{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return funds, nil
+}
+
+// CreatePayout сохраняет заявку, если сумма не превышает доступные агенту средства.
+// Блокировка по агенту не даёт двум параллельным заявкам потратить одни и те же деньги.
+func (s *Storage) CreatePayout(ctx context.Context, payout *models.Payout, heldSince time.Time) error {
+	const op = "storage.payout.CreatePayout"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('payouts'), $1)", payout.UserID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	funds, err := payoutFunds(ctx, tx, payout.UserID, heldSince)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if payout.Amount > funds.Available() {
+		return ErrPayoutInsufficientFunds
+	}
+
+	query := `
+		INSERT INTO payouts (user_id, amount, status, comment)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + payoutColumns
+	err = scanPayout(tx.QueryRowContext(ctx, query, payout.UserID, payout.Amount, models.PayoutStatusRequested, payout.Comment), payout)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrPayoutExists
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event := models.PayoutEvent{
+		PayoutID: payout.ID,
+		UserID:   &payout.UserID,
+		ToStatus: payout.Status,
+		Comment:  payout.Comment,
+	}
+	if err := insertPayoutEvent(ctx, tx, &event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func insertPayoutEvent(ctx context.Context, tx *sql.Tx, event *models.PayoutEvent) error {
+	query := `
+		INSERT INTO payout_events (payout_id, user_id, from_status, to_status, comment)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	return tx.QueryRowContext(ctx, query, event.PayoutID, event.UserID, event.FromStatus, event.ToStatus, event.Comment).
+		Scan(&event.ID, &event.CreatedAt)
+}
+
+func (s *Storage) PayoutByID(ctx context.Context, id int64) (models.Payout, error) {
+	const op = "storage.payout.PayoutByID"
+
+	var payout models.Payout
+	err := scanPayout(s.db.QueryRowContext(ctx, "SELECT "+payoutColumns+" FROM payouts WHERE id = $1", id), &payout)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Payout{}, ErrPayoutNotFound
+		}
+		return models.Payout{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return payout, nil
+}
+
+func (s *Storage) PayoutEvents(ctx context.Context, payoutID int64) ([]models.PayoutEvent, error) {
+	const op = "storage.payout.PayoutEvents"
+
+	query := `
+		SELECT id, payout_id, user_id, from_status, to_status, comment, created_at
+		FROM payout_events
+		WHERE payout_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, payoutID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	events := []models.PayoutEvent{}
+	for rows.Next() {
+		var e models.PayoutEvent
+		if err := rows.Scan(&e.ID, &e.PayoutID, &e.UserID, &e.FromStatus, &e.ToStatus, &e.Comment, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) Payouts(ctx context.Context, filter models.PayoutFilter) ([]models.Payout, error) {
+	const op = "storage.payout.Payouts"
+
+	var conditions []string
+	var args []any
+
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if filter.Status != nil {
+		args = append(args, *filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	query := "SELECT " + payoutColumns + " FROM payouts"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	payouts := []models.Payout{}
+	for rows.Next() {
+		var payout models.Payout
+		if err := scanPayout(rows, &payout); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		payouts = append(payouts, payout)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return payouts, nil
+}
+
+// lockPayoutStatus блокирует заявку до конца транзакции и проверяет, что её статус
+// всё ещё один из fromStatuses
+func lockPayoutStatus(ctx context.Context, tx *sql.Tx, id int64, fromStatuses []string) (string, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, "SELECT status FROM payouts WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrPayoutNotFound
+		}
+		return "", err
+	}
+
+	for _, from := range fromStatuses {
+		if status == from {
+			return status, nil
+		}
+	}
+
+	return status, ErrPayoutStatusChanged
+}
+
+// UpdatePayoutStatus записывает решение по заявке (одобрение или отказ) и событие журнала.
+// Если заявка уже не в одном из fromStatuses, возвращает ErrPayoutStatusChanged.
+func (s *Storage) UpdatePayoutStatus(ctx context.Context, payout *models.Payout, fromStatuses []string, event *models.PayoutEvent) error {
+	const op = "storage.payout.UpdatePayoutStatus"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockPayoutStatus(ctx, tx, payout.ID, fromStatuses)
+	if err != nil {
+		if errors.Is(err, ErrPayoutNotFound) || errors.Is(err, ErrPayoutStatusChanged) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Отклонить заявку из выгруженного реестра нельзя: банк может её всё равно оплатить
+	if payout.Status == models.PayoutStatusRejected {
+		var exported bool
+		err = tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM payouts p
+				JOIN payout_batches b ON b.id = p.batch_id
+				WHERE p.id = $1 AND b.status <> $2
+			)`, payout.ID, models.PayoutBatchStatusCreated,
+		).Scan(&exported)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if exported {
+			return ErrPayoutExported
+		}
+	}
+
+	query := `
+		UPDATE payouts
+		SET status = $2, reject_reason = $3, processed_by = $4, approved_at = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING ` + payoutColumns
+	err = scanPayout(tx.QueryRowContext(ctx, query,
+		payout.ID, payout.Status, payout.RejectReason, payout.ProcessedBy, payout.ApprovedAt,
+	), payout)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.PayoutID = payout.ID
+	event.FromStatus = &current
+	event.ToStatus = payout.Status
+	if err := insertPayoutEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MarkPayoutPaid закрывает одобренную заявку и списывает сумму со счёта агента по книге.
+// Лиды, покрытые выплатой, отмечает оплаченными сервис лидов.
+func (s *Storage) MarkPayoutPaid(ctx context.Context, payout *models.Payout, event *models.PayoutEvent) error {
+	const op = "storage.payout.MarkPayoutPaid"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockPayoutStatus(ctx, tx, payout.ID, []string{models.PayoutStatusApproved})
+	if err != nil {
+		if errors.Is(err, ErrPayoutNotFound) || errors.Is(err, ErrPayoutStatusChanged) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+		UPDATE payouts
+		SET status = $2, processed_by = $3, paid_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING ` + payoutColumns
+	err = scanPayout(tx.QueryRowContext(ctx, query, payout.ID, models.PayoutStatusPaid, payout.ProcessedBy), payout)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	transaction := models.LedgerTransaction{
+		Kind:        models.LedgerKindPayout,
+		PayoutID:    &payout.ID,
+		CreatedBy:   payout.ProcessedBy,
+		Description: fmt.Sprintf("Выплата по заявке %d", payout.ID),
+		Entries:     models.AgentTransfer(payout.UserID, nil, -payout.Amount, models.LedgerAccountPayouts),
+	}
+	if err := insertLedgerTransaction(ctx, tx, &transaction); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.PayoutID = payout.ID
+	event.FromStatus = &current
+	event.ToStatus = payout.Status
+	if err := insertPayoutEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PayoutsWithUnpaidLeads возвращает оплаченные заявки, покрытые которыми лиды ещё не отмечены оплаченными
+func (s *Storage) PayoutsWithUnpaidLeads(ctx context.Context) ([]models.Payout, error) {
+	const op = "storage.payout.PayoutsWithUnpaidLeads"
+
+	query := "SELECT " + payoutColumns + " FROM payouts WHERE status = $1 AND leads_paid_at IS NULL ORDER BY id"
+
+	rows, err := s.db.QueryContext(ctx, query, models.PayoutStatusPaid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	payouts := []models.Payout{}
+	for rows.Next() {
+		var payout models.Payout
+		if err := scanPayout(rows, &payout); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		payouts = append(payouts, payout)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return payouts, nil
+}
+
+// MarkPayoutLeadsPaid отмечает, что покрытые заявкой лиды закрыты
+func (s *Storage) MarkPayoutLeadsPaid(ctx context.Context, id int64) error {
+	const op = "storage.payout.MarkPayoutLeadsPaid"
+
+	_, err := s.db.ExecContext(ctx, "UPDATE payouts SET leads_paid_at = CURRENT_TIMESTAMP WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/models"
+)
+
+type PayoutBatchRepositoryI interface {
+	CreatePayoutBatch(ctx context.Context, batch *models.PayoutBatch) error
+	PayoutBatches(ctx context.Context, limit, offset int64) ([]models.PayoutBatch, error)
+	PayoutBatchByID(ctx context.Context, id int64) (models.PayoutBatch, error)
+	PayoutBatchItems(ctx context.Context, batchID int64) ([]models.PayoutBatchItem, error)
+	MarkPayoutBatchExported(ctx context.Context, id int64) error
+	CompletePayoutBatch(ctx context.Context, id int64) error
+}
+
+var (
+	ErrPayoutBatchNotFound = errors.New("payout batch not found")
+	// Нет одобренных заявок, которые можно включить в пачку
+	ErrPayoutBatchEmpty = errors.New("no approved payouts for batch")
+)
+
+// Колонки пачки вместе с количеством и суммой её заявок; требует GROUP BY b.id
+const payoutBatchColumns = `b.id, b.status, b.created_by, b.created_at, b.exported_at, b.completed_at,
+	COUNT(p.id), COALESCE(SUM(p.amount), 0)`
+
+func scanPayoutBatch(row rowScanner, batch *models.PayoutBatch) error {
+	return row.Scan(
+		&batch.ID, &batch.Status, &batch.CreatedBy, &batch.CreatedAt, &batch.ExportedAt, &batch.CompletedAt,
+		&batch.Count, &batch.Total,
+	)
+}
+
+// CreatePayoutBatch включает в новую пачку все одобренные заявки, которые ещё не попали в другую.
+// Если таких нет, пачка не создаётся и возвращается ErrPayoutBatchEmpty.
+func (s *Storage) CreatePayoutBatch(ctx context.Context, batch *models.PayoutBatch) error {
+	const op = "storage.payout_batch.CreatePayoutBatch"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO payout_batches (status, created_by)
+		VALUES ($1, $2)
+		RETURNING id, status, created_by, created_at, exported_at, completed_at
+	`
+	err = tx.QueryRowContext(ctx, query, models.PayoutBatchStatusCreated, batch.CreatedBy).Scan(
+		&batch.ID, &batch.Status, &batch.CreatedBy, &batch.CreatedAt, &batch.ExportedAt, &batch.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `
+		WITH attached AS (
+			UPDATE payouts
+			SET batch_id = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE status = $2 AND batch_id IS NULL
+			RETURNING amount
+		)
+		SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM attached
+	`
+	err = tx.QueryRowContext(ctx, query, batch.ID, models.PayoutStatusApproved).Scan(&batch.Count, &batch.Total)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if batch.Count == 0 {
+		return ErrPayoutBatchEmpty
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PayoutBatches возвращает пачки, новые сначала
+func (s *Storage) PayoutBatches(ctx context.Context, limit, offset int64) ([]models.PayoutBatch, error) {
+	const op = "storage.payout_batch.PayoutBatches"
+
+	query := `
+		SELECT ` + payoutBatchColumns + `
+		FROM payout_batches b
+		LEFT JOIN payouts p ON p.batch_id = b.id
+		GROUP BY b.id
+		ORDER BY b.created_at DESC, b.id DESC
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	batches := []models.PayoutBatch{}
+	for rows.Next() {
+		var batch models.PayoutBatch
+		if err := scanPayoutBatch(rows, &batch); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		batches = append(batches, batch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return batches, nil
+}
+
+func (s *Storage) PayoutBatchByID(ctx context.Context, id int64) (models.PayoutBatch, error) {
+	const op = "storage.payout_batch.PayoutBatchByID"
+
+	query := `
+		SELECT ` + payoutBatchColumns + `
+		FROM payout_batches b
+		LEFT JOIN payouts p ON p.batch_id = b.id
+		WHERE b.id = $1
+		GROUP BY b.id
+	`
+
+	var batch models.PayoutBatch
+	if err := scanPayoutBatch(s.db.QueryRowContext(ctx, query, id), &batch); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PayoutBatch{}, ErrPayoutBatchNotFound
+		}
+		return models.PayoutBatch{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return batch, nil
+}
+
+// PayoutBatchItems возвращает заявки пачки с именем и контактами агентов
+func (s *Storage) PayoutBatchItems(ctx context.Context, batchID int64) ([]models.PayoutBatchItem, error) {
+	const op = "storage.payout_batch.PayoutBatchItems"
+
+	query := `
+		SELECT ` + payoutColumns + `, user_name, user_email, user_phone
+		FROM payouts
+		JOIN (
+			SELECT id AS agent_id, name AS user_name, email AS user_email, phone_number AS user_phone
+			FROM users
+		) agents ON agents.agent_id = payouts.user_id
+		WHERE batch_id = $1
+		ORDER BY id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	items := []models.PayoutBatchItem{}
+	for rows.Next() {
+		var item models.PayoutBatchItem
+		fields := append(payoutFields(&item.Payout), &item.UserName, &item.UserEmail, &item.UserPhone)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return items, nil
+}
+
+// MarkPayoutBatchExported отмечает первую выгрузку реестра; повторные выгрузки её не меняют
+func (s *Storage) MarkPayoutBatchExported(ctx context.Context, id int64) error {
+	const op = "storage.payout_batch.MarkPayoutBatchExported"
+
+	query := `
+		UPDATE payout_batches
+		SET status = $2, exported_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $3
+	`
+	_, err := s.db.ExecContext(ctx, query, id, models.PayoutBatchStatusExported, models.PayoutBatchStatusCreated)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CompletePayoutBatch закрывает пачку, если в ней не осталось одобренных, но не оплаченных заявок
+func (s *Storage) CompletePayoutBatch(ctx context.Context, id int64) error {
+	const op = "storage.payout_batch.CompletePayoutBatch"
+
+	query := `
+		UPDATE payout_batches
+		SET status = $2, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status <> $2
+		  AND NOT EXISTS (SELECT 1 FROM payouts WHERE batch_id = $1 AND status = $3)
+	`
+	_, err := s.db.ExecContext(ctx, query, id, models.PayoutBatchStatusCompleted, models.PayoutStatusApproved)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -5,17 +5,24 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"ia-online-golang/internal/lib/money"
 	"ia-online-golang/internal/models"
+
+	"github.com/lib/pq"
 )
 
 type ReferralRepositoryI interface {
 	ReferralByReferralId(ctx context.Context, referral_id string) (models.Referral, error)
-	SaveReferral(ctx context.Context, userId int64, referral_id string) error
+	SaveReferral(ctx context.Context, userId int64, referral_id string, cost money.Amount) error
 	ReferralsUser(ctx context.Context, referral_id string) ([]models.ReferralAndUser, error)
 	Referrals(ctx context.Context) ([]models.Referral, error)
-	GetInactiveReferralsWithReadyLeads(ctx context.Context) ([]models.Referral, error)
+	PendingReferrals(ctx context.Context, rule models.ReferralRule) ([]models.ReferralProgress, error)
 	UpdateActive(ctx context.Context, referral_id int64, active bool) error
+	ActivateReferral(ctx context.Context, referralID int64) (bool, error)
+	ExpireReferral(ctx context.Context, referralID int64) error
 	ActiveReferralsByReferralId(ctx context.Context, referral_id string) ([]models.Referral, error)
+	ReferralNodes(ctx context.Context, filter models.ReferralNodeFilter) ([]models.ReferralNode, error)
+	ReferralSummary(ctx context.Context, inviterID int64) (models.ReferralSummary, error)
 }
 
 var (
@@ -23,12 +30,16 @@ var (
 	ErrReferralsNotFound = errors.New("referrals not found")
 )
 
+// В referrals.referral_id хранится UUID пригласившего, а искать можно и по выбранному им коду.
+// inviterCode превращает любой из кодов в параметре $1 в UUID.
+const inviterCode = `(SELECT referral_code FROM users WHERE referral_code = $1 OR lower(vanity_code) = lower($1))`
+
 func (s *Storage) ReferralByReferralId(ctx context.Context, referral_id string) (models.Referral, error) {
 	const op = "storage.auth.ReferralByReferralId"
 	var referral models.Referral
 
 	// Запрос для получения refresh-токена по user_id
-	query := "SELECT id, user_id, referral_id, cost FROM referrals WHERE referral_id = $1"
+	query := "SELECT id, user_id, referral_id, cost FROM referrals WHERE referral_id = " + inviterCode
 	err := s.db.QueryRowContext(ctx, query, referral_id).Scan(
 		&referral.ID,
 		&referral.UserID,
@@ -45,11 +56,12 @@ func (s *Storage) ReferralByReferralId(ctx context.Context, referral_id string)
 	return referral, nil
 }
 
-func (s *Storage) SaveReferral(ctx context.Context, userId int64, referral_id string) error {
+// SaveReferral сохраняет реферала с вознаграждением cost, которое пригласивший получит при активации
+func (s *Storage) SaveReferral(ctx context.Context, userId int64, referral_id string, cost money.Amount) error {
 	const op = "storage.auth.SaveReferral"
 
-	query := "INSERT INTO referrals (user_id, referral_id) VALUES ($1, $2)"
-	_, err := s.db.ExecContext(ctx, query, userId, referral_id)
+	query := "INSERT INTO referrals (user_id, referral_id, cost) VALUES ($1, $2, $3)"
+	_, err := s.db.ExecContext(ctx, query, userId, referral_id, cost)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -60,16 +72,25 @@ func (s *Storage) SaveReferral(ctx context.Context, userId int64, referral_id st
 func (s *Storage) ReferralsUser(ctx context.Context, referral_id string) ([]models.ReferralAndUser, error) {
 	const op = "storage.auth.ReferralsUser"
 
+	// Комиссия — всё, что владелец кода получил с проводок по лидам реферала
 	query := `
 		SELECT u.id, u.phone_number, u.email, u.name, u.telegram, u.city,
 		       u.password_hash, u.referral_code, u.created_at, u.is_active,
-		       u.roles, r.id, r.user_id, r.referral_id, r.created_at, r.active
+		       u.roles, r.id, r.user_id, r.referral_id, r.created_at, r.active,
+		       COALESCE((
+		           SELECT SUM(e.amount)
+		           FROM ledger_entries e
+		           JOIN ledger_transactions t ON t.id = e.transaction_id
+		           JOIN leads l ON l.id = t.lead_id
+		           WHERE l.user_id = u.id
+		             AND e.account = $2 AND e.service = $3
+		             AND e.user_id = (SELECT id FROM users WHERE referral_code = $1 OR lower(vanity_code) = lower($1))
+		       ), 0)
 		FROM users u
 		JOIN referrals r ON u.id = r.user_id
-		WHERE r.referral_id = $1
-	`
+		WHERE r.referral_id = ` + inviterCode
 
-	rows, err := s.db.QueryContext(ctx, query, referral_id)
+	rows, err := s.db.QueryContext(ctx, query, referral_id, models.LedgerAccountAgent, models.LedgerServiceCommission)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -82,6 +103,7 @@ func (s *Storage) ReferralsUser(ctx context.Context, referral_id string) ([]mode
 			&ru.User.ID, &ru.User.PhoneNumber, &ru.User.Email, &ru.User.Name, &ru.User.Telegram, &ru.User.City,
 			&ru.User.PasswordHash, &ru.User.ReferralCode, &ru.User.CreatedAt, &ru.User.IsActive,
 			&ru.User.Roles, &ru.Referral.ID, &ru.Referral.UserID, &ru.Referral.ReferralCode, &ru.Referral.CreatedAt, &ru.Referral.Active,
+			&ru.Commission,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
@@ -135,7 +157,7 @@ func (s *Storage) Referrals(ctx context.Context) ([]models.Referral, error) {
 func (s *Storage) ActiveReferralsByReferralId(ctx context.Context, referralID string) ([]models.Referral, error) {
 	const op = "storage.Referrals"
 
-	query := "SELECT id, user_id, referral_id, created_at, active, cost FROM referrals WHERE referral_id = $1 AND active = true"
+	query := "SELECT id, user_id, referral_id, created_at, active, cost FROM referrals WHERE active = true AND referral_id = " + inviterCode
 
 	rows, err := s.db.QueryContext(ctx, query, referralID)
 	if err != nil {
@@ -164,44 +186,54 @@ func (s *Storage) ActiveReferralsByReferralId(ctx context.Context, referralID st
 	return referrals, nil
 }
 
-func (s *Storage) GetInactiveReferralsWithReadyLeads(ctx context.Context) ([]models.Referral, error) {
-	const op = "storage.referral.GetInactiveReferralsWithReadyLeads"
+// referralProgressJoin считает для реферала r лиды, засчитанные по условиям активации:
+// в статусах $1, поданные за $2 секунд с регистрации (0 — без срока)
+const referralProgressJoin = `
+	CROSS JOIN LATERAL (
+		SELECT COUNT(l.id) AS leads,
+		       COALESCE(SUM(l.reward_internet + l.reward_cleaning + l.reward_shipping), 0) AS reward
+		FROM leads l
+		WHERE l.user_id = r.user_id
+			AND l.status_id = ANY($1)
+			AND ($2::float8 = 0 OR l.created_at < r.created_at + make_interval(secs => $2::float8))
+	) q`
+
+// PendingReferrals возвращает неактивные и не истёкшие рефералы с тем, сколько лидов
+// в статусах rule.StatusIDs они подали за rule.Window с регистрации и на какую сумму вознаграждений
+func (s *Storage) PendingReferrals(ctx context.Context, rule models.ReferralRule) ([]models.ReferralProgress, error) {
+	const op = "storage.referral.PendingReferrals"
 
 	query := `
-				SELECT r.*
+		SELECT r.id, r.user_id, r.referral_id, r.created_at, r.active, r.cost, q.leads, q.reward
 		FROM referrals r
-		JOIN users u ON u.id = r.user_id
-		JOIN leads l ON l.user_id = u.id
-		WHERE r.active = false
-		  AND l.status_id = 4
-		GROUP BY r.id
-		HAVING COUNT(l.id) > 2;
-
+		` + referralProgressJoin + `
+		WHERE NOT r.active AND r.expired_at IS NULL
+		ORDER BY r.id
 	`
 
-	rows, err := s.db.QueryContext(ctx, query)
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(rule.StatusIDs), rule.Window.Seconds())
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	var referrals []models.Referral
+	var referrals []models.ReferralProgress
 	for rows.Next() {
-		var ref models.Referral
-		if err := rows.Scan(&ref.ID, &ref.UserID, &ref.ReferralCode, &ref.CreatedAt, &ref.Active, &ref.Cost); err != nil {
+		var p models.ReferralProgress
+		err := rows.Scan(
+			&p.Referral.ID, &p.Referral.UserID, &p.Referral.ReferralCode, &p.Referral.CreatedAt, &p.Referral.Active, &p.Referral.Cost,
+			&p.Leads, &p.Reward,
+		)
+		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		referrals = append(referrals, ref)
+		referrals = append(referrals, p)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if len(referrals) == 0 {
-		return nil, ErrReferralsNotFound
-	}
-
 	return referrals, nil
 }
 
@@ -227,3 +259,168 @@ func (s *Storage) UpdateActive(ctx context.Context, referral_id int64, active bo
 
 	return nil
 }
+
+// ActivateReferral активирует реферала и в той же транзакции начисляет пригласившему
+// вознаграждение за него. Повторная активация ничего не меняет и возвращает false.
+func (s *Storage) ActivateReferral(ctx context.Context, referralID int64) (bool, error) {
+	const op = "storage.referral.ActivateReferral"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var referral models.Referral
+	query := "SELECT id, user_id, referral_id, active, cost FROM referrals WHERE id = $1 FOR UPDATE"
+	err = tx.QueryRowContext(ctx, query, referralID).Scan(
+		&referral.ID, &referral.UserID, &referral.ReferralCode, &referral.Active, &referral.Cost,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrReferralNotFound
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if referral.Active {
+		return false, nil
+	}
+
+	query = "UPDATE referrals SET active = true, activated_at = CURRENT_TIMESTAMP, expired_at = NULL WHERE id = $1"
+	if _, err := tx.ExecContext(ctx, query, referral.ID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if referral.Cost != 0 {
+		// Вознаграждение получает владелец реферального кода
+		var inviterID int64
+		err = tx.QueryRowContext(ctx, "SELECT id FROM users WHERE referral_code = $1", referral.ReferralCode).Scan(&inviterID)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		service := models.LedgerServiceReferral
+		transaction := models.LedgerTransaction{
+			Kind:        models.LedgerKindAccrual,
+			ReferralID:  &referral.ID,
+			Description: fmt.Sprintf("Вознаграждение за реферала %d", referral.UserID),
+			Entries:     models.AgentTransfer(inviterID, &service, referral.Cost, models.LedgerAccountRewards),
+		}
+
+		if err := insertLedgerTransaction(ctx, tx, &transaction); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// ExpireReferral отмечает, что реферал не выполнил условия активации в срок
+func (s *Storage) ExpireReferral(ctx context.Context, referralID int64) error {
+	const op = "storage.referral.ExpireReferral"
+
+	query := "UPDATE referrals SET expired_at = CURRENT_TIMESTAMP WHERE id = $1 AND NOT active AND expired_at IS NULL"
+	if _, err := s.db.ExecContext(ctx, query, referralID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReferralNodes возвращает рефералов агентов filter.InviterIDs с прогрессом активации
+// и заработком filter.RootID на них, сначала новых
+func (s *Storage) ReferralNodes(ctx context.Context, filter models.ReferralNodeFilter) ([]models.ReferralNode, error) {
+	const op = "storage.referral.ReferralNodes"
+
+	query := `
+		SELECT r.id, r.user_id, r.referral_id, r.created_at, r.active, r.cost, r.activated_at, r.expired_at,
+		       u.id, u.name, u.phone_number, u.city, inviter.id,
+		       (SELECT COUNT(*) FROM leads l WHERE l.user_id = r.user_id),
+		       q.leads, q.reward,
+		       COALESCE((
+		           SELECT SUM(e.amount)
+		           FROM ledger_entries e
+		           JOIN ledger_transactions t ON t.id = e.transaction_id
+		           LEFT JOIN leads l ON l.id = t.lead_id
+		           WHERE e.account = $4 AND e.user_id = $5
+		             AND (t.referral_id = r.id OR (l.user_id = r.user_id AND e.service = $6))
+		       ), 0)
+		FROM referrals r
+		JOIN users u ON u.id = r.user_id
+		JOIN users inviter ON inviter.referral_code = r.referral_id
+		` + referralProgressJoin + `
+		WHERE inviter.id = ANY($3)
+		ORDER BY r.created_at DESC, r.id DESC
+	`
+	args := []any{
+		pq.Array(filter.Rule.StatusIDs), filter.Rule.Window.Seconds(), pq.Array(filter.InviterIDs),
+		models.LedgerAccountAgent, filter.RootID, models.LedgerServiceCommission,
+	}
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit, filter.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var nodes []models.ReferralNode
+	for rows.Next() {
+		var n models.ReferralNode
+		err := rows.Scan(
+			&n.Referral.ID, &n.Referral.UserID, &n.Referral.ReferralCode, &n.Referral.CreatedAt, &n.Referral.Active,
+			&n.Referral.Cost, &n.Referral.ActivatedAt, &n.Referral.ExpiredAt,
+			&n.User.ID, &n.User.Name, &n.User.PhoneNumber, &n.User.City, &n.InviterID,
+			&n.Leads, &n.Progress.Leads, &n.Progress.Reward, &n.Earned,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		n.Progress.Referral = n.Referral
+		nodes = append(nodes, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nodes, nil
+}
+
+// ReferralSummary считает прямых рефералов агента по состояниям и всё, что он получил по программе
+func (s *Storage) ReferralSummary(ctx context.Context, inviterID int64) (models.ReferralSummary, error) {
+	const op = "storage.referral.ReferralSummary"
+
+	var summary models.ReferralSummary
+
+	query := `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE r.active),
+		       COUNT(*) FILTER (WHERE NOT r.active AND r.expired_at IS NULL),
+		       COUNT(*) FILTER (WHERE NOT r.active AND r.expired_at IS NOT NULL)
+		FROM referrals r
+		JOIN users inviter ON inviter.referral_code = r.referral_id
+		WHERE inviter.id = $1
+	`
+	err := s.db.QueryRowContext(ctx, query, inviterID).Scan(&summary.Total, &summary.Active, &summary.Pending, &summary.Expired)
+	if err != nil {
+		return models.ReferralSummary{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "SELECT COALESCE(SUM(amount), 0) FROM ledger_entries WHERE account = $1 AND user_id = $2 AND service = ANY($3)"
+	services := pq.Array([]string{models.LedgerServiceReferral, models.LedgerServiceCommission})
+	if err := s.db.QueryRowContext(ctx, query, models.LedgerAccountAgent, inviterID, services).Scan(&summary.Earned); err != nil {
+		return models.ReferralSummary{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/models"
+)
+
+type ReferralClickRepositoryI interface {
+	SaveReferralClick(ctx context.Context, click *models.ReferralClick) error
+	ReferralClickByToken(ctx context.Context, token string) (models.ReferralClick, error)
+	ConvertReferralClick(ctx context.Context, clickID int64, userID int64) error
+	ReferralFunnel(ctx context.Context, filter models.ReferralFunnelFilter) ([]models.ReferralFunnel, error)
+}
+
+var (
+	ErrReferralClickNotFound = errors.New("referral click not found")
+)
+
+func (s *Storage) SaveReferralClick(ctx context.Context, click *models.ReferralClick) error {
+	const op = "storage.referral_click.SaveReferralClick"
+
+	query := `
+		INSERT INTO referral_clicks (token, inviter_id, referral_code, user_agent, ip_hash,
+			utm_source, utm_medium, utm_campaign, utm_term, utm_content)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`
+	err := s.db.QueryRowContext(ctx, query,
+		click.Token, click.InviterID, click.ReferralCode, click.UserAgent, click.IPHash,
+		click.UTMSource, click.UTMMedium, click.UTMCampaign, click.UTMTerm, click.UTMContent,
+	).Scan(&click.ID, &click.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ReferralClickByToken(ctx context.Context, token string) (models.ReferralClick, error) {
+	const op = "storage.referral_click.ReferralClickByToken"
+
+	query := `
+		SELECT id, token, inviter_id, referral_code, user_agent, ip_hash,
+		       utm_source, utm_medium, utm_campaign, utm_term, utm_content,
+		       created_at, registered_user_id, registered_at
+		FROM referral_clicks
+		WHERE token = $1
+	`
+
+	var click models.ReferralClick
+	err := s.db.QueryRowContext(ctx, query, token).Scan(
+		&click.ID, &click.Token, &click.InviterID, &click.ReferralCode, &click.UserAgent, &click.IPHash,
+		&click.UTMSource, &click.UTMMedium, &click.UTMCampaign, &click.UTMTerm, &click.UTMContent,
+		&click.CreatedAt, &click.RegisteredUserID, &click.RegisteredAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ReferralClick{}, ErrReferralClickNotFound
+		}
+		return models.ReferralClick{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return click, nil
+}
+
+// ConvertReferralClick отмечает регистрацию пользователя по переходу. Переход засчитывается один раз.
+func (s *Storage) ConvertReferralClick(ctx context.Context, clickID int64, userID int64) error {
+	const op = "storage.referral_click.ConvertReferralClick"
+
+	query := `
+		UPDATE referral_clicks
+		SET registered_user_id = $2, registered_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND registered_user_id IS NULL
+	`
+	result, err := s.db.ExecContext(ctx, query, clickID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rows == 0 {
+		return ErrReferralClickNotFound
+	}
+
+	return nil
+}
+
+// ReferralFunnel считает воронку по агентам: переходы и регистрации с них по дате перехода,
+// регистрации, активации и оплаченные лиды рефералов — по дате регистрации реферала
+func (s *Storage) ReferralFunnel(ctx context.Context, filter models.ReferralFunnelFilter) ([]models.ReferralFunnel, error) {
+	const op = "storage.referral_click.ReferralFunnel"
+
+	query := `
+		WITH clicks AS (
+			SELECT inviter_id,
+			       COUNT(*) AS clicks,
+			       COUNT(DISTINCT ip_hash) AS visitors,
+			       COUNT(registered_user_id) AS registrations
+			FROM referral_clicks
+			WHERE ($1::timestamptz IS NULL OR created_at >= $1)
+				AND ($2::timestamptz IS NULL OR created_at < $2)
+			GROUP BY inviter_id
+		), referred AS (
+			SELECT inviter.id AS inviter_id,
+			       COUNT(*) AS registrations,
+			       COUNT(*) FILTER (WHERE r.active) AS activated,
+			       COUNT(*) FILTER (WHERE EXISTS (
+			           SELECT 1 FROM leads l WHERE l.user_id = r.user_id AND l.status_id = $3
+			       )) AS paid
+			FROM referrals r
+			JOIN users inviter ON inviter.referral_code = r.referral_id
+			WHERE ($1::timestamptz IS NULL OR r.created_at >= $1)
+				AND ($2::timestamptz IS NULL OR r.created_at < $2)
+			GROUP BY inviter.id
+		)
+		SELECT u.id, u.name, u.phone_number, u.city,
+		       COALESCE(c.clicks, 0), COALESCE(c.visitors, 0), COALESCE(c.registrations, 0),
+		       COALESCE(f.registrations, 0), COALESCE(f.activated, 0), COALESCE(f.paid, 0)
+		FROM users u
+		LEFT JOIN clicks c ON c.inviter_id = u.id
+		LEFT JOIN referred f ON f.inviter_id = u.id
+		WHERE CASE WHEN $4::bigint IS NULL
+		           THEN c.inviter_id IS NOT NULL OR f.inviter_id IS NOT NULL
+		           ELSE u.id = $4
+		      END
+		ORDER BY COALESCE(c.clicks, 0) DESC, COALESCE(f.registrations, 0) DESC, u.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, filter.From, filter.To, filter.PaidStatusID, filter.InviterID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	funnels := []models.ReferralFunnel{}
+	for rows.Next() {
+		var f models.ReferralFunnel
+		err := rows.Scan(
+			&f.User.ID, &f.User.Name, &f.User.PhoneNumber, &f.User.City,
+			&f.Clicks, &f.Visitors, &f.ClickRegistrations,
+			&f.Registrations, &f.Activated, &f.FirstPaidLead,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		funnels = append(funnels, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return funnels, nil
+}
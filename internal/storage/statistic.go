@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"ia-online-golang/internal/models"
+	"time"
+)
+
+type StatisticRepositoryI interface {
+	StatisticBuckets(ctx context.Context, filter models.StatisticFilter) ([]time.Time, error)
+	EarningsSeries(ctx context.Context, filter models.StatisticFilter) ([]models.EarningsPoint, error)
+	LeadStatusSeries(ctx context.Context, filter models.StatisticFilter) ([]models.LeadStatusPoint, error)
+}
+
+// StatisticBuckets возвращает начала всех шагов периода, в том числе пустых,
+// чтобы ряд для графика был без пропусков
+func (s *Storage) StatisticBuckets(ctx context.Context, filter models.StatisticFilter) ([]time.Time, error) {
+	const op = "storage.statistic.StatisticBuckets"
+
+	query := `
+		SELECT generate_series(
+			date_trunc($1, $2::timestamptz),
+			$3::timestamptz - interval '1 microsecond',
+			('1 ' || $1)::interval
+		)
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, filter.Interval, filter.From, filter.To)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var buckets []time.Time
+	for rows.Next() {
+		var bucket time.Time
+		if err := rows.Scan(&bucket); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return buckets, nil
+}
+
+// EarningsSeries суммирует начисления на счёт агента по шагам и статьям. Выплаты без статьи не входят.
+func (s *Storage) EarningsSeries(ctx context.Context, filter models.StatisticFilter) ([]models.EarningsPoint, error) {
+	const op = "storage.statistic.EarningsSeries"
+
+	query := `
+		SELECT date_trunc($1, created_at) AS bucket, service, SUM(amount)
+		FROM ledger_entries
+		WHERE account = $2 AND service IS NOT NULL
+			AND created_at >= $3 AND created_at < $4
+			AND ($5::bigint IS NULL OR user_id = $5)
+		GROUP BY bucket, service
+		ORDER BY bucket, service
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, filter.Interval, models.LedgerAccountAgent, filter.From, filter.To, filter.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var points []models.EarningsPoint
+	for rows.Next() {
+		var point models.EarningsPoint
+		if err := rows.Scan(&point.Bucket, &point.Service, &point.Amount); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		points = append(points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return points, nil
+}
+
+// LeadStatusSeries считает лиды по шагу подачи и текущему статусу
+func (s *Storage) LeadStatusSeries(ctx context.Context, filter models.StatisticFilter) ([]models.LeadStatusPoint, error) {
+	const op = "storage.statistic.LeadStatusSeries"
+
+	query := `
+		SELECT date_trunc($1, l.created_at) AS bucket, st.name, COUNT(*)
+		FROM leads l
+		JOIN statuses st ON st.id = l.status_id
+		WHERE l.created_at >= $2 AND l.created_at < $3
+			AND ($4::bigint IS NULL OR l.user_id = $4)
+		GROUP BY bucket, st.name
+		ORDER BY bucket, st.name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, filter.Interval, filter.From, filter.To, filter.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var points []models.LeadStatusPoint
+	for rows.Next() {
+		var point models.LeadStatusPoint
+		if err := rows.Scan(&point.Bucket, &point.Status, &point.Count); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		points = append(points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return points, nil
+}
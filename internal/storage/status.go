@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/models"
+
+	"github.com/lib/pq"
+)
+
+type StatusRepositoryI interface {
+	Statuses(ctx context.Context) ([]models.Status, error)
+	CreateStatus(ctx context.Context, status *models.Status) error
+	UpdateStatus(ctx context.Context, status models.Status) error
+}
+
+var (
+	ErrStatusNotFound = errors.New("status not found")
+	ErrStatusExists   = errors.New("status or stage already exists")
+)
+
+// Код ошибки PostgreSQL при нарушении уникальности
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+// Statuses возвращает все статусы вместе со стадиями Bitrix
+func (s *Storage) Statuses(ctx context.Context) ([]models.Status, error) {
+	const op = "storage.status.Statuses"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, bitrix_name FROM statuses ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var statuses []models.Status
+	index := make(map[int64]int)
+	for rows.Next() {
+		var status models.Status
+		if err := rows.Scan(&status.ID, &status.Name, &status.BitrixName); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		status.Stages = []models.StatusStage{}
+		index[status.ID] = len(statuses)
+		statuses = append(statuses, status)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stageRows, err := s.db.QueryContext(ctx, "SELECT id, status_id, category_id, stage_code FROM status_stages ORDER BY category_id, id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stageRows.Close()
+
+	for stageRows.Next() {
+		var stage models.StatusStage
+		if err := stageRows.Scan(&stage.ID, &stage.StatusID, &stage.CategoryID, &stage.StageCode); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if i, ok := index[stage.StatusID]; ok {
+			statuses[i].Stages = append(statuses[i].Stages, stage)
+		}
+	}
+
+	if err := stageRows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return statuses, nil
+}
+
+func (s *Storage) CreateStatus(ctx context.Context, status *models.Status) error {
+	const op = "storage.status.CreateStatus"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO statuses (name, bitrix_name) VALUES ($1, $2) RETURNING id"
+	err = tx.QueryRowContext(ctx, query, status.Name, status.BitrixName).Scan(&status.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrStatusExists
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertStatusStages(ctx, tx, status.ID, status.Stages); err != nil {
+		if isUniqueViolation(err) {
+			return ErrStatusExists
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpdateStatus обновляет статус и полностью заменяет список его стадий
+func (s *Storage) UpdateStatus(ctx context.Context, status models.Status) error {
+	const op = "storage.status.UpdateStatus"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE statuses SET name = $2, bitrix_name = $3 WHERE id = $1"
+	result, err := tx.ExecContext(ctx, query, status.ID, status.Name, status.BitrixName)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrStatusExists
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrStatusNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM status_stages WHERE status_id = $1", status.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := insertStatusStages(ctx, tx, status.ID, status.Stages); err != nil {
+		if isUniqueViolation(err) {
+			return ErrStatusExists
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func insertStatusStages(ctx context.Context, tx *sql.Tx, statusID int64, stages []models.StatusStage) error {
+	query := "INSERT INTO status_stages (status_id, category_id, stage_code) VALUES ($1, $2, $3)"
+
+	for _, stage := range stages {
+		if _, err := tx.ExecContext(ctx, query, statusID, stage.CategoryID, stage.StageCode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"ia-online-golang/internal/models"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TariffRepositoryI interface {
+	Tariffs(ctx context.Context, filter models.TariffFilter) ([]models.Tariff, error)
+	TariffByID(ctx context.Context, id int64) (models.Tariff, error)
+	CreateTariff(ctx context.Context, tariff *models.Tariff) error
+	UpdateTariff(ctx context.Context, tariff *models.Tariff) error
+	ActiveTariff(ctx context.Context, service string, city *string, at time.Time) (models.Tariff, error)
+}
+
+var (
+	ErrTariffNotFound = errors.New("tariff not found")
+	// Период тарифа пересекается с другим тарифом той же услуги в том же городе
+	ErrTariffOverlap = errors.New("tariff period overlaps another tariff")
+)
+
+// Код ошибки PostgreSQL при нарушении ограничения EXCLUDE
+const exclusionViolation = "23P01"
+
+func isExclusionViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == exclusionViolation
+}
+
+const tariffColumns = `id, service, city, amount, valid_from, valid_to, created_by, created_at, updated_at`
+
+func scanTariff(row rowScanner, tariff *models.Tariff) error {
+	return row.Scan(
+		&tariff.ID, &tariff.Service, &tariff.City, &tariff.Amount, &tariff.ValidFrom, &tariff.ValidTo,
+		&tariff.CreatedBy, &tariff.CreatedAt, &tariff.UpdatedAt,
+	)
+}
+
+// Tariffs возвращает тарифы по услуге и городу, сначала самые новые
+func (s *Storage) Tariffs(ctx context.Context, filter models.TariffFilter) ([]models.Tariff, error) {
+	const op = "storage.tariff.Tariffs"
+
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if filter.Service != nil {
+		args = append(args, *filter.Service)
+		conditions = append(conditions, fmt.Sprintf("service = $%d", len(args)))
+	}
+	if filter.City != nil {
+		args = append(args, *filter.City)
+		conditions = append(conditions, fmt.Sprintf("lower(city) = lower($%d)", len(args)))
+	}
+	if filter.At != nil {
+		args = append(args, *filter.At)
+		conditions = append(conditions, fmt.Sprintf("valid_from <= $%[1]d AND (valid_to IS NULL OR valid_to > $%[1]d)", len(args)))
+	}
+
+	query := "SELECT " + tariffColumns + " FROM tariffs"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY service, city NULLS FIRST, valid_from DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	tariffs := []models.Tariff{}
+	for rows.Next() {
+		var tariff models.Tariff
+		if err := scanTariff(rows, &tariff); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		tariffs = append(tariffs, tariff)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tariffs, nil
+}
+
+func (s *Storage) TariffByID(ctx context.Context, id int64) (models.Tariff, error) {
+	const op = "storage.tariff.TariffByID"
+
+	var tariff models.Tariff
+	err := scanTariff(s.db.QueryRowContext(ctx, "SELECT "+tariffColumns+" FROM tariffs WHERE id = $1", id), &tariff)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Tariff{}, ErrTariffNotFound
+		}
+		return models.Tariff{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tariff, nil
+}
+
+func (s *Storage) CreateTariff(ctx context.Context, tariff *models.Tariff) error {
+	const op = "storage.tariff.CreateTariff"
+
+	query := `
+		INSERT INTO tariffs (service, city, amount, valid_from, valid_to, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + tariffColumns
+
+	err := scanTariff(s.db.QueryRowContext(ctx, query,
+		tariff.Service, tariff.City, tariff.Amount, tariff.ValidFrom, tariff.ValidTo, tariff.CreatedBy,
+	), tariff)
+	if err != nil {
+		if isExclusionViolation(err) {
+			return ErrTariffOverlap
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpdateTariff меняет сумму, город и период тарифа. Лиды, созданные раньше,
+// хранят свой снимок тарифа, поэтому правка на них не влияет.
+func (s *Storage) UpdateTariff(ctx context.Context, tariff *models.Tariff) error {
+	const op = "storage.tariff.UpdateTariff"
+
+	query := `
+		UPDATE tariffs
+		SET service = $2, city = $3, amount = $4, valid_from = $5, valid_to = $6, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING ` + tariffColumns
+
+	err := scanTariff(s.db.QueryRowContext(ctx, query,
+		tariff.ID, tariff.Service, tariff.City, tariff.Amount, tariff.ValidFrom, tariff.ValidTo,
+	), tariff)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTariffNotFound
+		}
+		if isExclusionViolation(err) {
+			return ErrTariffOverlap
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ActiveTariff возвращает тариф услуги, действующий в момент at: тариф города city,
+// а если его нет — общий. Город сравнивается без учёта регистра.
+func (s *Storage) ActiveTariff(ctx context.Context, service string, city *string, at time.Time) (models.Tariff, error) {
+	const op = "storage.tariff.ActiveTariff"
+
+	query := `
+		SELECT ` + tariffColumns + `
+		FROM tariffs
+		WHERE service = $1
+			AND (city IS NULL OR lower(city) = lower($2))
+			AND valid_from <= $3 AND (valid_to IS NULL OR valid_to > $3)
+		ORDER BY city IS NULL, valid_from DESC
+		LIMIT 1
+	`
+
+	var tariff models.Tariff
+	err := scanTariff(s.db.QueryRowContext(ctx, query, service, city, at), &tariff)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Tariff{}, ErrTariffNotFound
+		}
+		return models.Tariff{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tariff, nil
+}
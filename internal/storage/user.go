@@ -24,12 +24,14 @@ type UserRepositoryI interface {
 	UpdatePasswordUser(ctx context.Context, password_hash string, userID int64) error
 	UpdateUser(ctx context.Context, user models.User) error
 	DeleteUser(ctx context.Context, id int) error
+	UpdateVanityCode(ctx context.Context, userID int64, code string) error
 }
 
 var (
 	ErrUserExists       = errors.New("user already exists")
 	ErrUserNotFound     = errors.New("user not found")
 	ErrUserIsNotUpdated = errors.New("user is not updated")
+	ErrVanityCodeTaken  = errors.New("referral code is already taken")
 )
 
 // Получение пользователя по email
@@ -37,7 +39,7 @@ func (s *Storage) UserByEmail(ctx context.Context, email string) (models.User, e
 	const op = "storage.user.UserByEmail"
 	var user models.User
 
-	query := "SELECT id, email, name, phone_number, telegram, is_active, created_at, city, password_hash, referral_code, roles FROM users WHERE email = $1"
+	query := "SELECT id, email, name, phone_number, telegram, is_active, created_at, city, password_hash, referral_code, vanity_code, roles FROM users WHERE email = $1"
 	err := s.db.QueryRowContext(ctx, query, email).Scan(
 		&user.ID,
 		&user.Email,
@@ -49,6 +51,7 @@ func (s *Storage) UserByEmail(ctx context.Context, email string) (models.User, e
 		&user.City,
 		&user.PasswordHash,
 		&user.ReferralCode,
+		&user.VanityCode,
 		&user.Roles,
 	)
 	if err != nil {
@@ -65,7 +68,7 @@ func (s *Storage) Users(ctx context.Context) ([]models.User, error) {
 	const op = "storage.user.Users"
 	var users []models.User
 
-	query := "SELECT id, email, name, phone_number, telegram, is_active, created_at, city, password_hash, referral_code, roles FROM users"
+	query := "SELECT id, email, name, phone_number, telegram, is_active, created_at, city, password_hash, referral_code, vanity_code, roles FROM users"
 	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -77,7 +80,7 @@ func (s *Storage) Users(ctx context.Context) ([]models.User, error) {
 		if err := rows.Scan(
 			&user.ID, &user.Email, &user.Name, &user.PhoneNumber, &user.Telegram,
 			&user.IsActive, &user.CreatedAt, &user.City, &user.PasswordHash,
-			&user.ReferralCode, &user.Roles,
+			&user.ReferralCode, &user.VanityCode, &user.Roles,
 		); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -91,11 +94,12 @@ func (s *Storage) Users(ctx context.Context) ([]models.User, error) {
 	return users, nil
 }
 
+// UserByReferralCode находит агента по UUID или по выбранному им коду (без учёта регистра)
 func (s *Storage) UserByReferralCode(ctx context.Context, referral_code string) (models.User, error) {
 	const op = "storage.user.UserByReferralCode"
 	var user models.User
 
-	query := "SELECT id, email, name, phone_number, telegram, is_active, created_at, city, password_hash, referral_code, roles FROM users WHERE referral_code = $1"
+	query := "SELECT id, email, name, phone_number, telegram, is_active, created_at, city, password_hash, referral_code, vanity_code, roles FROM users WHERE referral_code = $1 OR lower(vanity_code) = lower($1)"
 	err := s.db.QueryRowContext(ctx, query, referral_code).Scan(
 		&user.ID,
 		&user.Email,
@@ -107,6 +111,7 @@ func (s *Storage) UserByReferralCode(ctx context.Context, referral_code string)
 		&user.City,
 		&user.PasswordHash,
 		&user.ReferralCode,
+		&user.VanityCode,
 		&user.Roles,
 	)
 	if err != nil {
@@ -123,7 +128,7 @@ func (s *Storage) UserById(ctx context.Context, id int64) (models.User, error) {
 	const op = "storage.user.UserById"
 	var user models.User
 
-	query := "SELECT id, email, name, phone_number, telegram, is_active, created_at, city, password_hash, referral_code, roles FROM users WHERE id = $1"
+	query := "SELECT id, email, name, phone_number, telegram, is_active, created_at, city, password_hash, referral_code, vanity_code, roles FROM users WHERE id = $1"
 	err := s.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Email,
@@ -135,6 +140,7 @@ func (s *Storage) UserById(ctx context.Context, id int64) (models.User, error) {
 		&user.City,
 		&user.PasswordHash,
 		&user.ReferralCode,
+		&user.VanityCode,
 		&user.Roles,
 	)
 	if err != nil {
@@ -353,3 +359,27 @@ func (s *Storage) DeleteUser(ctx context.Context, id int) error {
 
 	return nil
 }
+
+// UpdateVanityCode задаёт агенту выбранный код. Прежний выбранный код освобождается.
+func (s *Storage) UpdateVanityCode(ctx context.Context, userID int64, code string) error {
+	const op = "storage.user.UpdateVanityCode"
+
+	query := "UPDATE users SET vanity_code = $1 WHERE id = $2"
+	result, err := s.db.ExecContext(ctx, query, code, userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrVanityCodeTaken
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"math/big"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -30,6 +31,7 @@ func UserToDTO(user models.User) dto.UserDTO {
 		ID:           &user.ID,
 		Roles:        user.Roles,
 		ReferralCode: user.ReferralCode,
+		VanityCode:   user.VanityCode,
 		Email:        user.Email,
 		Name:         user.Name,
 		PhoneNumber:  user.PhoneNumber,
@@ -85,6 +87,23 @@ func Contains(slice []string, value string) bool {
 	return false
 }
 
+// ParsePeriodBound разбирает границу периода: время RFC 3339 или дату 2006-01-02.
+// Для конца периода (end) дата означает начало следующего дня, чтобы день вошёл целиком.
+func ParsePeriodBound(val string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", val)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
 func derefInt64(i *int64) int64 {
 	if i != nil {
 		return *i
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS bitrix_outbox;
+
+ALTER TABLE leads
+    DROP COLUMN IF EXISTS sync_status,
+    DROP COLUMN IF EXISTS bitrix_deal_id;
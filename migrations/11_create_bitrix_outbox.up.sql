@@ -0,0 +1,26 @@
+ALTER TABLE leads
+    ADD COLUMN bitrix_deal_id BIGINT UNIQUE,
+    ADD COLUMN sync_status VARCHAR(20) NOT NULL DEFAULT 'pending';
+
+-- До появления outbox лиды сохранялись с ID сделки Bitrix в качестве первичного ключа
+UPDATE leads SET bitrix_deal_id = id, sync_status = 'synced';
+
+-- Локальные ID продолжают нумерацию после уже существующих
+SELECT setval(pg_get_serial_sequence('leads', 'id'), COALESCE((SELECT MAX(id) FROM leads), 0) + 1, false);
+
+CREATE TABLE bitrix_outbox (
+    id SERIAL PRIMARY KEY,
+    lead_id INTEGER NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (lead_id) REFERENCES leads(id)
+);
+
+CREATE INDEX bitrix_outbox_status_next_attempt_idx ON bitrix_outbox (status, next_attempt_at);
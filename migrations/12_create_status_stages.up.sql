@@ -0,0 +1,27 @@
+-- Статусы заполнялись с явными ID, поэтому сдвигаем последовательность
+SELECT setval(pg_get_serial_sequence('statuses', 'id'), COALESCE((SELECT MAX(id) FROM statuses), 0) + 1, false);
+
+CREATE TABLE status_stages (
+    id SERIAL PRIMARY KEY,
+    status_id INTEGER NOT NULL,
+    category_id INTEGER NOT NULL DEFAULT 0,
+    stage_code VARCHAR(100) NOT NULL,
+
+    FOREIGN KEY (status_id) REFERENCES statuses(id) ON DELETE CASCADE,
+    UNIQUE (category_id, stage_code)
+);
+
+-- Перенос соответствия стадий воронки C42, ранее зашитого в LeadService.EditDeal
+INSERT INTO status_stages (status_id, category_id, stage_code)
+SELECT s.id, 42, m.stage_code
+FROM (VALUES
+    (0, 'C42:NEW'),
+    (1, 'C42:PREPARATION'),
+    (2, 'C42:PREPAYMENT_INVOIC'),
+    (7, 'C42:EXECUTING'),
+    (3, 'C42:FINAL_INVOICE'),
+    (4, 'C42:1'),
+    (6, 'C42:LOSE'),
+    (5, 'C42:WON')
+) AS m(status_id, stage_code)
+JOIN statuses s ON s.id = m.status_id;
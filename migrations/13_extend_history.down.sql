@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS history_lead_id_created_at_idx;
+
+ALTER TABLE history
+    DROP COLUMN IF EXISTS changes,
+    DROP COLUMN IF EXISTS user_id,
+    DROP COLUMN IF EXISTS source;
@@ -0,0 +1,7 @@
+ALTER TABLE history
+    ADD COLUMN source VARCHAR(30) NOT NULL DEFAULT 'system',
+    ADD COLUMN user_id INTEGER,
+    ADD COLUMN changes JSONB NOT NULL DEFAULT '[]',
+    ADD FOREIGN KEY (user_id) REFERENCES users(id);
+
+CREATE INDEX history_lead_id_created_at_idx ON history (lead_id, created_at);
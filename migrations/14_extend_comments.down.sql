@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS comments_lead_id_created_at_idx;
+
+DELETE FROM comments WHERE user_id IS NULL;
+
+ALTER TABLE comments
+    DROP COLUMN bitrix_comment_id,
+    DROP COLUMN source,
+    ALTER COLUMN user_id SET NOT NULL;
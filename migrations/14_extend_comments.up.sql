@@ -0,0 +1,7 @@
+-- Комментарии из таймлайна Bitrix приходят без автора на нашей стороне
+ALTER TABLE comments
+    ALTER COLUMN user_id DROP NOT NULL,
+    ADD COLUMN source VARCHAR(20) NOT NULL DEFAULT 'agent',
+    ADD COLUMN bitrix_comment_id BIGINT UNIQUE;
+
+CREATE INDEX comments_lead_id_created_at_idx ON comments (lead_id, created_at);
@@ -0,0 +1,3 @@
+-- Keyset-пагинация списка лидов идёт по (created_at, id), в том числе внутри одного агента
+CREATE INDEX leads_created_at_id_idx ON leads (created_at, id);
+CREATE INDEX leads_user_id_created_at_id_idx ON leads (user_id, created_at, id);
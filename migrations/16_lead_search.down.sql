@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS leads_phone_digits_trgm_idx;
+DROP INDEX IF EXISTS leads_address_trgm_idx;
+DROP INDEX IF EXISTS leads_fio_trgm_idx;
+DROP INDEX IF EXISTS leads_search_vector_idx;
+
+ALTER TABLE leads
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS phone_digits;
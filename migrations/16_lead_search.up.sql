@@ -0,0 +1,23 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Цифры телефона в едином виде 7XXXXXXXXXX: "8 999 ...", "+7 (999) ..." и "999..." совпадают
+ALTER TABLE leads
+    ADD COLUMN phone_digits TEXT GENERATED ALWAYS AS (
+        CASE
+            WHEN length(regexp_replace(phone_number, '\D', '', 'g')) = 11
+                 AND left(regexp_replace(phone_number, '\D', '', 'g'), 1) = '8'
+                THEN '7' || substr(regexp_replace(phone_number, '\D', '', 'g'), 2)
+            WHEN length(regexp_replace(phone_number, '\D', '', 'g')) = 10
+                THEN '7' || regexp_replace(phone_number, '\D', '', 'g')
+            ELSE regexp_replace(phone_number, '\D', '', 'g')
+        END
+    ) STORED,
+    ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', coalesce(fio, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(address, '')), 'B')
+    ) STORED;
+
+CREATE INDEX leads_search_vector_idx ON leads USING GIN (search_vector);
+CREATE INDEX leads_fio_trgm_idx ON leads USING GIN (fio gin_trgm_ops);
+CREATE INDEX leads_address_trgm_idx ON leads USING GIN (address gin_trgm_ops);
+CREATE INDEX leads_phone_digits_trgm_idx ON leads USING GIN (phone_digits gin_trgm_ops);
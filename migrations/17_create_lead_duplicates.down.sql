@@ -0,0 +1 @@
+DROP TABLE IF EXISTS lead_duplicates;
@@ -0,0 +1,15 @@
+-- Попытки подать лид на клиента, которого уже закрепил за собой другой (или тот же) агент.
+-- lead_id — первый лид, за которым остаётся атрибуция.
+CREATE TABLE lead_duplicates (
+    id SERIAL PRIMARY KEY,
+    lead_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    phone_number VARCHAR(20) NOT NULL,
+    address VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (lead_id) REFERENCES leads(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX lead_duplicates_lead_id_idx ON lead_duplicates (lead_id);
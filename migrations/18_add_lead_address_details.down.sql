@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS leads_fias_id_idx;
+
+ALTER TABLE leads
+    DROP COLUMN IF EXISTS geo_lon,
+    DROP COLUMN IF EXISTS geo_lat,
+    DROP COLUMN IF EXISTS city,
+    DROP COLUMN IF EXISTS fias_id,
+    DROP COLUMN IF EXISTS address_normalized;
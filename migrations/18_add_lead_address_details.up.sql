@@ -0,0 +1,9 @@
+-- Результат стандартизации адреса через Dadata; пусто, если сервис был недоступен
+ALTER TABLE leads
+    ADD COLUMN address_normalized TEXT,
+    ADD COLUMN fias_id VARCHAR(36),
+    ADD COLUMN city VARCHAR(100),
+    ADD COLUMN geo_lat DOUBLE PRECISION,
+    ADD COLUMN geo_lon DOUBLE PRECISION;
+
+CREATE INDEX leads_fias_id_idx ON leads (fias_id);
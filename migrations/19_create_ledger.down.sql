@@ -0,0 +1,12 @@
+DROP TRIGGER IF EXISTS ledger_entries_balance ON ledger_entries;
+DROP FUNCTION IF EXISTS ledger_check_balance();
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_transactions;
+
+ALTER TABLE referrals
+    ALTER COLUMN cost TYPE FLOAT;
+
+ALTER TABLE leads
+    ALTER COLUMN reward_internet TYPE FLOAT,
+    ALTER COLUMN reward_cleaning TYPE FLOAT,
+    ALTER COLUMN reward_shipping TYPE FLOAT;
@@ -0,0 +1,93 @@
+-- Деньги храним точно: рубли с копейками, без FLOAT
+ALTER TABLE leads
+    ALTER COLUMN reward_internet TYPE NUMERIC(12,2) USING ROUND(reward_internet::numeric, 2),
+    ALTER COLUMN reward_cleaning TYPE NUMERIC(12,2) USING ROUND(reward_cleaning::numeric, 2),
+    ALTER COLUMN reward_shipping TYPE NUMERIC(12,2) USING ROUND(reward_shipping::numeric, 2);
+
+ALTER TABLE referrals
+    ALTER COLUMN cost TYPE NUMERIC(12,2) USING ROUND(cost::numeric, 2);
+
+-- Проводка: начисление, сторно, выплата или ручная корректировка.
+-- Сумма всех строк ledger_entries одной проводки всегда равна нулю.
+CREATE TABLE ledger_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,
+    lead_id INTEGER,
+    referral_id INTEGER,
+    created_by INTEGER,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (lead_id) REFERENCES leads(id),
+    FOREIGN KEY (referral_id) REFERENCES referrals(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+-- Строки проводки по счетам. Для счёта agent user_id — агент, которому должны,
+-- положительная сумма увеличивает его баланс.
+CREATE TABLE ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id BIGINT NOT NULL,
+    account VARCHAR(20) NOT NULL,
+    user_id INTEGER,
+    service VARCHAR(20),
+    amount NUMERIC(14,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (transaction_id) REFERENCES ledger_transactions(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX ledger_transactions_lead_id_idx ON ledger_transactions (lead_id);
+CREATE UNIQUE INDEX ledger_transactions_referral_accrual_idx ON ledger_transactions (referral_id) WHERE kind = 'accrual';
+CREATE INDEX ledger_entries_transaction_id_idx ON ledger_entries (transaction_id);
+CREATE INDEX ledger_entries_user_id_created_at_idx ON ledger_entries (user_id, created_at) WHERE account = 'agent';
+
+-- Проверка баланса проводки в конце транзакции, когда все её строки уже вставлены
+CREATE FUNCTION ledger_check_balance() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT SUM(amount) FROM ledger_entries WHERE transaction_id = NEW.transaction_id) <> 0 THEN
+        RAISE EXCEPTION 'ledger transaction % is not balanced', NEW.transaction_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_entries_balance
+    AFTER INSERT OR UPDATE ON ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION ledger_check_balance();
+
+-- Переносим уже начисленные вознаграждения по лидам
+WITH tx AS (
+    INSERT INTO ledger_transactions (kind, lead_id, description, created_at)
+    SELECT 'accrual', id, 'Перенос вознаграждения по лиду', COALESCE(completed_at, created_at)
+    FROM leads
+    WHERE reward_internet <> 0 OR reward_cleaning <> 0 OR reward_shipping <> 0
+    RETURNING id, lead_id, created_at
+)
+INSERT INTO ledger_entries (transaction_id, account, user_id, service, amount, created_at)
+SELECT tx.id, e.account, CASE WHEN e.account = 'agent' THEN l.user_id END, e.service, e.amount, tx.created_at
+FROM tx
+JOIN leads l ON l.id = tx.lead_id
+CROSS JOIN LATERAL (VALUES
+    ('agent', 'internet', l.reward_internet), ('rewards', 'internet', -l.reward_internet),
+    ('agent', 'cleaning', l.reward_cleaning), ('rewards', 'cleaning', -l.reward_cleaning),
+    ('agent', 'shipping', l.reward_shipping), ('rewards', 'shipping', -l.reward_shipping)
+) AS e (account, service, amount)
+WHERE e.amount <> 0;
+
+-- и за уже активированных рефералов: вознаграждение получает пригласивший
+WITH tx AS (
+    INSERT INTO ledger_transactions (kind, referral_id, description, created_at)
+    SELECT 'accrual', r.id, 'Перенос вознаграждения за реферала', r.created_at
+    FROM referrals r
+    WHERE r.active AND r.cost <> 0
+    RETURNING id, referral_id, created_at
+)
+INSERT INTO ledger_entries (transaction_id, account, user_id, service, amount, created_at)
+SELECT tx.id, e.account, CASE WHEN e.account = 'agent' THEN u.id END, 'referral', e.amount, tx.created_at
+FROM tx
+JOIN referrals r ON r.id = tx.referral_id
+JOIN users u ON u.referral_code = r.referral_id
+CROSS JOIN LATERAL (VALUES ('agent', r.cost), ('rewards', -r.cost)) AS e (account, amount);
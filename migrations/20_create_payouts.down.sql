@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS leads_user_id_payout_id_idx;
+ALTER TABLE leads DROP COLUMN IF EXISTS payout_id;
+
+DROP INDEX IF EXISTS ledger_transactions_payout_idx;
+ALTER TABLE ledger_transactions DROP COLUMN IF EXISTS payout_id;
+
+DROP TABLE IF EXISTS payout_events;
+DROP TABLE IF EXISTS payouts;
@@ -0,0 +1,49 @@
+-- Заявки агентов на вывод вознаграждения.
+-- requested -> approved -> paid, либо requested/approved -> rejected.
+CREATE TABLE payouts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    amount NUMERIC(14,2) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'requested',
+    comment TEXT,
+    reject_reason TEXT,
+    processed_by INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    approved_at TIMESTAMP WITH TIME ZONE,
+    paid_at TIMESTAMP WITH TIME ZONE,
+
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (processed_by) REFERENCES users(id)
+);
+
+-- У агента может быть только одна незакрытая заявка
+CREATE UNIQUE INDEX payouts_user_open_idx ON payouts (user_id) WHERE status IN ('requested', 'approved');
+CREATE INDEX payouts_status_created_at_idx ON payouts (status, created_at);
+
+-- Журнал всех шагов по заявке
+CREATE TABLE payout_events (
+    id SERIAL PRIMARY KEY,
+    payout_id INTEGER NOT NULL,
+    user_id INTEGER,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    comment TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (payout_id) REFERENCES payouts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX payout_events_payout_id_idx ON payout_events (payout_id);
+
+ALTER TABLE ledger_transactions
+    ADD COLUMN payout_id INTEGER REFERENCES payouts(id);
+
+CREATE UNIQUE INDEX ledger_transactions_payout_idx ON ledger_transactions (payout_id) WHERE kind = 'payout';
+
+-- Выплата, которой закрыто вознаграждение по лиду
+ALTER TABLE leads
+    ADD COLUMN payout_id INTEGER REFERENCES payouts(id);
+
+CREATE INDEX leads_user_id_payout_id_idx ON leads (user_id, payout_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS payouts_batch_id_idx;
+ALTER TABLE payouts DROP COLUMN IF EXISTS batch_id;
+
+DROP TABLE IF EXISTS payout_batches;
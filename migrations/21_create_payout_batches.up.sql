@@ -0,0 +1,19 @@
+-- Пачки одобренных заявок, которые уходят в банк одним реестром платежей.
+-- created -> exported (реестр выгружен) -> completed (по всем заявкам пришло подтверждение).
+CREATE TABLE payout_batches (
+    id SERIAL PRIMARY KEY,
+    status VARCHAR(20) NOT NULL DEFAULT 'created',
+    created_by INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    exported_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE INDEX payout_batches_created_at_idx ON payout_batches (created_at);
+
+ALTER TABLE payouts
+    ADD COLUMN batch_id INTEGER REFERENCES payout_batches(id);
+
+CREATE INDEX payouts_batch_id_idx ON payouts (batch_id);
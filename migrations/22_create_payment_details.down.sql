@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS payment_details;
+
+-- Значение 'finance' из user_role удалить нельзя, оно остаётся в типе
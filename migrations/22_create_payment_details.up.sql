@@ -0,0 +1,26 @@
+-- Роль сотрудников финансового отдела: только им реквизиты агентов видны полностью.
+-- ADD VALUE допустим в транзакции начиная с PostgreSQL 12; новое значение здесь не используется.
+ALTER TYPE user_role ADD VALUE IF NOT EXISTS 'finance';
+
+-- Платёжные реквизиты агента. ИНН, номер карты и счёта хранятся зашифрованными
+-- ключом key_id (AES-256-GCM, base64 от nonce и шифротекста).
+CREATE TABLE payment_details (
+    user_id INTEGER PRIMARY KEY,
+    method VARCHAR(20) NOT NULL,
+    full_name VARCHAR(255) NOT NULL,
+    self_employed BOOLEAN NOT NULL DEFAULT false,
+    inn_encrypted TEXT NOT NULL,
+    card_number_encrypted TEXT,
+    account_encrypted TEXT,
+    bik VARCHAR(9),
+    bank_name VARCHAR(255),
+    corr_account VARCHAR(20),
+    key_id VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Поиск записей, ещё не перешифрованных текущим ключом
+CREATE INDEX payment_details_key_id_idx ON payment_details (key_id);
@@ -0,0 +1,9 @@
+ALTER TABLE referrals
+    ALTER COLUMN cost SET DEFAULT 500;
+
+ALTER TABLE leads
+    DROP COLUMN IF EXISTS tariff_internet,
+    DROP COLUMN IF EXISTS tariff_cleaning,
+    DROP COLUMN IF EXISTS tariff_shipping;
+
+DROP TABLE IF EXISTS tariffs;
@@ -0,0 +1,39 @@
+-- Нужен для ограничения EXCLUDE по равенству текстовых колонок
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+-- Тарифы вознаграждения агента за услугу (internet, cleaning, shipping) или реферала (referral).
+-- city NULL — тариф для всех городов, valid_to NULL — бессрочный.
+CREATE TABLE tariffs (
+    id SERIAL PRIMARY KEY,
+    service VARCHAR(20) NOT NULL,
+    city VARCHAR(100),
+    amount NUMERIC(12,2) NOT NULL CHECK (amount >= 0),
+    valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    valid_to TIMESTAMP WITH TIME ZONE,
+    created_by INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    CHECK (valid_to IS NULL OR valid_to > valid_from),
+    -- Периоды тарифов одной услуги в одном городе не пересекаются
+    CONSTRAINT tariffs_no_overlap EXCLUDE USING gist (
+        service WITH =,
+        (lower(COALESCE(city, ''))) WITH =,
+        tstzrange(valid_from, valid_to) WITH &&
+    )
+);
+
+-- Тарифы на момент создания лида; NULL — тарифа не было, вознаграждение берётся из сделки Bitrix
+ALTER TABLE leads
+    ADD COLUMN tariff_internet NUMERIC(12,2),
+    ADD COLUMN tariff_cleaning NUMERIC(12,2),
+    ADD COLUMN tariff_shipping NUMERIC(12,2);
+
+-- Вознаграждение за реферала теперь задаётся тарифом при регистрации;
+-- прежние 500 ₽ становятся общим тарифом
+INSERT INTO tariffs (service, city, amount, valid_from)
+VALUES ('referral', NULL, 500, '2000-01-01 00:00:00+00');
+
+ALTER TABLE referrals
+    ALTER COLUMN cost SET DEFAULT 0;
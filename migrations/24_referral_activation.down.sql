@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS referrals_pending_idx;
+
+ALTER TABLE referrals
+    DROP COLUMN IF EXISTS activated_at,
+    DROP COLUMN IF EXISTS expired_at;
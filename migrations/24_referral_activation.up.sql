@@ -0,0 +1,14 @@
+-- Когда реферал выполнил условия активации или истёк срок, за который он мог их выполнить
+ALTER TABLE referrals
+    ADD COLUMN activated_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN expired_at TIMESTAMP WITH TIME ZONE;
+
+-- Для уже активных рефералов точного времени нет, берём время начисления за них
+UPDATE referrals r
+SET activated_at = COALESCE(
+    (SELECT t.created_at FROM ledger_transactions t WHERE t.referral_id = r.id AND t.kind = 'accrual'),
+    r.created_at
+)
+WHERE r.active;
+
+CREATE INDEX referrals_pending_idx ON referrals (id) WHERE NOT active AND expired_at IS NULL;
@@ -0,0 +1,23 @@
+-- Переходы по реферальным ссылкам /r/{code}. IP хранится только в виде хеша с солью.
+-- token записывается в cookie и связывает переход с регистрацией.
+CREATE TABLE referral_clicks (
+    id SERIAL PRIMARY KEY,
+    token VARCHAR(36) UNIQUE NOT NULL,
+    inviter_id INTEGER NOT NULL,
+    referral_code VARCHAR(100) NOT NULL,
+    user_agent TEXT,
+    ip_hash VARCHAR(64),
+    utm_source VARCHAR(255),
+    utm_medium VARCHAR(255),
+    utm_campaign VARCHAR(255),
+    utm_term VARCHAR(255),
+    utm_content VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    registered_user_id INTEGER,
+    registered_at TIMESTAMP WITH TIME ZONE,
+
+    FOREIGN KEY (inviter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (registered_user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX referral_clicks_inviter_created_at_idx ON referral_clicks (inviter_id, created_at);
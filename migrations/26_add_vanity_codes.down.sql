@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_vanity_code_idx;
+
+ALTER TABLE users DROP COLUMN IF EXISTS vanity_code;
@@ -0,0 +1,5 @@
+-- Короткий код, который агент выбирает сам. UUID из referral_code продолжает работать,
+-- а в referrals.referral_id по-прежнему пишется UUID пригласившего.
+ALTER TABLE users ADD COLUMN vanity_code VARCHAR(20);
+
+CREATE UNIQUE INDEX users_vanity_code_idx ON users (lower(vanity_code));
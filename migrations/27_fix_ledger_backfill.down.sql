@@ -0,0 +1,2 @@
+-- Исправление необратимо: обнулённые вознаграждения незавершённых лидов не восстановить,
+-- а без них сторно нельзя убрать, не разойдясь с книгой.
@@ -0,0 +1,36 @@
+-- Перенос в 19 начислил вознаграждения и незавершённым лидам, хотя оно положено только
+-- лидам в ready/paid с completed_at. Первое же обновление такого лида сторнировало бы начисление.
+
+-- Старым лидам в ready/paid без даты завершения ставим дату создания: перенесённое начисление остаётся
+UPDATE leads l
+SET completed_at = l.created_at
+FROM statuses s
+WHERE s.id = l.status_id AND s.name IN ('ready', 'paid') AND l.completed_at IS NULL;
+
+-- Остальным незавершённым лидам сторнируем начисленное по услугам
+WITH posted AS (
+    SELECT t.lead_id, e.user_id, e.service, SUM(e.amount) AS amount
+    FROM ledger_entries e
+    JOIN ledger_transactions t ON t.id = e.transaction_id
+    JOIN leads l ON l.id = t.lead_id
+    WHERE l.completed_at IS NULL AND t.kind IN ('accrual', 'reversal') AND e.account = 'agent'
+    GROUP BY t.lead_id, e.user_id, e.service
+    HAVING SUM(e.amount) <> 0
+), tx AS (
+    INSERT INTO ledger_transactions (kind, lead_id, description)
+    SELECT 'reversal', lead_id, 'Сторно перенесённого вознаграждения по незавершённому лиду'
+    FROM posted
+    GROUP BY lead_id
+    RETURNING id, lead_id, created_at
+)
+INSERT INTO ledger_entries (transaction_id, account, user_id, service, amount, created_at)
+SELECT tx.id, e.account, CASE WHEN e.account = 'agent' THEN p.user_id END, p.service, e.amount, tx.created_at
+FROM tx
+JOIN posted p ON p.lead_id = tx.lead_id
+CROSS JOIN LATERAL (VALUES ('agent', -p.amount), ('rewards', p.amount)) AS e (account, amount);
+
+-- и обнуляем их вознаграждения, чтобы лид и книга совпадали
+UPDATE leads
+SET reward_internet = 0, reward_cleaning = 0, reward_shipping = 0
+WHERE completed_at IS NULL
+    AND (reward_internet <> 0 OR reward_cleaning <> 0 OR reward_shipping <> 0);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS payouts_leads_unpaid_idx;
+
+ALTER TABLE payouts DROP COLUMN IF EXISTS leads_paid_at;
@@ -0,0 +1,7 @@
+-- Когда покрытые выплатой лиды отмечены оплаченными. Пустое у оплаченной заявки значит,
+-- что закрыть лиды не удалось и планировщик повторит попытку.
+ALTER TABLE payouts ADD COLUMN leads_paid_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE payouts SET leads_paid_at = paid_at WHERE status = 'paid';
+
+CREATE INDEX payouts_leads_unpaid_idx ON payouts (id) WHERE status = 'paid' AND leads_paid_at IS NULL;